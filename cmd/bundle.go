@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// bundleCmd represents the bundle command
+var bundleCmd = &cobra.Command{
+	Use:   "bundle [series...]",
+	Short: "assemble downloaded chapters into volumes",
+	Long: `The bundle command assembles the chapters of each volume into one
+"Series Vol. NN.cbz" once every chapter of the volume has been downloaded. It
+bundles the sources in the config with bundle set, or the sources and series
+passed by name or url.
+
+Chapters are assigned to volumes with the volume_map of the source or the
+volume the site gives them. The volume cover saved with the series art is used
+as the first page and every chapter is bookmarked in ComicInfo.xml.
+
+Sources with bundle set are also bundled by manga download and manga watch
+after each run. With --delete, or bundle_delete in the config, the chapter
+files are removed once they are bundled.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		dry, _ := cmd.Flags().GetBool("dry-run")
+		del, _ := cmd.Flags().GetBool("delete")
+
+		sources, err := bundleSources(args)
+		if err != nil {
+			return err
+		}
+		naming, err := configNaming()
+		if err != nil {
+			return err
+		}
+		site.SetNaming(naming)
+
+		var db *site.DB
+		if dry {
+			db, err = openDBReadOnly()
+		} else {
+			db, err = site.OpenDB(viper.GetString("database"))
+		}
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		dir := viper.GetString("dir")
+		bundles := []*site.VolumeBundle{}
+		failed := 0
+		for _, s := range sources {
+			s.BundleDelete = s.BundleDelete || del
+			b, err := site.Bundle(db, dir, s, dry)
+			if err != nil {
+				slog.Error("Could not bundle source", "url", s.URL, "err", err)
+				failed++
+				continue
+			}
+			bundles = append(bundles, b...)
+		}
+		for _, b := range bundles {
+			if b.Status == site.BundleFailed {
+				failed++
+			}
+			if rel, err := filepath.Rel(dir, b.File); err == nil {
+				b.File = filepath.ToSlash(rel)
+			}
+		}
+
+		if format == outputJSON {
+			err = printJSON(bundles)
+		} else {
+			w := newTable("FILE", "CHAPTERS", "STATUS")
+			for _, b := range bundles {
+				status := b.Status
+				if len(b.Missing) > 0 {
+					status += ": missing " + formatChapters(b.Missing)
+				}
+				if b.Error != "" {
+					status += ": " + b.Error
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\n", b.File, len(b.Chapters), status)
+			}
+			err = w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d volumes or sources could not be bundled", failed)
+		}
+		return nil
+	},
+}
+
+// bundleSources returns the configured sources to bundle, the ones with
+// bundle set or the ones matching the names or urls passed.
+func bundleSources(args []string) ([]*site.Source, error) {
+	sources, err := config.Sources()
+	if err != nil {
+		return nil, err
+	}
+	matched := []*site.Source{}
+	for _, s := range sources {
+		if len(args) == 0 && s.Bundle {
+			matched = append(matched, s)
+		}
+		for _, arg := range args {
+			if strings.EqualFold(s.Name, arg) || s.URL == arg {
+				matched = append(matched, s)
+				break
+			}
+		}
+	}
+	if len(matched) == 0 {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("no sources have bundle set in the config")
+		}
+		return nil, fmt.Errorf("no sources match %s", strings.Join(args, ", "))
+	}
+	return matched, nil
+}
+
+func formatChapters(chapters []float64) string {
+	s := make([]string, len(chapters))
+	for i, c := range chapters {
+		s[i] = fmt.Sprintf("%g", c)
+	}
+	return strings.Join(s, ", ")
+}
+
+func init() {
+	rootCmd.AddCommand(bundleCmd)
+	bundleCmd.Flags().Bool("dry-run", false, "list the volumes that would be bundled without writing them")
+	bundleCmd.Flags().Bool("delete", false, "remove the chapter files once they are bundled")
+	addOutputFlag(bundleCmd)
+}
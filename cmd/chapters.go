@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/library"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// chaptersCmd represents the chapters command
+var chaptersCmd = &cobra.Command{
+	Use:   "chapters <series>",
+	Short: "list the downloaded chapters of a series",
+	Long: `The chapters command lists the downloaded chapters of a series and the whole
+chapters missing from the numbering. The series can be its name or series id
+as shown by manga series.
+
+Chapters are read from the database and from the files in the series folder so
+books downloaded before the database tracked them are included.
+
+While watch is running it has the database locked, the database is read from
+its api instead so listen has to be set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		snap, err := readSnapshot()
+		if err != nil {
+			return err
+		}
+
+		report, err := listChapters(snap, library.New(viper.GetString("dir")), args[0])
+		if err != nil {
+			return err
+		}
+
+		if format == outputJSON {
+			return printJSON(report)
+		}
+		w := newTable("CHAPTER", "VOLUME", "TITLE", "DOWNLOADED", "FILE")
+		for _, c := range report.Chapters {
+			volume := "-"
+			if c.Volume != 0 {
+				volume = fmt.Sprint(c.Volume)
+			}
+			fmt.Fprintf(w, "%g\t%s\t%s\t%s\t%s\n", c.Chapter, volume, orDash(c.Title), formatTime(c.DownloadedAt), c.File)
+		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+		if len(report.Missing) > 0 {
+			missing := make([]string, len(report.Missing))
+			for i, m := range report.Missing {
+				missing[i] = fmt.Sprintf("%g", m)
+			}
+			fmt.Printf("\nmissing %d: %s\n", len(missing), strings.Join(missing, ", "))
+		}
+		return nil
+	},
+}
+
+type chapterReport struct {
+	Series   string         `json:"series"`
+	IDs      []string       `json:"series_ids"`
+	Chapters []*chapterInfo `json:"chapters"`
+	Missing  []float64      `json:"missing"`
+}
+
+type chapterInfo struct {
+	Chapter      float64   `json:"chapter"`
+	Volume       int       `json:"volume,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	File         string    `json:"file"`
+	Source       string    `json:"source,omitempty"`
+	DownloadedAt time.Time `json:"downloaded_at,omitzero"`
+}
+
+func listChapters(snap *dbSnapshot, lib *library.Library, query string) (*chapterReport, error) {
+	names, books := snap.Series, snap.Books
+
+	report := &chapterReport{IDs: []string{}, Chapters: []*chapterInfo{}}
+	matches := func(id, name string) bool {
+		return id == query || strings.EqualFold(name, query)
+	}
+	for id, name := range names {
+		if matches(id, name) {
+			report.Series = name
+			report.IDs = append(report.IDs, id)
+		}
+	}
+
+	files := map[string]bool{}
+	for _, b := range books {
+		if !matches(b.SeriesID, b.Series) && !slices.Contains(report.IDs, b.SeriesID) {
+			continue
+		}
+		if report.Series == "" {
+			report.Series = b.Series
+		}
+		if !slices.Contains(report.IDs, b.SeriesID) {
+			report.IDs = append(report.IDs, b.SeriesID)
+		}
+		files[b.File] = true
+		report.Chapters = append(report.Chapters, &chapterInfo{
+			Chapter:      b.Chapter,
+			Volume:       b.Volume,
+			Title:        b.Title,
+			File:         b.File,
+			Source:       b.Source,
+			DownloadedAt: b.DownloadedAt,
+		})
+	}
+
+	// the folder may be named differently to the query, e.g. when searching
+	// by id
+	folder := cmp.Or(report.Series, query)
+	folders, err := lib.Series()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range folders {
+		if strings.EqualFold(s.Name, folder) {
+			folder = s.Name
+			break
+		}
+	}
+	onDisk, err := lib.Books(folder)
+	if err != nil && !errors.Is(err, library.ErrNotFound) {
+		return nil, err
+	}
+	for _, b := range onDisk {
+		if files[b.RelPath()] {
+			continue
+		}
+		volume, chapter, ok := library.ParseName(b.Name)
+		if !ok {
+			continue
+		}
+		report.Chapters = append(report.Chapters, &chapterInfo{
+			Chapter: chapter,
+			Volume:  volume,
+			File:    filepath.ToSlash(b.RelPath()),
+		})
+	}
+
+	if report.Series == "" && len(onDisk) == 0 {
+		return nil, fmt.Errorf("no series named %q, see manga series for the known series", query)
+	}
+	if report.Series == "" {
+		report.Series = folder
+	}
+
+	slices.SortFunc(report.Chapters, func(a, b *chapterInfo) int {
+		return cmp.Or(
+			cmp.Compare(a.Chapter, b.Chapter),
+			cmp.Compare(a.Volume, b.Volume),
+			strings.Compare(a.File, b.File),
+		)
+	})
+	chapters := make([]float64, 0, len(report.Chapters))
+	for _, c := range report.Chapters {
+		if c.Chapter != 0 {
+			chapters = append(chapters, c.Chapter)
+		}
+	}
+	report.Missing = library.Gaps(chapters)
+	return report, nil
+}
+
+func init() {
+	rootCmd.AddCommand(chaptersCmd)
+	addOutputFlag(chaptersCmd)
+}
@@ -17,11 +17,12 @@ package cmd
 import (
 	"fmt"
 	"log/slog"
-	"strconv"
 	"strings"
 
+	"github.com/abibby/manga/config"
 	"github.com/abibby/manga/site"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 
 	// Connectors
@@ -38,42 +39,111 @@ var downloadCmd = &cobra.Command{
 	Short:   "downloads manga from a url",
 	Long: fmt.Sprintf(`The download command downloads manga from a url.
 Manga can download from any site that has a connector setup.
-Currently the installed connectors are %s.`, strings.Join(site.ConnectorNames(), ", ")),
+Currently the installed connectors are %s.
+
+The selection flags pick which chapters are downloaded, without urls they
+override the settings of the sources in the config. Ranges are lists like
+1-10,15,20.5 and dates look like 2024-01-31.
+
+With --dry-run the books that would be downloaded are listed with the file
+they would be saved to, --naming previews a different naming template.
+
+Pages are processed with the image profile of the source, or the default
+profile in the config. --profile picks a profile from the config for this
+run.`, strings.Join(site.ConnectorNames(), ", ")),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		sources := []*site.Source{}
-		if len(args) > 0 {
-			from, err := strconv.ParseFloat(cmd.Flag("from").Value.String(), 64)
+		for _, url := range args {
+			sources = append(sources, &site.Source{URL: url})
+		}
+		if len(sources) == 0 {
+			var err error
+			sources, err = config.Sources()
 			if err != nil {
 				return err
 			}
-			for _, url := range args {
-				sources = append(sources, &site.Source{
-					URL:  url,
-					From: from,
-				})
+		}
+		for _, src := range sources {
+			err := applySelectionFlags(cmd.Flags(), src)
+			if err != nil {
+				return err
 			}
 		}
+
+		dry, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+		if dry {
+			return dryRun(cmd, viper.GetString("dir"), sources)
+		}
 		return download(sources)
 	},
 }
 
+// applySelectionFlags copies the selection flags that were passed onto src
+func applySelectionFlags(flags *pflag.FlagSet, src *site.Source) error {
+	if flags.Changed("from") {
+		src.From, _ = flags.GetFloat64("from")
+	}
+	if flags.Changed("to") {
+		src.To, _ = flags.GetFloat64("to")
+	}
+	if flags.Changed("chapters") {
+		chapters, _ := flags.GetString("chapters")
+		src.Chapters = site.Ranges(chapters)
+	}
+	if flags.Changed("volumes") {
+		volumes, _ := flags.GetString("volumes")
+		src.Volumes = site.Ranges(volumes)
+	}
+	if flags.Changed("latest") {
+		src.Latest, _ = flags.GetInt("latest")
+	}
+	if flags.Changed("skip-extras") {
+		src.SkipExtras, _ = flags.GetBool("skip-extras")
+	}
+	if flags.Changed("released-after") {
+		src.ReleasedAfter, _ = flags.GetString("released-after")
+	}
+	if flags.Changed("released-before") {
+		src.ReleasedBefore, _ = flags.GetString("released-before")
+	}
+	if flags.Changed("profile") {
+		src.Profile, _ = flags.GetString("profile")
+	}
+	return src.ValidateSelection()
+}
+
 func init() {
 	rootCmd.AddCommand(downloadCmd)
 
-	downloadCmd.Flags().IntP("from", "f", 0, "the chapter to start downloading, inclusive")
+	downloadCmd.Flags().Float64P("from", "f", 0, "the chapter to start downloading, inclusive")
+	downloadCmd.Flags().Float64("to", 0, "the last chapter to download, inclusive")
+	downloadCmd.Flags().String("chapters", "", "only download chapters in these ranges, e.g. 1-10,15")
+	downloadCmd.Flags().String("volumes", "", "only download volumes in these ranges, e.g. 1-3")
+	downloadCmd.Flags().Int("latest", 0, "only download the newest n chapters")
+	downloadCmd.Flags().Bool("skip-extras", false, "skip fractional chapters like 10.5 and books without a chapter number")
+	downloadCmd.Flags().String("released-after", "", "skip chapters released before this date")
+	downloadCmd.Flags().String("released-before", "", "skip chapters released on or after this date")
+	downloadCmd.Flags().String("profile", "", "the image profile pages are processed with, none keeps them as they are")
+	addDryRunFlags(downloadCmd)
 }
 
 func download(sources []*site.Source) error {
-	if len(sources) == 0 {
-		err := viper.UnmarshalKey("sources", &sources)
-		if err != nil {
-			return err
-		}
-	}
 	mangaPath := viper.GetString("dir")
 	if mangaPath == "" {
 		return fmt.Errorf("must set dir in the config")
 	}
+	naming, err := configNaming()
+	if err != nil {
+		return err
+	}
+	site.SetNaming(naming)
+	err = setConfigProfiles()
+	if err != nil {
+		return err
+	}
 
 	dbPath := viper.GetString("database")
 	db, err := site.OpenDB(dbPath)
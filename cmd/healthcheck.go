@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// healthcheckCmd represents the healthcheck command
+var healthcheckCmd = &cobra.Command{
+	Use:   "healthcheck",
+	Short: "check that a running watch is healthy",
+	Long: `The healthcheck command requests /healthz from the server started by
+watch --listen and exits with an error if it is unhealthy. It is meant to be
+used as a Docker HEALTHCHECK since the image has no curl or wget.
+
+When no url is passed the listen address from the config is used, it fails if
+listen isn't set since there is no way to tell if watch is running.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		uri, err := cmd.Flags().GetString("url")
+		if err != nil {
+			return err
+		}
+		if uri == "" {
+			listen := viper.GetString("listen")
+			if listen == "" {
+				return fmt.Errorf("listen is not set, pass --url or set listen in the config")
+			}
+			uri, err = healthzURL(listen)
+			if err != nil {
+				return err
+			}
+		}
+
+		client := &http.Client{Timeout: 5 * time.Second}
+		resp, err := client.Get(uri)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unhealthy: %s %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		fmt.Println(strings.TrimSpace(string(body)))
+		return nil
+	},
+}
+
+// healthzURL turns a listen address like :8080 into a url on localhost.
+func healthzURL(listen string) (string, error) {
+	base, err := listenURL(listen)
+	if err != nil {
+		return "", err
+	}
+	return base + "/healthz", nil
+}
+
+// listenURL turns a listen address like :8080 into the url of the server on
+// localhost.
+func listenURL(listen string) (string, error) {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return "http://" + net.JoinHostPort(host, port), nil
+}
+
+func init() {
+	rootCmd.AddCommand(healthcheckCmd)
+
+	healthcheckCmd.Flags().String("url", "", "the healthz url, defaults to the listen address on localhost")
+}
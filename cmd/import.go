@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [series...]",
+	Short: "add books that are already in the library to the database",
+	Long: `The import command scans the series folders in the library, or only the ones
+passed, for books the database doesn't know about. This includes books
+downloaded by other tools and folders of images with a book.json, which are
+packed into a cbz when they are imported. Folders named by the naming template
+are unfinished downloads and are left to watch and download.
+
+Each book is identified from its book.json, its ComicInfo.xml or its file name
+and matched to a book from the configured sources in the same series with the
+same chapter and volume. Matched books are recorded in the database so they
+aren't downloaded again, even when their file names are different from the
+ones manga would use.
+
+With --dry-run the matches are listed without changing the database.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		dry, _ := cmd.Flags().GetBool("dry-run")
+
+		sources, err := config.Sources()
+		if err != nil {
+			return err
+		}
+
+		naming, err := configNaming()
+		if err != nil {
+			return err
+		}
+		entries, err := library.New(viper.GetString("dir")).Entries(naming, args...)
+		if err != nil {
+			return err
+		}
+
+		var db *site.DB
+		if dry {
+			db, err = openDBReadOnly()
+		} else {
+			db, err = site.OpenDB(viper.GetString("database"))
+		}
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		results, err := importBooks(db, sources, entries, dry)
+		if err != nil {
+			return err
+		}
+
+		imported, failed := 0, 0
+		for _, r := range results {
+			switch r.Status {
+			case importImported, importMatched:
+				imported++
+			case importFailed:
+				failed++
+			}
+		}
+
+		if format == outputJSON {
+			err = printJSON(results)
+		} else {
+			w := newTable("FILE", "FROM", "VOLUME", "CHAPTER", "STATUS", "SOURCE")
+			for _, r := range results {
+				volume, chapter := "-", "-"
+				if r.Volume != 0 {
+					volume = fmt.Sprint(r.Volume)
+				}
+				if r.Chapter != 0 {
+					chapter = fmt.Sprintf("%g", r.Chapter)
+				}
+				status := r.Status
+				if r.Error != "" {
+					status += ": " + r.Error
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.File, orDash(r.From), volume, chapter, status, orDash(r.Source))
+			}
+			err = w.Flush()
+			if err == nil {
+				verb := "imported"
+				if dry {
+					verb = "would import"
+				}
+				fmt.Printf("\n%s %d of %d books, %d could not be matched\n", verb, imported, len(results), failed)
+			}
+		}
+		return err
+	},
+}
+
+const (
+	importImported = "imported"
+	importMatched  = "matched"
+	importTracked  = "tracked"
+	importFailed   = "failed"
+)
+
+type importResult struct {
+	File    string  `json:"file"`
+	From    string  `json:"from,omitempty"`
+	Volume  int     `json:"volume,omitempty"`
+	Chapter float64 `json:"chapter,omitempty"`
+	Status  string  `json:"status"`
+	Source  string  `json:"source,omitempty"`
+	BookID  string  `json:"book_id,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// importBooks records the entries that aren't in the database yet, with dry
+// set they are only matched.
+func importBooks(db *site.DB, sources []*site.Source, entries []*library.Entry, dry bool) ([]*importResult, error) {
+	results := []*importResult{}
+	locals := []*site.LocalBook{}
+	pending := []*importResult{}
+	pendingEntries := []*library.Entry{}
+	for _, e := range entries {
+		r := &importResult{File: e.Rel}
+		results = append(results, r)
+
+		rec, err := db.Book(e.Rel)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil {
+			r.Status, r.Volume, r.Chapter, r.Source, r.BookID = importTracked, rec.Volume, rec.Chapter, rec.Source, rec.BookID
+			continue
+		}
+
+		id, err := e.Identify()
+		if err != nil {
+			r.Status, r.Error = importFailed, err.Error()
+			continue
+		}
+		r.From, r.Volume, r.Chapter = id.From, id.Volume, id.Chapter
+		locals = append(locals, &site.LocalBook{
+			File:    e.Rel,
+			Folder:  e.Series,
+			Series:  id.Series,
+			Volume:  id.Volume,
+			Chapter: id.Chapter,
+			Title:   id.Title,
+			BookID:  id.BookID,
+			Source:  id.Source,
+			ModTime: e.ModTime,
+		})
+		pending = append(pending, r)
+		pendingEntries = append(pendingEntries, e)
+	}
+
+	for i, m := range site.MatchBooks(db, sources, locals) {
+		r := pending[i]
+		if m.Err != nil {
+			r.Status, r.Error = importFailed, m.Err.Error()
+			continue
+		}
+		r.Source, r.BookID = m.Record.Source, m.Record.BookID
+		if dry {
+			r.Status = importMatched
+			continue
+		}
+		// the downloader only counts files as downloaded books
+		if e := pendingEntries[i]; e.IsDir {
+			err := e.Pack()
+			if err != nil {
+				r.Status, r.Error = importFailed, err.Error()
+				continue
+			}
+			r.File, m.Record.File = e.Rel, e.Rel
+		}
+		err := db.AddBook(m.Record)
+		if err != nil {
+			return nil, err
+		}
+		if !m.Pinned {
+			err = db.InitSeriesName(m.Record.SeriesID, m.Record.Series)
+			if err != nil {
+				return nil, err
+			}
+		}
+		r.Status = importImported
+	}
+	return results, nil
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().Bool("dry-run", false, "list the matches without changing the database")
+	addOutputFlag(importCmd)
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", outputTable, "the output format, table or json")
+}
+
+func outputFormat(cmd *cobra.Command) (string, error) {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return "", err
+	}
+	if format != outputTable && format != outputJSON {
+		return "", fmt.Errorf("unknown output format %q, must be table or json", format)
+	}
+	return format, nil
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// newTable returns a writer that aligns tab separated columns, call Flush
+// when done.
+func newTable(headers ...string) *tabwriter.Writer {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i, h := range headers {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, h)
+	}
+	fmt.Fprintln(w)
+	return w
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// openDBReadOnly opens the database for commands that only read it.
+func openDBReadOnly() (*site.DB, error) {
+	return site.OpenDBReadOnly(viper.GetString("database"))
+}
+
+// dbSnapshot is the part of the database the listing commands read.
+type dbSnapshot struct {
+	Series map[string]string
+	Books  []*site.BookRecord
+}
+
+// readSnapshot reads the series names and books from the database. While
+// watch has the database locked they are read from its api if listen is set.
+func readSnapshot() (*dbSnapshot, error) {
+	db, err := openDBReadOnly()
+	if site.IsLocked(err) && viper.GetString("listen") != "" {
+		return apiSnapshot()
+	} else if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	snap := &dbSnapshot{}
+	snap.Series, err = db.AllSeries()
+	if err != nil {
+		return nil, err
+	}
+	snap.Books, err = db.Books()
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+func apiSnapshot() (*dbSnapshot, error) {
+	series := []*struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}{}
+	err := getAPI("/api/series", &series)
+	if err != nil {
+		return nil, err
+	}
+	snap := &dbSnapshot{Series: map[string]string{}}
+	for _, s := range series {
+		snap.Series[s.ID] = s.Name
+	}
+	err = getAPI("/api/books", &snap.Books)
+	if err != nil {
+		return nil, err
+	}
+	return snap, nil
+}
+
+// getAPI decodes the json from an endpoint of the api served by watch
+// --listen.
+func getAPI(path string, v any) error {
+	base, err := listenURL(viper.GetString("listen"))
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(base + path)
+	if err != nil {
+		return fmt.Errorf("the database is locked by manga watch and its api can't be reached: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
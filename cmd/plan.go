@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type sourcePlan struct {
+	Source string              `json:"source"`
+	Name   string              `json:"name,omitempty"`
+	Books  []*site.PlannedBook `json:"books"`
+	Error  string              `json:"error,omitempty"`
+}
+
+func addDryRunFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("dry-run", false, "list the books that would be downloaded without downloading them")
+	cmd.Flags().String("naming", "", "preview a naming template with --dry-run instead of the one in the config")
+	addOutputFlag(cmd)
+}
+
+// configNaming parses the naming template from the config
+func configNaming() (*site.Naming, error) {
+	return site.ParseNaming(viper.GetString("naming"))
+}
+
+// setConfigProfiles sets the image profiles downloads use from the config
+func setConfigProfiles() error {
+	profiles, err := config.Profiles()
+	if err != nil {
+		return err
+	}
+	def := viper.GetString("profile")
+	err = config.ValidateProfiles(profiles, def)
+	if err != nil {
+		return err
+	}
+	site.SetProfiles(profiles, def)
+	return nil
+}
+
+// dryRun prints the books each source would download. Nothing is downloaded
+// and the database is opened read only so new series names aren't saved.
+func dryRun(cmd *cobra.Command, dir string, sources []*site.Source) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	naming, err := configNaming()
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("naming") {
+		text, _ := cmd.Flags().GetString("naming")
+		naming, err = site.ParseNaming(text)
+		if err != nil {
+			return err
+		}
+	}
+
+	db, err := openDBReadOnly()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	plans := make([]*sourcePlan, len(sources))
+	for i, src := range sources {
+		plan := &sourcePlan{Source: src.URL, Name: src.Name, Books: []*site.PlannedBook{}}
+		books, err := site.Plan(db, dir, src, naming)
+		if err != nil {
+			// the table has no error column, json has the error in the plan
+			if format != outputJSON {
+				slog.Error("Error planning source", "url", src.URL, "err", err)
+			}
+			plan.Error = err.Error()
+		} else {
+			plan.Books = books
+		}
+		plans[i] = plan
+	}
+
+	if format == outputJSON {
+		return printJSON(plans)
+	}
+	w := newTable("SERIES", "CHAPTER", "VOLUME", "FILE")
+	for _, plan := range plans {
+		for _, b := range plan.Books {
+			file, err := filepath.Rel(dir, b.File)
+			if err != nil {
+				file = b.File
+			}
+			volume := "-"
+			if b.Volume != 0 {
+				volume = fmt.Sprint(b.Volume)
+			}
+			fmt.Fprintf(w, "%s\t%g\t%s\t%s\n", b.Series, b.Chapter, volume, file)
+		}
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, plan := range plans {
+		total += len(plan.Books)
+	}
+	fmt.Printf("\n%d books would be downloaded\n", total)
+	return nil
+}
@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// retagCmd represents the retag command
+var retagCmd = &cobra.Command{
+	Use:   "retag [series...]",
+	Short: "update the metadata of downloaded books",
+	Long: `The retag command loads the metadata of every downloaded book, or only the books
+in the series folders passed, from the connector it was downloaded from and
+rewrites the book.json, and the ComicInfo.xml if it has one, in the archive.
+The images are not touched and the new archive replaces the old one in a
+single rename.
+
+With --rename books are also renamed to match the current naming template, the
+database and read progress follow the new file names.
+
+With --dry-run the changes are listed without writing anything.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		dry, _ := cmd.Flags().GetBool("dry-run")
+		rename, _ := cmd.Flags().GetBool("rename")
+
+		naming, err := configNaming()
+		if err != nil {
+			return err
+		}
+
+		lib := library.New(viper.GetString("dir"))
+		books := []*library.Book{}
+		if len(args) > 0 {
+			for _, series := range args {
+				b, err := lib.Books(series)
+				if err != nil {
+					return err
+				}
+				books = append(books, b...)
+			}
+		} else {
+			books, err = lib.AllBooks()
+			if err != nil {
+				return err
+			}
+		}
+
+		var db *site.DB
+		if dry {
+			db, err = openDBReadOnly()
+		} else {
+			db, err = site.OpenDB(viper.GetString("database"))
+		}
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+
+		retagger := site.NewRetagger(db, lib.Dir(), naming)
+		results := make([]*retagResult, len(books))
+		failed := 0
+		for i, b := range books {
+			results[i] = retagBook(db, lib, retagger, b, rename, dry)
+			if results[i].Error != "" {
+				failed++
+			}
+		}
+
+		if format == outputJSON {
+			err = printJSON(results)
+		} else {
+			w := newTable("FILE", "CHANGED", "RENAME")
+			for _, r := range results {
+				changed := strings.Join(r.Changed, ", ")
+				if r.Error != "" {
+					changed = "error: " + r.Error
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", r.File, orDash(changed), orDash(r.Rename))
+			}
+			err = w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d books could not be retagged", failed, len(books))
+		}
+		return nil
+	},
+}
+
+type retagResult struct {
+	File string `json:"file"`
+	// Changed lists the book.json fields that changed
+	Changed []string `json:"changed"`
+	// Rename is the new file relative to the library dir
+	Rename string `json:"rename,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func retagBook(db *site.DB, lib *library.Library, retagger *site.Retagger, b *library.Book, rename, dry bool) *retagResult {
+	r := &retagResult{File: b.RelPath(), Changed: []string{}}
+	fail := func(err error) *retagResult {
+		r.Error = err.Error()
+		return r
+	}
+
+	bookID, sourceURL, err := bookOrigin(db, b)
+	if err != nil {
+		return fail(err)
+	}
+	old, oldComicInfo, err := readInfo(b)
+	if err != nil {
+		return fail(err)
+	}
+	info, file, err := retagger.Retag(sourceURL, bookID, b.Series, old)
+	if err != nil {
+		return fail(err)
+	}
+
+	r.Changed, err = changedFields(old, info)
+	if err != nil {
+		return fail(err)
+	}
+	if rename && filepath.Clean(file) != filepath.Clean(b.Path) {
+		rel, err := filepath.Rel(lib.Dir(), file)
+		if err != nil {
+			return fail(err)
+		}
+		r.Rename = filepath.ToSlash(rel)
+	}
+	if dry {
+		return r
+	}
+
+	if len(r.Changed) > 0 {
+		data, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return fail(err)
+		}
+		files := map[string][]byte{"book.json": data}
+		if oldComicInfo != nil {
+			files["ComicInfo.xml"], err = site.RetagComicInfo(oldComicInfo, info)
+			if err != nil {
+				return fail(err)
+			}
+		}
+		err = library.Rewrite(b.Path, files)
+		if err != nil {
+			return fail(err)
+		}
+	}
+	if r.Rename != "" {
+		if _, err := os.Stat(file); err == nil {
+			return fail(fmt.Errorf("can't rename to %s, the file already exists", r.Rename))
+		}
+		err = os.Rename(b.Path, file)
+		if err != nil {
+			return fail(err)
+		}
+		err = db.MoveBook(b.RelPath(), r.Rename)
+		if err != nil {
+			// put the file back so it still matches the database
+			return fail(errors.Join(err, os.Rename(file, b.Path)))
+		}
+	}
+	return r
+}
+
+// readInfo returns the book.json of a book and its ComicInfo.xml, nil if it
+// doesn't have one.
+func readInfo(b *library.Book) (*site.BookInfo, []byte, error) {
+	a, err := b.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer a.Close()
+	info, err := a.Info()
+	if err != nil {
+		return nil, nil, err
+	}
+	f, ok := a.File("ComicInfo.xml")
+	if !ok {
+		return info, nil, nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	comicInfo, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, comicInfo, nil
+}
+
+// changedFields returns the json names of the book.json fields that are
+// different, pages are compared as a whole.
+func changedFields(old, info *site.BookInfo) ([]string, error) {
+	if old == nil {
+		old = &site.BookInfo{}
+	}
+	a, err := jsonFields(old)
+	if err != nil {
+		return nil, err
+	}
+	b, err := jsonFields(info)
+	if err != nil {
+		return nil, err
+	}
+	changed := []string{}
+	for k, v := range b {
+		if !reflect.DeepEqual(a[k], v) {
+			changed = append(changed, k)
+		}
+	}
+	for k := range a {
+		if _, ok := b[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	slices.Sort(changed)
+	return changed, nil
+}
+
+func jsonFields(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]any{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err = dec.Decode(&fields)
+	return fields, err
+}
+
+func init() {
+	rootCmd.AddCommand(retagCmd)
+	retagCmd.Flags().Bool("rename", false, "rename books to match the naming template")
+	retagCmd.Flags().Bool("dry-run", false, "list the changes without writing them")
+	addOutputFlag(retagCmd)
+}
@@ -15,14 +15,15 @@
 package cmd
 
 import (
+	"bytes"
 	"fmt"
 	"log/slog"
 	"os"
 	"path"
 
-	"github.com/fsnotify/fsnotify"
 	"github.com/lmittmann/tint"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
@@ -31,6 +32,9 @@ var verbose bool
 
 var home string
 
+// configRoot is the folder the database and cookies are kept in by default
+var configRoot = "/etc/manga"
+
 func init() {
 	h, err := os.UserHomeDir()
 	if err != nil {
@@ -58,7 +62,6 @@ func Execute() {
 func init() {
 	cobra.OnInitialize(initConfig)
 
-	configRoot := "/etc/manga"
 	if home != "/" {
 		configRoot = path.Join(home, ".manga")
 	}
@@ -71,25 +74,67 @@ func init() {
 
 	rootCmd.PersistentFlags().StringP("dir", "d", "", "the path to the manga root dir")
 	MustBindPFlag("dir", "dir")
-	viper.SetDefault("dir", path.Join(home, "manga"))
+	setDefault("dir", path.Join(home, "manga"))
+
+	rootCmd.PersistentFlags().String("cookie_dir", "", "the directory connectors store their cookies in")
+	MustBindPFlag("cookie_dir", "cookie_dir")
+	setDefault("cookie_dir", path.Join(configRoot, "cookies"))
 
-	rootCmd.PersistentFlags().String("cookie_file", "", "the path to the cookie file")
+	rootCmd.PersistentFlags().String("cookie_file", "", "deprecated, the path of the viz cookie file, use cookie_dir")
 	MustBindPFlag("cookie_file", "cookie_file")
-	viper.SetDefault("cookie_file", path.Join(configRoot, "cookies.json"))
 
 	rootCmd.PersistentFlags().String("database", "", "the path to the database file")
 	MustBindPFlag("database", "database")
-	viper.SetDefault("database", path.Join(configRoot, "manga.db"))
+	setDefault("database", path.Join(configRoot, "manga.db"))
 
 	rootCmd.PersistentFlags().StringP("language", "l", "", "the language to download chapters in")
 	MustBindPFlag("language", "language")
-	viper.SetDefault("language", "en")
+	setDefault("language", "en")
 }
 func MustBindPFlag(configKey, flagName string) {
-	err := viper.BindPFlag(configKey, rootCmd.PersistentFlags().Lookup(flagName))
+	mustBindFlag(configKey, rootCmd.PersistentFlags().Lookup(flagName))
+}
+
+// configDefaults and configFlags are the defaults and flags set on viper,
+// they are also set on the viper a changed config file is checked with.
+var (
+	configDefaults = map[string]any{}
+	configFlags    = map[string]*pflag.Flag{}
+)
+
+func setDefault(key string, value any) {
+	configDefaults[key] = value
+	viper.SetDefault(key, value)
+}
+
+func mustBindFlag(key string, flag *pflag.Flag) {
+	err := viper.BindPFlag(key, flag)
 	if err != nil {
 		panic(err)
 	}
+	configFlags[key] = flag
+}
+
+// readConfig reads the contents of the config file into a new viper with the
+// same defaults and flags as the global one.
+func readConfig(b []byte) (*viper.Viper, error) {
+	v := viper.New()
+	for key, value := range configDefaults {
+		v.SetDefault(key, value)
+	}
+	for key, flag := range configFlags {
+		err := v.BindPFlag(key, flag)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the file name sets the config type
+	v.SetConfigFile(viper.ConfigFileUsed())
+	err := v.ReadConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -100,14 +145,16 @@ func initConfig() {
 		level = slog.LevelDebug - 4
 	}
 
-	fi, err := os.Stdout.Stat()
+	// logs go to stderr so stdout only has the output of the command, like
+	// the json from -o json
+	fi, err := os.Stderr.Stat()
 	isTTY := err == nil && (fi.Mode()&os.ModeCharDevice) != 0
 	if isTTY {
-		slog.SetDefault(slog.New(tint.NewHandler(os.Stdout, &tint.Options{
+		slog.SetDefault(slog.New(tint.NewHandler(os.Stderr, &tint.Options{
 			Level: level,
 		})))
 	} else {
-		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 			Level: level,
 		})))
 	}
@@ -141,8 +188,31 @@ func initConfig() {
 	} else {
 		slog.Info("Using config file", "file", viper.ConfigFileUsed())
 	}
-	viper.OnConfigChange(func(e fsnotify.Event) {
-		slog.Info("Config file changed", "file", e.Name)
-	})
-	viper.WatchConfig()
+	migrateCookieFile()
+}
+
+// migrateCookieFile moves the viz session from cookies.json, where it was
+// kept before cookie_dir, so existing logins carry over. A cookie_file set in
+// the config is still used where it is.
+func migrateCookieFile() {
+	if viper.GetString("cookie_file") != "" || viper.GetString("viz.cookie_file") != "" {
+		return
+	}
+	old := path.Join(configRoot, "cookies.json")
+	dst := path.Join(viper.GetString("cookie_dir"), "viz.json")
+	if _, err := os.Stat(old); err != nil {
+		return
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return
+	}
+	err := os.MkdirAll(path.Dir(dst), 0700)
+	if err == nil {
+		err = os.Rename(old, dst)
+	}
+	if err != nil {
+		slog.Warn("Could not move the viz cookies into cookie_dir", "from", old, "to", dst, "err", err)
+		return
+	}
+	slog.Info("Moved the viz cookies into cookie_dir", "from", old, "to", dst)
 }
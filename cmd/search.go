@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// searchCmd represents the search command
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "search the connectors for a series",
+	Long: fmt.Sprintf(`The search command looks for series by title on every connector that
+supports searching and prints the url to use as a source.
+
+Use --add with the number of a result to add it to the sources in the config
+file. It picks from the results the last search with the same query printed,
+the connectors aren't searched again so the numbers can't change. Currently
+the installed connectors are %s.`, strings.Join(site.ConnectorNames(), ", ")),
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		add, err := cmd.Flags().GetInt("add")
+		if err != nil {
+			return err
+		}
+		connector, err := cmd.Flags().GetString("connector")
+		if err != nil {
+			return err
+		}
+
+		query := strings.Join(args, " ")
+		connectors := []string{}
+		if connector != "" {
+			connectors = append(connectors, connector)
+		}
+
+		if add > 0 {
+			last, err := loadLastSearch()
+			if err != nil {
+				return err
+			}
+			if last == nil || last.Query != query || !slices.EqualFunc(last.Connectors, connectors, strings.EqualFold) {
+				return fmt.Errorf("search for %q before adding one of its results", query)
+			}
+			if add > len(last.Results) {
+				return fmt.Errorf("there are only %d results", len(last.Results))
+			}
+			return addSearchResult(last.Results[add-1])
+		}
+
+		results, err := site.Search(query, connectors...)
+		if results == nil {
+			return err
+		} else if err != nil {
+			// one connector failing shouldn't hide the results of the others
+			slog.Warn("Search failed", "err", err)
+		}
+		err = saveLastSearch(&lastSearch{Query: query, Connectors: connectors, Results: results})
+		if err != nil {
+			slog.Warn("Could not save the search results", "err", err)
+		}
+
+		if format == outputJSON {
+			return printJSON(results)
+		}
+		if len(results) == 0 {
+			fmt.Println("no results")
+			return nil
+		}
+		w := newTable("#", "CONNECTOR", "TITLE", "AUTHOR", "STATUS", "URL")
+		for i, r := range results {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, r.Connector, truncate(r.Title, 50), truncate(orDash(r.Author), 30), orDash(r.Status), r.URL)
+		}
+		return w.Flush()
+	},
+}
+
+// lastSearch is the search --add picks results from.
+type lastSearch struct {
+	Query      string               `json:"query"`
+	Connectors []string             `json:"connectors"`
+	Results    []*site.SearchResult `json:"results"`
+}
+
+// lastSearchFile is kept next to the database.
+func lastSearchFile() string {
+	return filepath.Join(filepath.Dir(viper.GetString("database")), "last-search.json")
+}
+
+func loadLastSearch() (*lastSearch, error) {
+	b, err := os.ReadFile(lastSearchFile())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	last := &lastSearch{}
+	err = json.Unmarshal(b, last)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", lastSearchFile(), err)
+	}
+	return last, nil
+}
+
+func saveLastSearch(last *lastSearch) error {
+	b, err := json.Marshal(last)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(lastSearchFile()), 0775)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lastSearchFile(), b, 0644)
+}
+
+func addSearchResult(r *site.SearchResult) error {
+	file, err := config.File()
+	if err != nil {
+		return err
+	}
+	err = config.AddSource(file, &site.Source{URL: r.URL})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("added %s (%s) to %s\n", r.Title, r.URL, file)
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	addOutputFlag(searchCmd)
+
+	searchCmd.Flags().Int("add", 0, "add the result with this number to the sources in the config file")
+	searchCmd.Flags().StringP("connector", "c", "", "only search this connector")
+}
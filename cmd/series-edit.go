@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var seriesRenameCmd = &cobra.Command{
+	Use:   "rename <series> <name>",
+	Short: "rename a series",
+	Long: `The rename command moves a series folder to a new name. The series can be its
+name or a series id as shown by manga series.
+
+File names that start with the old name are renamed, the series in the
+book.json of every book is updated and the database is changed so new chapters
+are downloaded into the new folder. Sources pinned to the old name in the
+config file are pinned to the new one.`,
+	Args:         cobra.ExactArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editSeries(cmd, args[1], args[:1], true)
+	},
+}
+
+var seriesMergeCmd = &cobra.Command{
+	Use:   "merge <name> <series...>",
+	Short: "merge series into one folder",
+	Long: `The merge command moves the books of each series into one folder called name,
+e.g. to keep the chapters from mangaplus:100020 and a MangaDex series together.
+The series can be names or series ids as shown by manga series, with an id
+only the books downloaded for that id are moved.
+
+Books are renamed and updated the same way as manga series rename. Books that
+would replace a file that is already in the folder are left where they are.`,
+	Args:         cobra.MinimumNArgs(2),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editSeries(cmd, args[0], args[1:], false)
+	},
+}
+
+func editSeries(cmd *cobra.Command, target string, queries []string, rename bool) error {
+	format, err := outputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	dry, _ := cmd.Flags().GetBool("dry-run")
+
+	var db *site.DB
+	if dry {
+		db, err = openDBReadOnly()
+	} else {
+		db, err = site.OpenDB(viper.GetString("database"))
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	lib := library.New(viper.GetString("dir"))
+	var edit *library.SeriesEdit
+	if rename {
+		edit, err = lib.RenameSeries(db, queries[0], target, dry)
+	} else {
+		edit, err = lib.MergeSeries(db, target, queries, dry)
+	}
+	if err != nil {
+		return err
+	}
+	if !dry {
+		for _, old := range edit.Renamed {
+			err = repinSources(old, edit.Target)
+			if err != nil {
+				slog.Warn("Could not update sources in the config file", "err", err)
+			}
+		}
+	}
+
+	if format == outputJSON {
+		err = printJSON(edit.Moves)
+	} else {
+		w := newTable("FROM", "TO", "STATUS")
+		for _, m := range edit.Moves {
+			status := m.Status
+			if m.Error != "" {
+				status += ": " + m.Error
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", m.From, m.To, status)
+		}
+		err = w.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	if failed := edit.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d files could not be moved", failed, len(edit.Moves))
+	}
+	return nil
+}
+
+// repinSources changes the name of sources pinned to the old series name.
+func repinSources(old, target string) error {
+	file, err := config.File()
+	if err != nil {
+		return err
+	}
+	_, err = config.RenameSeries(file, old, target)
+	return err
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{seriesRenameCmd, seriesMergeCmd} {
+		seriesCmd.AddCommand(cmd)
+		cmd.Flags().Bool("dry-run", false, "list the files that would be moved without moving them")
+		addOutputFlag(cmd)
+	}
+}
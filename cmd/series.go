@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/library"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// seriesCmd represents the series command
+var seriesCmd = &cobra.Command{
+	Use:   "series",
+	Short: "list the series in the database",
+	Long: `The series command lists every series the database knows about, the id the
+connector uses for it, the name it is saved as and how many chapters have been
+downloaded.
+
+While watch is running it has the database locked, the database is read from
+its api instead so listen has to be set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		snap, err := readSnapshot()
+		if err != nil {
+			return err
+		}
+
+		series, err := listSeries(snap, library.New(viper.GetString("dir")))
+		if err != nil {
+			return err
+		}
+
+		if format == outputJSON {
+			return printJSON(series)
+		}
+		w := newTable("SERIES ID", "NAME", "CHAPTERS", "FILES", "LATEST", "LAST DOWNLOAD")
+		for _, s := range series {
+			latest := "-"
+			if s.Chapters > 0 {
+				latest = fmt.Sprintf("%g", s.Latest)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%s\n", orDash(s.ID), s.Name, s.Chapters, s.Files, latest, formatTime(s.LastDownload))
+		}
+		return w.Flush()
+	},
+}
+
+type seriesInfo struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// Chapters is the number of chapters recorded in the database
+	Chapters int `json:"chapters"`
+	// Files is the number of cbz files in the series folder
+	Files        int       `json:"files"`
+	Latest       float64   `json:"latest,omitempty"`
+	LastDownload time.Time `json:"last_download,omitzero"`
+}
+
+func listSeries(snap *dbSnapshot, lib *library.Library) ([]*seriesInfo, error) {
+	names, books := snap.Series, snap.Books
+
+	series := map[string]*seriesInfo{}
+	for id, name := range names {
+		series[id] = &seriesInfo{ID: id, Name: name}
+	}
+	for _, b := range books {
+		s, ok := series[b.SeriesID]
+		if !ok {
+			s = &seriesInfo{ID: b.SeriesID, Name: b.Series}
+			series[b.SeriesID] = s
+		}
+		s.Chapters++
+		s.Latest = max(s.Latest, b.Chapter)
+		if b.DownloadedAt.After(s.LastDownload) {
+			s.LastDownload = b.DownloadedAt
+		}
+	}
+
+	result := make([]*seriesInfo, 0, len(series))
+	known := map[string]bool{}
+	for _, s := range series {
+		files, err := lib.Books(s.Name)
+		if err != nil && !errors.Is(err, library.ErrNotFound) {
+			return nil, err
+		}
+		s.Files = len(files)
+		known[s.Name] = true
+		result = append(result, s)
+	}
+
+	// folders downloaded before the database tracked series
+	folders, err := lib.Series()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range folders {
+		if known[f.Name] {
+			continue
+		}
+		files, err := lib.Books(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, &seriesInfo{Name: f.Name, Files: len(files)})
+	}
+	slices.SortFunc(result, func(a, b *seriesInfo) int {
+		return cmp.Or(
+			library.NaturalCompare(strings.ToLower(a.Name), strings.ToLower(b.Name)),
+			strings.Compare(a.ID, b.ID),
+		)
+	})
+	return result, nil
+}
+
+func init() {
+	rootCmd.AddCommand(seriesCmd)
+	addOutputFlag(seriesCmd)
+}
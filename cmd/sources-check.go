@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+)
+
+var sourcesCheckCmd = &cobra.Command{
+	Use:   "check [source...]",
+	Short: "check that the sources can be loaded",
+	Long: `The check command loads the chapter list of every source, or only the ones
+passed, without downloading anything and reports the sources that are broken.
+It exits with an error if any source is broken.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		sources := []*site.Source{}
+		if len(args) > 0 {
+			for _, arg := range args {
+				src, err := findSource(arg)
+				if err != nil {
+					return err
+				}
+				sources = append(sources, src)
+			}
+		} else {
+			sources, err = config.Sources()
+			if err != nil {
+				return err
+			}
+		}
+
+		results := make([]*sourceCheck, len(sources))
+		for i, src := range sources {
+			results[i] = checkSource(src)
+		}
+
+		broken := 0
+		for _, r := range results {
+			if !r.OK {
+				broken++
+			}
+		}
+
+		if format == outputJSON {
+			err = printJSON(results)
+		} else {
+			w := newTable("NAME", "CONNECTOR", "STATUS", "CHAPTERS", "LATEST", "ERROR")
+			for _, r := range results {
+				status := "ok"
+				if !r.OK {
+					status = "broken"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%g\t%s\n",
+					orDash(r.Name),
+					orDash(r.Connector),
+					status,
+					r.Chapters,
+					r.Latest,
+					orDash(truncate(r.Error, 80)),
+				)
+			}
+			err = w.Flush()
+		}
+		if err != nil {
+			return err
+		}
+		if broken > 0 {
+			return fmt.Errorf("%d of %d sources are broken", broken, len(results))
+		}
+		return nil
+	},
+}
+
+type sourceCheck struct {
+	Name      string  `json:"name"`
+	URL       string  `json:"url"`
+	Connector string  `json:"connector,omitempty"`
+	OK        bool    `json:"ok"`
+	Chapters  int     `json:"chapters"`
+	Latest    float64 `json:"latest"`
+	Error     string  `json:"error,omitempty"`
+}
+
+func checkSource(src *site.Source) *sourceCheck {
+	check := &sourceCheck{Name: src.Name, URL: src.URL}
+	if check.Name == "" {
+		check.Name = src.URL
+	}
+	urls := []string{src.URL}
+	if src.IsSeries() {
+		urls = src.Sources
+	}
+
+	connectors := []string{}
+	errs := []string{}
+	for _, url := range urls {
+		connector, err := findConnector(url)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		connectors = append(connectors, connector.SiteName())
+
+		books, err := connector.Books(url)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		check.Chapters += len(books)
+		for _, b := range books {
+			check.Latest = max(check.Latest, b.Chapter())
+		}
+	}
+	check.Connector = strings.Join(connectors, ", ")
+	check.Error = strings.Join(errs, ", ")
+	check.OK = len(errs) == 0
+	return check
+}
+
+func init() {
+	sourcesCmd.AddCommand(sourcesCheckCmd)
+	addOutputFlag(sourcesCheckCmd)
+}
@@ -0,0 +1,305 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var sourcesAddCmd = &cobra.Command{
+	Use:   "add <url>",
+	Short: "add a source to the config file",
+	Long: `The add command checks that a connector can download the url, looks up the
+series name and adds the source to the config file. The series name is pinned
+in the config so the folder doesn't change if the site renames the series.
+
+--from can be a chapter number, latest to start at the newest chapter or next
+to only download chapters released from now on.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src := &site.Source{URL: strings.TrimSpace(args[0])}
+		connector, err := findConnector(src.URL)
+		if err != nil {
+			return err
+		}
+		err = applySourceFlags(cmd.Flags(), src)
+		if err != nil {
+			return err
+		}
+
+		from, _ := cmd.Flags().GetString("from")
+		if src.Name == "" || !isNumber(from) {
+			books, err := connector.Books(src.URL)
+			if err != nil {
+				return fmt.Errorf("could not load %s: %w", src.URL, err)
+			}
+			if src.Name == "" {
+				src.Name, err = seriesName(books)
+				if err != nil {
+					return err
+				}
+			}
+			src.From, err = parseFrom(from, books)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = config.ValidateSource(src, config.WatchDefaults())
+		if err != nil {
+			return err
+		}
+		file, err := config.File()
+		if err != nil {
+			return err
+		}
+		err = config.AddSource(file, src)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("added %s (%s) from chapter %g\n", src.Name, src.URL, src.From)
+		return nil
+	},
+}
+
+var sourcesRemoveCmd = &cobra.Command{
+	Use:     "remove <source>",
+	Aliases: []string{"rm"},
+	Short:   "remove a source from the config file",
+	Long: `The remove command removes a source from the config file. The source can be
+its url, id or name. A series is removed with its name or the url of its first
+source. Downloaded books are not deleted.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := findSource(args[0])
+		if err != nil {
+			return err
+		}
+		file, err := config.File()
+		if err != nil {
+			return err
+		}
+		err = config.RemoveSource(file, src.URL)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %s\n", src.URL)
+		return nil
+	},
+}
+
+var sourcesEditCmd = &cobra.Command{
+	Use:   "edit <source>",
+	Short: "change the settings of a source in the config file",
+	Long: `The edit command changes the settings of a source in the config file. The
+source can be its url, id or name. Only the flags that are passed are changed,
+pass an empty value to remove a setting.
+
+A series is edited with its name or the url of its first source, --url can't
+be used on it since a series has several urls.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		src, err := findSource(args[0])
+		if err != nil {
+			return err
+		}
+		url := src.URL
+		if len(src.Sources) > 0 && cmd.Flags().Changed("url") {
+			return fmt.Errorf("%s is a series, its sources can only be changed in the config file", src.Name)
+		}
+		if cmd.Flags().Changed("url") {
+			src.URL, _ = cmd.Flags().GetString("url")
+		}
+		err = applySourceFlags(cmd.Flags(), src)
+		if err != nil {
+			return err
+		}
+
+		pin, _ := cmd.Flags().GetBool("pin")
+		from, _ := cmd.Flags().GetString("from")
+		if pin || (cmd.Flags().Changed("from") && !isNumber(from)) {
+			connector, err := findConnector(src.URL)
+			if err != nil {
+				return err
+			}
+			books, err := connector.Books(src.URL)
+			if err != nil {
+				return fmt.Errorf("could not load %s: %w", src.URL, err)
+			}
+			if pin {
+				src.Name, err = seriesName(books)
+				if err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("from") {
+				src.From, err = parseFrom(from, books)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		err = config.ValidateSource(src, config.WatchDefaults())
+		if err != nil {
+			return err
+		}
+		file, err := config.File()
+		if err != nil {
+			return err
+		}
+		err = config.UpdateSource(file, url, src)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("updated %s\n", src.URL)
+		return nil
+	},
+}
+
+// applySourceFlags copies the flags that were passed onto src
+func applySourceFlags(flags *pflag.FlagSet, src *site.Source) error {
+	var err error
+	if flags.Changed("name") {
+		src.Name, _ = flags.GetString("name")
+	}
+	if flags.Changed("from") {
+		from, _ := flags.GetString("from")
+		if isNumber(from) {
+			src.From, _ = strconv.ParseFloat(from, 64)
+		}
+	}
+	if flags.Changed("frequency") {
+		frequency, _ := flags.GetString("frequency")
+		src.Frequency = 0
+		if frequency != "" {
+			src.Frequency, err = time.ParseDuration(frequency)
+			if err != nil {
+				return fmt.Errorf("invalid frequency: %w", err)
+			}
+		}
+	}
+	if flags.Changed("schedule") {
+		src.Schedule, _ = flags.GetString("schedule")
+	}
+	if flags.Changed("quiet-hours") {
+		src.QuietHours, _ = flags.GetString("quiet-hours")
+	}
+	if flags.Changed("notify") {
+		notify, _ := flags.GetBool("notify")
+		src.Notify = nil
+		if !notify {
+			src.Notify = &notify
+		}
+	}
+	return nil
+}
+
+func addSourceFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "the series name, it is looked up from the site if it isn't set")
+	cmd.Flags().StringP("from", "f", "", "the chapter to start downloading, a number, latest or next")
+	cmd.Flags().String("frequency", "", "how often to check the source, overrides watch.frequency")
+	cmd.Flags().String("schedule", "", "a cron expression to check the source on")
+	cmd.Flags().String("quiet-hours", "", "a time range like 23:00-07:00 the source won't run in")
+	cmd.Flags().Bool("notify", true, "send notifications for new chapters")
+}
+
+func findConnector(url string) (site.MangaSite, error) {
+	connector, ok := site.FindSite(url)
+	if !ok {
+		return nil, fmt.Errorf("no connector can download %s, the installed connectors are %s", url, strings.Join(site.ConnectorNames(), ", "))
+	}
+	return connector, nil
+}
+
+// findSource looks up a source in the config by url, id or name. The config
+// isn't validated so broken sources can still be edited or removed.
+func findSource(query string) (*site.Source, error) {
+	sources, err := config.Sources()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sources {
+		if s.URL == query || s.ID() == query {
+			return s, nil
+		}
+	}
+	for _, s := range sources {
+		if s.Name != "" && strings.EqualFold(s.Name, query) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("no source matches %s, see manga sources for the configured sources", query)
+}
+
+// seriesName returns the name the series would be saved as, an existing name
+// in the database is used so the folder stays the same.
+func seriesName(books []site.Book) (string, error) {
+	if len(books) == 0 {
+		return "", fmt.Errorf("the source has no chapters to take the series name from, set it with --name")
+	}
+	book := books[0]
+
+	db, err := openDBReadOnly()
+	if err != nil {
+		slog.Warn("Could not check the database for the series name", "err", err)
+		return book.Series(), nil
+	}
+	defer db.Close()
+
+	names, err := db.AllSeries()
+	if err != nil {
+		return "", err
+	}
+	if name, ok := names[book.SeriesID()]; ok {
+		return name, nil
+	}
+	return book.Series(), nil
+}
+
+func parseFrom(from string, books []site.Book) (float64, error) {
+	latest := 0.0
+	for _, b := range books {
+		latest = max(latest, b.Chapter())
+	}
+	switch from {
+	case "":
+		return 0, nil
+	case "latest":
+		return latest, nil
+	case "next":
+		// the next whole chapter, point chapters of the latest are skipped
+		return float64(int(latest) + 1), nil
+	}
+	f, err := strconv.ParseFloat(from, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid from %q, must be a number, latest or next", from)
+	}
+	return f, nil
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func init() {
+	sourcesCmd.AddCommand(sourcesAddCmd)
+	sourcesCmd.AddCommand(sourcesRemoveCmd)
+	sourcesCmd.AddCommand(sourcesEditCmd)
+
+	addSourceFlags(sourcesAddCmd)
+	addSourceFlags(sourcesEditCmd)
+	sourcesEditCmd.Flags().String("url", "", "change the url of the source")
+	sourcesEditCmd.Flags().Bool("pin", false, "look up the series name and pin it in the config")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// sourcesCmd represents the sources command
+var sourcesCmd = &cobra.Command{
+	Use:   "sources",
+	Short: "list the configured sources",
+	Long: `The sources command lists the sources in the config with their connector and
+when watch last ran them, the last error and when they will run next.
+
+While watch is running it has the database locked, the state is read from its
+api instead so listen has to be set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		sources, err := listSources(cfg)
+		if err != nil {
+			return err
+		}
+
+		if format == outputJSON {
+			return printJSON(sources)
+		}
+		w := newTable("NAME", "CONNECTOR", "LAST RUN", "NEXT RUN", "LAST ERROR", "URL")
+		for _, s := range sources {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				orDash(s.Name),
+				orDash(s.Connector),
+				formatTime(s.LastRun),
+				formatTime(s.NextRun),
+				orDash(truncate(s.LastError, 60)),
+				s.URL,
+			)
+		}
+		return w.Flush()
+	},
+}
+
+type sourceInfo struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	URL         string    `json:"url"`
+	Connector   string    `json:"connector,omitempty"`
+	LastRun     time.Time `json:"last_run,omitzero"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	NextRun     time.Time `json:"next_run,omitzero"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// listSources reads the state of the sources from the database, or from the
+// api of watch while it has the database locked.
+func listSources(cfg *config.Config) ([]*sourceInfo, error) {
+	db, err := openDBReadOnly()
+	if site.IsLocked(err) && viper.GetString("listen") != "" {
+		sources := []*sourceInfo{}
+		err = getAPI("/api/sources", &sources)
+		if err != nil {
+			return nil, err
+		}
+		return sources, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sources := make([]*sourceInfo, len(cfg.Sources))
+	for i, src := range cfg.Sources {
+		state, err := db.SourceState(src.URL)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = newSourceInfo(src, state)
+	}
+	return sources, nil
+}
+
+func newSourceInfo(src *site.Source, state *site.SourceState) *sourceInfo {
+	info := &sourceInfo{
+		ID:          src.ID(),
+		Name:        src.Name,
+		URL:         src.URL,
+		LastRun:     state.LastRun,
+		LastSuccess: state.LastSuccess,
+		NextRun:     state.NextRun,
+		LastError:   state.LastError,
+	}
+	if connector, ok := site.FindSite(src.URL); ok {
+		info.Connector = connector.SiteName()
+	}
+	return info
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+func init() {
+	rootCmd.AddCommand(sourcesCmd)
+	addOutputFlag(sourcesCmd)
+}
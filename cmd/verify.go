@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify [series...]",
+	Short: "check the books in the library for damage",
+	Long: `The verify command opens every book in the library, or only the books in the
+series folders passed, and checks that:
+
+  - every file in the archive matches its checksum
+  - every page is an image that can be decoded
+  - the page count and page sizes match book.json
+  - the book has a cover
+
+With --repair broken books are downloaded again from the connector they were
+downloaded from, using the book id saved in the database or in book.json. The
+old file is only replaced once the new one has been downloaded.
+
+It exits with an error if any problems are left.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := outputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		repair, _ := cmd.Flags().GetBool("repair")
+
+		lib := library.New(viper.GetString("dir"))
+		books := []*library.Book{}
+		if len(args) > 0 {
+			for _, series := range args {
+				b, err := lib.Books(series)
+				if err != nil {
+					return err
+				}
+				books = append(books, b...)
+			}
+		} else {
+			books, err = lib.AllBooks()
+			if err != nil {
+				return err
+			}
+		}
+
+		var db *site.DB
+		if repair {
+			naming, err := configNaming()
+			if err != nil {
+				return err
+			}
+			site.SetNaming(naming)
+			err = setConfigProfiles()
+			if err != nil {
+				return err
+			}
+			db, err = site.OpenDB(viper.GetString("database"))
+			if err != nil {
+				return err
+			}
+		} else {
+			db, err = openDBReadOnly()
+			if err != nil {
+				return err
+			}
+		}
+		defer db.Close()
+
+		results := []*verifyResult{}
+		for _, b := range books {
+			problems := b.Verify()
+			if len(problems) == 0 {
+				continue
+			}
+			r := &verifyResult{File: b.RelPath(), Problems: problems}
+			if repair {
+				repairBook(db, lib, b, r)
+			}
+			results = append(results, r)
+		}
+
+		broken := 0
+		for _, r := range results {
+			if !r.Repaired {
+				broken++
+			}
+		}
+
+		if format == outputJSON {
+			err = printJSON(results)
+		} else {
+			w := newTable("FILE", "PROBLEM")
+			for _, r := range results {
+				for _, p := range r.Problems {
+					fmt.Fprintf(w, "%s\t%s\n", r.File, p)
+				}
+				if r.Repaired {
+					fmt.Fprintf(w, "%s\trepaired\n", r.File)
+				} else if r.RepairError != "" {
+					fmt.Fprintf(w, "%s\tcould not repair: %s\n", r.File, r.RepairError)
+				}
+			}
+			err = w.Flush()
+			if err == nil {
+				fmt.Printf("\nchecked %d books, %d with problems, %d repaired\n", len(books), len(results), len(results)-broken)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if broken > 0 {
+			return fmt.Errorf("%d of %d books have problems", broken, len(books))
+		}
+		return nil
+	},
+}
+
+type verifyResult struct {
+	File        string             `json:"file"`
+	Problems    []*library.Problem `json:"problems"`
+	Repaired    bool               `json:"repaired"`
+	RepairError string             `json:"repair_error,omitempty"`
+}
+
+// repairBook downloads a broken book again and checks the new file.
+func repairBook(db *site.DB, lib *library.Library, b *library.Book, r *verifyResult) {
+	bookID, sourceURL, err := bookOrigin(db, b)
+	if err != nil {
+		r.RepairError = err.Error()
+		return
+	}
+
+	slog.Info("Downloading book again", "file", r.File, "source", sourceURL, "id", bookID)
+	// the series is pinned to the folder the book is in so it is downloaded
+	// back into the same place
+	src := &site.Source{URL: sourceURL, Name: b.Series}
+	file, err := site.Redownload(db, lib.Dir(), src, bookID)
+	if err != nil {
+		r.RepairError = err.Error()
+		return
+	}
+	if filepath.Clean(file) != filepath.Clean(b.Path) {
+		// the naming template has changed since the book was downloaded
+		err = os.Remove(b.Path)
+		if err != nil {
+			slog.Warn("Could not remove the old file", "file", b.Path, "err", err)
+		}
+	}
+
+	problems := (&library.Book{Path: file}).Verify()
+	if len(problems) > 0 {
+		r.RepairError = fmt.Sprintf("the new download has problems: %s", problems[0])
+		return
+	}
+	r.Repaired = true
+}
+
+// bookOrigin returns the book id and source url a book was downloaded with,
+// from the database or from book.json in the archive.
+func bookOrigin(db *site.DB, b *library.Book) (string, string, error) {
+	rec, err := db.Book(b.RelPath())
+	if err != nil {
+		return "", "", err
+	}
+	if rec != nil && rec.BookID != "" && rec.Source != "" {
+		return rec.BookID, rec.Source, nil
+	}
+
+	a, err := b.Open()
+	if err != nil {
+		return "", "", fmt.Errorf("not in the database and the archive can't be opened: %w", err)
+	}
+	defer a.Close()
+	info, err := a.Info()
+	if err != nil {
+		return "", "", fmt.Errorf("not in the database and book.json can't be read: %w", err)
+	}
+	if info == nil || info.ID == "" || info.Source == "" {
+		return "", "", fmt.Errorf("not in the database and book.json has no book id")
+	}
+	return info.ID, info.Source, nil
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+	verifyCmd.Flags().Bool("repair", false, "download broken books again")
+	addOutputFlag(verifyCmd)
+}
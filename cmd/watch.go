@@ -4,10 +4,29 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"log/slog"
-	"runtime/debug"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/server"
+	"github.com/abibby/manga/server/opds"
+	"github.com/abibby/manga/server/reader"
+	"github.com/abibby/manga/services/hooks"
+	"github.com/abibby/manga/services/metrics"
+	"github.com/abibby/manga/services/notify"
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/abibby/manga/site"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
@@ -15,54 +34,242 @@ import (
 // watchCmd represents the serve command
 var watchCmd = &cobra.Command{
 	Use:   "watch",
-	Short: "",
-	Long:  ``,
+	Short: "download sources on a schedule",
+	Long: `The watch command keeps running and downloads each source on its own schedule.
+Sources use watch.frequency unless they set their own frequency or cron style
+schedule, and can set quiet_hours to avoid running at night.
+
+Changes to the config file are applied without a restart, an invalid config is
+logged and ignored, connectors keep using the last good settings.
+
+After each run the hooks ask Komga, Kavita, Jellyfin or a command to pick up the
+series folders that changed, then the new books are sent to the notify targets.
+Sources that fail notify.failure_threshold runs in a row send an alert.
+
+With --listen a JSON API is served that lists sources, series and downloaded
+books and shows download progress. Triggering syncs and adding or removing
+sources need the api_token from the config as a bearer token. The library is
+also served as an OPDS catalogue at /opds for e-readers and as a web reader at
+/reader. Prometheus metrics are served from /metrics and /healthz
+reports if the watcher is healthy.
+
+With --dry-run the books every source would download are listed and watch
+exits without downloading anything.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		for {
-			frequency := viper.GetDuration("watch.frequency")
-			if frequency == 0 {
-				frequency = time.Hour
-			}
-			start := time.Now()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if dry, _ := cmd.Flags().GetBool("dry-run"); dry {
+			return dryRun(cmd, cfg.Dir, cfg.Sources)
+		}
+		naming, err := site.ParseNaming(cfg.Naming)
+		if err != nil {
+			return err
+		}
+		site.SetNaming(naming)
+		site.SetProfiles(cfg.Profiles, cfg.Profile)
+
+		current := &atomic.Pointer[config.Config]{}
+		current.Store(cfg)
+
+		db, err := site.OpenDB(cfg.Database)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
 
-			watchDownload()
+		notifier, err := notify.New(db, cfg.Notify, notify.Links{
+			Cover: func(series, book string) string {
+				return opds.BookPath(series, book) + "/cover"
+			},
+			Read: reader.ReadPath,
+		})
+		if err != nil {
+			return err
+		}
+
+		libraryHooks, err := hooks.New(cfg.Hooks, cfg.Dir)
+		if err != nil {
+			return err
+		}
+
+		var m *metrics.Metrics
+		s := scheduler.New(db, func(src *site.Source) error {
+			slog.Info("Downloading source", "url", src.URL)
+			err := site.Download(db, current.Load().Dir, src)
+			libraryHooks.Flush(src)
+			notifier.Flush(src, err)
+			m.RecordRun(src, err)
+			return err
+		})
+		m = metrics.New(s)
 
-			timeToNextRun := frequency - time.Duration(time.Now().Unix()%int64(frequency/time.Second))*time.Second
-			if timeToNextRun == 0 {
-				timeToNextRun += frequency
+		err = s.SetSources(cfg.Sources, cfg.Watch)
+		if err != nil {
+			return err
+		}
+
+		// editors often write the file in several steps, wait for the
+		// writes to settle before reading it
+		var reloadMtx sync.Mutex
+		var reload *time.Timer
+		if listen := viper.GetString("listen"); listen != "" {
+			lib := func() *library.Library {
+				return library.New(current.Load().Dir)
+			}
+			handler := server.New(db, s, current.Load)
+			handler.Handle(opds.Prefix+"/", opds.New(db, lib))
+			handler.Handle(reader.Prefix+"/", reader.New(db, lib))
+			handler.Handle("GET /metrics", m.Handler())
+			handler.Handle("GET /{$}", http.RedirectHandler(reader.Prefix+"/", http.StatusFound))
+			srv := &http.Server{
+				Addr:    listen,
+				Handler: handler,
 			}
-			slog.Info("Download complete",
-				"duration", time.Since(start).Truncate(time.Millisecond),
-				"next", time.Now().Add(timeToNextRun).Truncate(time.Second),
-			)
-			time.Sleep(timeToNextRun)
+			go func() {
+				slog.Info("Listening", "addr", listen)
+				err := srv.ListenAndServe()
+				if err != nil && err != http.ErrServerClosed {
+					slog.Error("Server failed", "err", err)
+					stop()
+				}
+			}()
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = srv.Shutdown(ctx)
+			}()
 		}
+
+		if file := viper.ConfigFileUsed(); file != "" {
+			err = watchConfigFile(ctx, file, func() {
+				reloadMtx.Lock()
+				defer reloadMtx.Unlock()
+				if reload != nil {
+					reload.Stop()
+				}
+				reload = time.AfterFunc(500*time.Millisecond, func() {
+					slog.Info("Config file changed", "file", file)
+					reloadConfig(current, s, notifier, libraryHooks)
+				})
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		err = s.Run(ctx)
+		if err == context.Canceled {
+			return nil
+		}
+		return err
 	},
 }
 
-func watchDownload() {
-	defer func() {
-		err := recover()
-		if err == nil {
-			return
+// watchConfigFile calls changed whenever the config file is written. The
+// folder is watched since editors often replace the file instead of writing
+// to it. viper.WatchConfig isn't used because it reads the new file into
+// viper before it can be checked.
+func watchConfigFile(ctx context.Context, file string, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	file = filepath.Clean(file)
+	err = watcher.Add(filepath.Dir(file))
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(e.Name) == file && e.Has(fsnotify.Write|fsnotify.Create) {
+					changed()
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("Config file watcher failed", "err", err)
+			}
 		}
-		slog.Error("Download failed due to panic", "err", err, "stack", debug.Stack())
 	}()
-	err := download(nil)
+	return nil
+}
+
+// reloadConfig applies a changed config file, if the new config is invalid
+// the last good config is kept. Connectors read their settings from viper so
+// the file is only read into viper once it is known to be good.
+func reloadConfig(current *atomic.Pointer[config.Config], s *scheduler.Scheduler, notifier *notify.Service, libraryHooks *hooks.Service) {
+	b, err := os.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		slog.Error("Could not read config, keeping the last good config", "err", err)
+		return
+	}
+	next, err := readConfig(b)
+	if err != nil {
+		slog.Error("Invalid config, keeping the last good config", "err", err)
+		return
+	}
+	cfg, err := config.LoadFrom(next)
+	if err != nil {
+		slog.Error("Invalid config, keeping the last good config", "err", err)
+		return
+	}
+
+	old := current.Load()
+	if old.Database != cfg.Database {
+		slog.Warn("Changing the database requires a restart", "database", old.Database)
+		cfg.Database = old.Database
+	}
+
+	naming, err := site.ParseNaming(cfg.Naming)
+	if err != nil {
+		slog.Error("Invalid config, keeping the last good config", "err", err)
+		return
+	}
+	err = s.SetSources(cfg.Sources, cfg.Watch)
+	if err != nil {
+		slog.Error("Invalid config, keeping the last good config", "err", err)
+		return
+	}
+	err = viper.ReadConfig(bytes.NewReader(b))
 	if err != nil {
-		slog.Error("Download failed", "err", err)
+		slog.Error("Could not apply config to connectors", "err", err)
 	}
+	site.SetNaming(naming)
+	site.SetProfiles(cfg.Profiles, cfg.Profile)
+	err = notifier.SetConfig(cfg.Notify)
+	if err != nil {
+		slog.Error("Invalid notify config, keeping the last good notify config", "err", err)
+	}
+	err = libraryHooks.SetConfig(cfg.Hooks, cfg.Dir)
+	if err != nil {
+		slog.Error("Invalid hooks, keeping the last good hooks", "err", err)
+	}
+	current.Store(cfg)
+	slog.Info("Config reloaded", "sources", len(cfg.Sources))
 }
+
 func init() {
 	rootCmd.AddCommand(watchCmd)
 
-	// Here you will define your flags and configuration settings.
-
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// serveCmd.PersistentFlags().String("foo", "", "A help for foo")
+	watchCmd.Flags().String("listen", "", "the address to serve the http api on, e.g. :8080")
+	addDryRunFlags(watchCmd)
+	mustBindFlag("listen", watchCmd.Flags().Lookup("listen"))
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// serveCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	setDefault("watch.frequency", time.Hour)
+	setDefault("watch.jitter", 5*time.Minute)
 }
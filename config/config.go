@@ -0,0 +1,267 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/imaging"
+	"github.com/abibby/manga/services/hooks"
+	"github.com/abibby/manga/services/notify"
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/abibby/manga/site"
+	"github.com/go-viper/mapstructure/v2"
+	"github.com/spf13/viper"
+)
+
+// Config is a validated snapshot of the settings the watcher uses. The
+// watcher keeps using the last good Config when the config file is changed to
+// something invalid.
+type Config struct {
+	Dir      string
+	Database string
+	// Naming is the template for book file names, see site.ParseNaming
+	Naming  string
+	Sources []*site.Source
+	Watch   scheduler.Defaults
+	Notify  notify.Config
+	Hooks   []*hooks.Hook
+	// Profiles are the named image profiles, Profile is the one sources use
+	// unless they pick another
+	Profiles map[string]*imaging.Profile
+	Profile  string
+	// APIToken must be sent as a bearer token to the api endpoints that
+	// change the config or start downloads, they are disabled without it
+	APIToken string
+}
+
+// Load reads the config from viper and validates it.
+func Load() (*Config, error) {
+	return LoadFrom(viper.GetViper())
+}
+
+// LoadFrom reads the config from v and validates it, it lets a changed config
+// file be checked before it replaces the global viper config.
+func LoadFrom(v *viper.Viper) (*Config, error) {
+	cfg := &Config{
+		Dir:      v.GetString("dir"),
+		Database: v.GetString("database"),
+		Naming:   v.GetString("naming"),
+		Sources:  []*site.Source{},
+		Watch:    watchDefaults(v),
+		Profile:  v.GetString("profile"),
+		APIToken: v.GetString("api_token"),
+	}
+	var err error
+	cfg.Sources, err = sources(v)
+	if err != nil {
+		return nil, err
+	}
+	err = v.UnmarshalKey("notify", &cfg.Notify)
+	if err != nil {
+		return nil, fmt.Errorf("invalid notify: %w", err)
+	}
+	err = v.UnmarshalKey("hooks", &cfg.Hooks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hooks: %w", err)
+	}
+	cfg.Profiles, err = profiles(v)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Sources reads the sources from the config without validating them. Series
+// followed on several sites are returned after the sources with URL set to
+// their first source.
+func Sources() ([]*site.Source, error) {
+	return sources(viper.GetViper())
+}
+
+func sources(v *viper.Viper) ([]*site.Source, error) {
+	hook := viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		dateToStringHook,
+	))
+	sources := []*site.Source{}
+	err := v.UnmarshalKey("sources", &sources, hook)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sources: %w", err)
+	}
+
+	series := []*site.Source{}
+	err = v.UnmarshalKey("series", &series, hook)
+	if err != nil {
+		return nil, fmt.Errorf("invalid series: %w", err)
+	}
+	for _, s := range series {
+		if len(s.Sources) > 0 {
+			s.URL = s.Sources[0]
+		}
+	}
+	return append(sources, series...), nil
+}
+
+// Profiles reads the image profiles from the config without validating
+// them.
+func Profiles() (map[string]*imaging.Profile, error) {
+	return profiles(viper.GetViper())
+}
+
+func profiles(v *viper.Viper) (map[string]*imaging.Profile, error) {
+	profiles := map[string]*imaging.Profile{}
+	err := v.UnmarshalKey("profiles", &profiles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// dateToStringHook turns unquoted yaml dates like released_after: 2024-01-31
+// back into strings.
+func dateToStringHook(from, to reflect.Type, data any) (any, error) {
+	t, ok := data.(time.Time)
+	if !ok || to.Kind() != reflect.String {
+		return data, nil
+	}
+	if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 {
+		return t.Format(time.DateOnly), nil
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+// WatchDefaults returns the schedule settings sources use unless they
+// override them.
+func WatchDefaults() scheduler.Defaults {
+	return watchDefaults(viper.GetViper())
+}
+
+func watchDefaults(v *viper.Viper) scheduler.Defaults {
+	return scheduler.Defaults{
+		Frequency:  v.GetDuration("watch.frequency"),
+		Jitter:     v.GetDuration("watch.jitter"),
+		QuietHours: v.GetString("watch.quiet_hours"),
+	}
+}
+
+// Validate checks that every source can be downloaded and has a valid
+// schedule.
+func (c *Config) Validate() error {
+	errs := []error{}
+	if c.Dir == "" {
+		errs = append(errs, fmt.Errorf("must set dir in the config"))
+	}
+	if c.Database == "" {
+		errs = append(errs, fmt.Errorf("must set database in the config"))
+	}
+
+	_, err := site.ParseNaming(c.Naming)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	err = ValidateProfiles(c.Profiles, c.Profile)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	urls := map[string]bool{}
+	for i, s := range c.Sources {
+		err := ValidateSource(s, c.Watch)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sources[%d]: %w", i, err))
+			continue
+		}
+		if !hasProfile(c.Profiles, s.Profile) {
+			errs = append(errs, fmt.Errorf("sources[%d]: %s: unknown profile %s", i, s.URL, s.Profile))
+		}
+		for _, url := range sourceURLs(s) {
+			if urls[url] {
+				errs = append(errs, fmt.Errorf("sources[%d]: duplicate source %s", i, url))
+			}
+			urls[url] = true
+		}
+	}
+	err = c.Notify.Validate()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	err = hooks.Validate(c.Hooks)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateSource checks that a connector matches the source url and that its
+// schedule and chapter selection can be parsed.
+func ValidateSource(s *site.Source, defaults scheduler.Defaults) error {
+	if s == nil || s.URL == "" {
+		return fmt.Errorf("missing url")
+	}
+	if s.IsSeries() && s.Name == "" {
+		return fmt.Errorf("%s: a series with several sources must have a name", s.URL)
+	}
+	for _, url := range sourceURLs(s) {
+		if _, ok := site.FindSite(url); !ok {
+			return fmt.Errorf("no site that matches %s", url)
+		}
+	}
+	_, err := scheduler.SourceSchedule(s, defaults)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.URL, err)
+	}
+	err = s.ValidateSelection()
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.URL, err)
+	}
+	return nil
+}
+
+// ValidateProfiles checks every profile and that the default profile exists.
+func ValidateProfiles(profiles map[string]*imaging.Profile, def string) error {
+	errs := []error{}
+	for name, p := range profiles {
+		if name == site.ProfileNone {
+			errs = append(errs, fmt.Errorf("profiles: %s is reserved for sources that don't use a profile", name))
+			continue
+		}
+		if p == nil {
+			continue
+		}
+		err := p.Validate()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("profiles.%s: %w", name, err))
+		}
+	}
+	if !hasProfile(profiles, def) {
+		errs = append(errs, fmt.Errorf("unknown profile %s", def))
+	}
+	return errors.Join(errs...)
+}
+
+func hasProfile(profiles map[string]*imaging.Profile, name string) bool {
+	if name == "" || strings.EqualFold(name, site.ProfileNone) {
+		return true
+	}
+	// viper lower cases keys
+	_, ok := profiles[strings.ToLower(name)]
+	return ok
+}
+
+// sourceURLs returns every url a source downloads from.
+func sourceURLs(s *site.Source) []string {
+	if len(s.Sources) > 0 {
+		return s.Sources
+	}
+	return []string{s.URL}
+}
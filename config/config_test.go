@@ -0,0 +1,184 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/config"
+	_ "github.com/abibby/manga/connectors/mangadex"
+	_ "github.com/abibby/manga/connectors/mangaplus"
+	_ "github.com/abibby/manga/connectors/viz"
+	"github.com/abibby/manga/imaging"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSources_selection(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+sources:
+  - url: https://mangaplus.shueisha.co.jp/titles/100020
+    from: 10.5
+    to: 20
+    chapters: 12
+    volumes: 1-3
+    latest: 5
+    skip_extras: true
+    released_after: 2024-01-31
+`))
+	require.NoError(t, err)
+
+	sources, err := config.Sources()
+	require.NoError(t, err)
+
+	require.Len(t, sources, 1)
+	assert.Equal(t, &site.Source{
+		URL:           "https://mangaplus.shueisha.co.jp/titles/100020",
+		From:          10.5,
+		To:            20,
+		Chapters:      "12",
+		Volumes:       "1-3",
+		Latest:        5,
+		SkipExtras:    true,
+		ReleasedAfter: "2024-01-31",
+	}, sources[0])
+}
+
+func TestSources_bundle(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+sources:
+  - url: https://mangaplus.shueisha.co.jp/titles/100020
+    bundle: true
+    bundle_delete: true
+    volume_map:
+      1: 1-8
+      2: 9
+`))
+	require.NoError(t, err)
+
+	sources, err := config.Sources()
+	require.NoError(t, err)
+
+	require.Len(t, sources, 1)
+	assert.True(t, sources[0].Bundle)
+	assert.True(t, sources[0].BundleDelete)
+	assert.Equal(t, map[int]site.Ranges{1: "1-8", 2: "9"}, sources[0].VolumeMap)
+	assert.NoError(t, sources[0].ValidateSelection())
+
+	sources[0].VolumeMap[3] = "x"
+	assert.ErrorContains(t, sources[0].ValidateSelection(), "volume_map 3")
+}
+
+func TestSources_series(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+sources:
+  - url: https://mangadex.org/titles/feed
+series:
+  - name: One Piece
+    sources:
+      - https://mangaplus.shueisha.co.jp/titles/100020
+      - https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f
+    upgrade: true
+    frequency: 6h
+`))
+	require.NoError(t, err)
+
+	sources, err := config.Sources()
+	require.NoError(t, err)
+
+	require.Len(t, sources, 2)
+	assert.Equal(t, "https://mangadex.org/titles/feed", sources[0].URL)
+	assert.Equal(t, &site.Source{
+		Name: "One Piece",
+		URL:  "https://mangaplus.shueisha.co.jp/titles/100020",
+		Sources: []string{
+			"https://mangaplus.shueisha.co.jp/titles/100020",
+			"https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f",
+		},
+		Upgrade:   true,
+		Frequency: 6 * time.Hour,
+	}, sources[1])
+
+	sources[1].Name = ""
+	err = config.ValidateSource(sources[1], config.WatchDefaults())
+	assert.ErrorContains(t, err, "must have a name")
+}
+
+func TestProfiles(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigType("yaml")
+	err := viper.ReadConfig(strings.NewReader(`
+profile: Kobo
+profiles:
+  Kobo:
+    device: kobo-libra
+    grayscale: true
+    dither: true
+    quality: 80
+    convert: jpeg
+  broken:
+    device: nook
+`))
+	require.NoError(t, err)
+
+	profiles, err := config.Profiles()
+	require.NoError(t, err)
+
+	assert.Equal(t, &imaging.Profile{
+		Device:    "kobo-libra",
+		Grayscale: true,
+		Dither:    true,
+		Quality:   80,
+		Convert:   "jpeg",
+	}, profiles["kobo"])
+
+	err = config.ValidateProfiles(profiles, viper.GetString("profile"))
+	assert.ErrorContains(t, err, "profiles.broken")
+	assert.NotContains(t, err.Error(), "unknown profile")
+
+	delete(profiles, "broken")
+	assert.NoError(t, config.ValidateProfiles(profiles, "none"))
+	assert.ErrorContains(t, config.ValidateProfiles(profiles, "kindle"), "unknown profile kindle")
+}
+
+func TestLoadFrom(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("dir", "/old")
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(`
+dir: /manga
+database: /manga.db
+profile: missing
+`)))
+	_, err := config.LoadFrom(v)
+	assert.ErrorContains(t, err, "unknown profile missing")
+
+	v.Set("profile", "")
+	cfg, err := config.LoadFrom(v)
+	require.NoError(t, err)
+	assert.Equal(t, "/manga", cfg.Dir)
+	assert.Equal(t, "/old", viper.GetString("dir"))
+}
+
+func TestLoadFrom_example(t *testing.T) {
+	v := viper.New()
+	v.SetConfigFile("../config.example.yaml")
+	require.NoError(t, v.ReadInConfig())
+	_, err := config.LoadFrom(v)
+	require.NoError(t, err)
+}
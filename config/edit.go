@@ -0,0 +1,347 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/abibby/manga/site"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrSourceExists is returned when adding a url that a source or series
+// already downloads from.
+var ErrSourceExists = errors.New("source already exists")
+
+// File returns the path of the config file in use.
+func File() (string, error) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		return "", fmt.Errorf("no config file is in use")
+	}
+	return file, nil
+}
+
+// yamlSource controls how a source is written to the config file.
+type yamlSource struct {
+	Name           string  `yaml:"name,omitempty"`
+	URL            string  `yaml:"url"`
+	From           float64 `yaml:"from,omitempty"`
+	To             float64 `yaml:"to,omitempty"`
+	Chapters       string  `yaml:"chapters,omitempty"`
+	Volumes        string  `yaml:"volumes,omitempty"`
+	Latest         int     `yaml:"latest,omitempty"`
+	SkipExtras     bool    `yaml:"skip_extras,omitempty"`
+	ReleasedAfter  string  `yaml:"released_after,omitempty"`
+	ReleasedBefore string  `yaml:"released_before,omitempty"`
+	Frequency      string  `yaml:"frequency,omitempty"`
+	Schedule       string  `yaml:"schedule,omitempty"`
+	QuietHours     string  `yaml:"quiet_hours,omitempty"`
+	Notify         *bool   `yaml:"notify,omitempty"`
+}
+
+func sourceNode(s *site.Source) (*yaml.Node, error) {
+	ys := &yamlSource{
+		Name:           s.Name,
+		URL:            s.URL,
+		From:           s.From,
+		To:             s.To,
+		Chapters:       string(s.Chapters),
+		Volumes:        string(s.Volumes),
+		Latest:         s.Latest,
+		SkipExtras:     s.SkipExtras,
+		ReleasedAfter:  s.ReleasedAfter,
+		ReleasedBefore: s.ReleasedBefore,
+		Schedule:       s.Schedule,
+		QuietHours:     s.QuietHours,
+		Notify:         s.Notify,
+	}
+	if s.Frequency != 0 {
+		ys.Frequency = s.Frequency.String()
+	}
+	n := &yaml.Node{}
+	err := n.Encode(ys)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// AddSource appends a source to the config file. Comments in the rest of the
+// file are kept but it is re-indented and blank lines are removed. It fails if
+// a source or series already downloads from the url.
+func AddSource(file string, s *site.Source) error {
+	v := viper.New()
+	v.SetConfigFile(file)
+	err := v.ReadInConfig()
+	if err != nil {
+		return err
+	}
+	existing, err := sources(v)
+	if err != nil {
+		return err
+	}
+	for _, e := range existing {
+		if slices.Contains(sourceURLs(e), s.URL) {
+			return fmt.Errorf("%w: %s", ErrSourceExists, s.URL)
+		}
+	}
+
+	return editSources(file, func(sources *yaml.Node) error {
+		n, err := sourceNode(s)
+		if err != nil {
+			return err
+		}
+		sources.Content = append(sources.Content, n)
+		return nil
+	})
+}
+
+// RemoveSource removes the source with the given url from the config file,
+// a series is removed by the url of its first source.
+func RemoveSource(file string, url string) error {
+	return editFile(file, func(root *yaml.Node) error {
+		list, i, _ := findSourceNode(root, url)
+		if list == nil {
+			return fmt.Errorf("source %s not found", url)
+		}
+		list.Content = append(list.Content[:i], list.Content[i+1:]...)
+		return nil
+	})
+}
+
+// UpdateSource replaces the settings of the source with the given url. Keys
+// the source doesn't use, and the comments on the ones it keeps, are left
+// alone. A series is found by the url of its first source, its sources can't
+// be changed this way.
+func UpdateSource(file string, url string, s *site.Source) error {
+	return editFile(file, func(root *yaml.Node) error {
+		if s.URL != url {
+			if list, _, _ := findSourceNode(root, s.URL); list != nil {
+				return fmt.Errorf("source %s already exists", s.URL)
+			}
+		}
+		list, i, series := findSourceNode(root, url)
+		if list == nil {
+			return fmt.Errorf("source %s not found", url)
+		}
+		if series && s.URL != url {
+			return fmt.Errorf("the sources of a series can only be changed in the config file")
+		}
+		updated, err := sourceNode(s)
+		if err != nil {
+			return err
+		}
+		mergeSource(list.Content[i], updated, series)
+		return nil
+	})
+}
+
+// findSourceNode returns the list and index of the source with the given url,
+// series match the url of their first source. The list is nil if there is no
+// source with the url.
+func findSourceNode(root *yaml.Node, url string) (list *yaml.Node, i int, series bool) {
+	if l := mappingValue(root, "sources"); l != nil && l.Kind == yaml.SequenceNode {
+		for i, n := range l.Content {
+			if sourceURL(n) == url {
+				return l, i, false
+			}
+		}
+	}
+	if l := mappingValue(root, "series"); l != nil && l.Kind == yaml.SequenceNode {
+		for i, n := range l.Content {
+			urls := mappingValue(n, "sources")
+			if urls != nil && urls.Kind == yaml.SequenceNode && len(urls.Content) > 0 && urls.Content[0].Value == url {
+				return l, i, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// RenameSeries changes the name of the sources and series pinned to the old
+// name, it returns the number that were changed.
+func RenameSeries(file, old, name string) (int, error) {
+	changed := 0
+	err := editFile(file, func(root *yaml.Node) error {
+		for _, key := range []string{"sources", "series"} {
+			list := mappingValue(root, key)
+			if list == nil || list.Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, n := range list.Content {
+				v := mappingValue(n, "name")
+				if v != nil && strings.EqualFold(v.Value, old) {
+					v.Value = name
+					changed++
+				}
+			}
+		}
+		return nil
+	})
+	return changed, err
+}
+
+// mergeSource copies the values of updated into n, source keys missing from
+// updated are removed from n. Series list their urls under sources so they
+// don't get a url.
+func mergeSource(n, updated *yaml.Node, series bool) {
+	for _, key := range sourceKeys() {
+		if series && key == "url" {
+			continue
+		}
+		value := mappingValue(updated, key)
+		i := mappingIndex(n, key)
+		switch {
+		case value == nil && i >= 0:
+			n.Content = append(n.Content[:i], n.Content[i+2:]...)
+		case value != nil && i >= 0:
+			old := n.Content[i+1]
+			value.HeadComment = old.HeadComment
+			value.LineComment = old.LineComment
+			value.FootComment = old.FootComment
+			n.Content[i+1] = value
+		case value != nil:
+			n.Content = append(n.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+				value,
+			)
+		}
+	}
+}
+
+// sourceKeys returns the keys of yamlSource in the order they are written
+func sourceKeys() []string {
+	t := reflect.TypeFor[yamlSource]()
+	keys := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func mappingIndex(n *yaml.Node, key string) int {
+	if n.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func sourceURL(n *yaml.Node) string {
+	v := mappingValue(n, "url")
+	if v == nil {
+		return ""
+	}
+	return v.Value
+}
+
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	i := mappingIndex(n, key)
+	if i < 0 {
+		return nil
+	}
+	return n.Content[i+1]
+}
+
+func editSources(file string, cb func(sources *yaml.Node) error) error {
+	return editFile(file, func(root *yaml.Node) error {
+		sources := mappingValue(root, "sources")
+		if sources == nil {
+			sources = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			root.Content = append(root.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "sources"},
+				sources,
+			)
+		}
+		if sources.Kind != yaml.SequenceNode {
+			return fmt.Errorf("sources must be a list")
+		}
+		// an empty flow style list like sources: [] would stay on one line
+		sources.Style = 0
+		return cb(sources)
+	})
+}
+
+// editFile parses the config file, passes the root mapping to cb and writes
+// the result back atomically. The yaml encoder keeps comments but not blank
+// lines, the file is written with a 2 space indent.
+func editFile(file string, cb func(root *yaml.Node) error) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	doc := &yaml.Node{}
+	err = yaml.Unmarshal(b, doc)
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %w", file, err)
+	}
+	if doc.Kind == 0 {
+		doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{
+			{Kind: yaml.MappingNode, Tag: "!!map"},
+		}}
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a yaml mapping", file)
+	}
+
+	err = cb(doc.Content[0])
+	if err != nil {
+		return err
+	}
+
+	out := &bytes.Buffer{}
+	enc := yaml.NewEncoder(out)
+	enc.SetIndent(2)
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+	err = enc.Close()
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(file, out.Bytes())
+}
+
+func writeFileAtomic(file string, b []byte) error {
+	mode := os.FileMode(0644)
+	if stat, err := os.Stat(file); err == nil {
+		mode = stat.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Chmod(mode)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
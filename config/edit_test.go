@@ -0,0 +1,206 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `# where books are saved
+dir: /manga
+
+sources:
+  # followed on sundays
+  - name: One Piece
+    url: https://mangaplus.shueisha.co.jp/titles/100020
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(file, []byte(content), 0600)
+	require.NoError(t, err)
+	return file
+}
+
+func TestAddSource(t *testing.T) {
+	file := writeConfig(t, testConfig)
+
+	err := config.AddSource(file, &site.Source{
+		URL:       "https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f",
+		From:      10.5,
+		Frequency: 6 * time.Hour,
+	})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `# where books are saved
+dir: /manga
+sources:
+  # followed on sundays
+  - name: One Piece
+    url: https://mangaplus.shueisha.co.jp/titles/100020
+  - url: https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f
+    from: 10.5
+    frequency: 6h0m0s
+`, string(b))
+
+	stat, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	err = config.AddSource(file, &site.Source{URL: "https://mangaplus.shueisha.co.jp/titles/100020"})
+	assert.Error(t, err)
+}
+
+func TestAddSource_series(t *testing.T) {
+	cfg := `series:
+  - name: One Piece
+    sources:
+      - https://mangaplus.shueisha.co.jp/titles/100020
+      - https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f
+`
+	file := writeConfig(t, cfg)
+
+	err := config.AddSource(file, &site.Source{URL: "https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f"})
+	assert.ErrorIs(t, err, config.ErrSourceExists)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, cfg, string(b))
+}
+
+func TestAddSource_noSources(t *testing.T) {
+	file := writeConfig(t, "dir: /manga\n")
+
+	err := config.AddSource(file, &site.Source{URL: "https://mangaplus.shueisha.co.jp/titles/100020"})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `dir: /manga
+sources:
+  - url: https://mangaplus.shueisha.co.jp/titles/100020
+`, string(b))
+}
+
+func TestRemoveSource(t *testing.T) {
+	file := writeConfig(t, testConfig)
+
+	err := config.RemoveSource(file, "https://mangaplus.shueisha.co.jp/titles/100020")
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `# where books are saved
+dir: /manga
+sources: []
+`, string(b))
+
+	err = config.RemoveSource(file, "https://mangaplus.shueisha.co.jp/titles/100020")
+	assert.Error(t, err)
+}
+
+func TestUpdateSource(t *testing.T) {
+	file := writeConfig(t, `sources:
+  # followed on sundays
+  - name: One Piece # pinned
+    url: https://mangaplus.shueisha.co.jp/titles/100020
+    from: 5
+    extra: kept
+`)
+
+	err := config.UpdateSource(file, "https://mangaplus.shueisha.co.jp/titles/100020", &site.Source{
+		Name:     "One Piece (MangaPlus)",
+		URL:      "https://mangaplus.shueisha.co.jp/titles/100020",
+		Schedule: "0 12 * * 0",
+	})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `sources:
+  # followed on sundays
+  - name: One Piece (MangaPlus) # pinned
+    url: https://mangaplus.shueisha.co.jp/titles/100020
+    extra: kept
+    schedule: 0 12 * * 0
+`, string(b))
+
+	err = config.UpdateSource(file, "https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f", &site.Source{
+		URL: "https://mangadex.org/title/a1c7c817-4e59-43b7-9365-09675a149a6f",
+	})
+	assert.Error(t, err)
+}
+
+func TestUpdateSource_series(t *testing.T) {
+	file := writeConfig(t, `series:
+  - name: One Piece
+    sources:
+      - https://mangaplus.shueisha.co.jp/titles/100020
+      - https://www.viz.com/shonenjump/chapters/one-piece
+    upgrade: true
+`)
+	viz := "https://www.viz.com/shonenjump/chapters/one-piece"
+
+	err := config.UpdateSource(file, "https://mangaplus.shueisha.co.jp/titles/100020", &site.Source{
+		Name:     "One Piece",
+		URL:      "https://mangaplus.shueisha.co.jp/titles/100020",
+		Schedule: "0 12 * * 0",
+	})
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `series:
+  - name: One Piece
+    sources:
+      - https://mangaplus.shueisha.co.jp/titles/100020
+      - https://www.viz.com/shonenjump/chapters/one-piece
+    upgrade: true
+    schedule: 0 12 * * 0
+`, string(b))
+
+	err = config.UpdateSource(file, "https://mangaplus.shueisha.co.jp/titles/100020", &site.Source{Name: "One Piece", URL: viz})
+	assert.ErrorContains(t, err, "only be changed in the config file")
+	// only the first source finds the series
+	assert.Error(t, config.RemoveSource(file, viz))
+
+	require.NoError(t, config.RemoveSource(file, "https://mangaplus.shueisha.co.jp/titles/100020"))
+	b, err = os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "series: []\n", string(b))
+}
+
+func TestRenameSeries(t *testing.T) {
+	file := writeConfig(t, testConfig+`series:
+  - name: one piece
+    sources:
+      - https://www.viz.com/shonenjump/chapters/one-piece
+`)
+
+	changed, err := config.RenameSeries(file, "One Piece", "ONE PIECE")
+	require.NoError(t, err)
+	assert.Equal(t, 2, changed)
+
+	b, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, `# where books are saved
+dir: /manga
+sources:
+  # followed on sundays
+  - name: ONE PIECE
+    url: https://mangaplus.shueisha.co.jp/titles/100020
+series:
+  - name: ONE PIECE
+    sources:
+      - https://www.viz.com/shonenjump/chapters/one-piece
+`, string(b))
+}
@@ -3,6 +3,7 @@ package mangadex
 import (
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/abibby/manga/site"
 	"github.com/abibby/mangadexv5"
@@ -14,6 +15,8 @@ type Book struct {
 }
 
 var _ site.Book = &Book{}
+var _ site.Releaser = &Book{}
+var _ site.VolumeHinter = &Book{}
 
 func NewBook(client *mangadexv5.Client, chapter *mangadexv5.Chapter) *Book {
 	return &Book{
@@ -59,15 +62,21 @@ func (b *Book) Chapter() float64 {
 func (b *Book) Volume() int {
 	return 0
 }
-func (b *Book) Info() *site.BookInfo {
+func (b *Book) VolumeHint() int {
 	volume, _ := strconv.Atoi(b.mdChapter.Volume.String())
+	return volume
+}
+func (b *Book) Released() time.Time {
+	return b.mdChapter.PublishAt
+}
+func (b *Book) Info() *site.BookInfo {
 	info := &site.BookInfo{
 		// Author:    stripCtlAndExtFromUnicode(b.mdChapter.Manga().Author().Name),
 		Series:       stripCtlAndExtFromUnicode(b.mdChapter.Manga().Title.String()),
 		Title:        stripCtlAndExtFromUnicode(b.mdChapter.Title),
 		Chapter:      b.Chapter(),
-		Volume:       volume,
-		DateReleased: b.mdChapter.PublishAt,
+		Volume:       b.VolumeHint(),
+		DateReleased: b.Released(),
 		RightToLeft:  true,
 		LongStrip:    b.isLongStrip(),
 	}
@@ -0,0 +1,30 @@
+package mangadex
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/abibby/mangadexv5"
+	"go.uber.org/ratelimit"
+)
+
+// apiClient is used for every request to the mangadex api. Downloads, searches
+// and cover lookups share its rate limit so together they stay under the 5
+// requests a second mangadex allows.
+var apiClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: &limitedTransport{limiter: ratelimit.New(5)},
+}
+
+type limitedTransport struct {
+	limiter ratelimit.Limiter
+}
+
+func (t *limitedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.limiter.Take()
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newClient() *mangadexv5.Client {
+	return mangadexv5.NewClient(mangadexv5.HttpClient(apiClient))
+}
@@ -0,0 +1,99 @@
+package mangadex
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/abibby/manga/site"
+)
+
+var _ site.SeriesInformer = &Book{}
+
+type coverResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Volume   string `json:"volume"`
+			FileName string `json:"fileName"`
+		} `json:"attributes"`
+	} `json:"data"`
+	Total int `json:"total"`
+}
+
+func (b *Book) SeriesInfo() (*site.SeriesInfo, error) {
+	manga := b.mdChapter.Manga()
+	info := &site.SeriesInfo{
+		ID:           b.SeriesID(),
+		Name:         b.Series(),
+		Description:  manga.Description.String(),
+		Status:       manga.Status,
+		Web:          "https://" + hostName + "/title/" + manga.ID,
+		VolumeCovers: map[int]string{},
+	}
+
+	mainCover := manga.Relationships.Get("cover_art")
+	err := eachCover(manga.ID, func(id, volume, fileName string) {
+		u := coverURL(manga.ID, fileName)
+		if id == mainCover {
+			info.Cover = u
+		}
+		v, err := strconv.Atoi(volume)
+		if err != nil {
+			return
+		}
+		if _, ok := info.VolumeCovers[v]; !ok {
+			info.VolumeCovers[v] = u
+		}
+	})
+	if err != nil {
+		// the description is still worth saving without the art
+		slog.Warn("Could not load mangadex covers", "manga", manga.ID, "err", err)
+	}
+	if info.Cover == "" && len(info.VolumeCovers) > 0 {
+		info.Cover = info.VolumeCovers[slices.Max(slices.Collect(maps.Keys(info.VolumeCovers)))]
+	}
+	return info, nil
+}
+
+// eachCover calls fn with every cover of a manga, covers are listed in volume
+// order.
+func eachCover(mangaID string, fn func(id, volume, fileName string)) error {
+	for offset := 0; ; offset += 100 {
+		q := url.Values{
+			"manga[]":       {mangaID},
+			"limit":         {"100"},
+			"offset":        {strconv.Itoa(offset)},
+			"order[volume]": {"asc"},
+		}
+		resp, err := apiClient.Get("https://api." + hostName + "/cover?" + q.Encode())
+		if err != nil {
+			return err
+		}
+		body := &coverResponse{}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return fmt.Errorf("cover list failed with %s: %w", resp.Status, site.ErrHTTPStatus)
+		}
+		err = json.NewDecoder(resp.Body).Decode(body)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("could not decode cover response: %w", err)
+		}
+		for _, c := range body.Data {
+			fn(c.ID, c.Attributes.Volume, c.Attributes.FileName)
+		}
+		if len(body.Data) == 0 || offset+len(body.Data) >= body.Total {
+			return nil
+		}
+	}
+}
+
+func coverURL(mangaID, fileName string) string {
+	return "https://uploads." + hostName + "/covers/" + mangaID + "/" + fileName
+}
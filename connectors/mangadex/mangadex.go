@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"net/http/cookiejar"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -106,11 +108,11 @@ func mangaDexDownload(rawurl string, from int64) ([]site.Book, error) {
 
 func mangaDexDownloadSeries(id string, from int64) ([]site.Book, error) {
 	var err error
-	c := mangadexv5.NewClient()
+	c := newClient()
 	user := viper.GetString("mangadex.username")
 	pass := viper.GetString("mangadex.password")
 	if user != "" && pass != "" {
-		err = c.Authenticate(user, pass, "./md-token.json")
+		err = authenticate(c, user, pass)
 		if err != nil {
 			return nil, err
 		}
@@ -142,8 +144,8 @@ func mangaDexDownloadSeries(id string, from int64) ([]site.Book, error) {
 }
 
 func mangaDexDownloadFeed(from int64) ([]site.Book, error) {
-	c := mangadexv5.NewClient()
-	err := c.Authenticate(viper.GetString("mangadex.username"), viper.GetString("mangadex.password"), "./md-token.json")
+	c := newClient()
+	err := authenticate(c, viper.GetString("mangadex.username"), viper.GetString("mangadex.password"))
 	if err != nil {
 		return nil, err
 	}
@@ -175,6 +177,16 @@ func downloadChapters(c *mangadexv5.Client, chapters []*mangadexv5.Chapter) ([]s
 	return books, nil
 }
 
+// authenticate logs in storing the token in the mangadex cookie store
+func authenticate(c *mangadexv5.Client, user, pass string) error {
+	tokenFile := filepath.Join(viper.GetString("cookie_dir"), "mangadex.json")
+	err := os.MkdirAll(filepath.Dir(tokenFile), 0700)
+	if err != nil {
+		return err
+	}
+	return c.Authenticate(user, pass, tokenFile)
+}
+
 func lang() string {
 	vLang := viper.GetString("language")
 	lang, ok := langs[vLang]
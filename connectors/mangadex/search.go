@@ -0,0 +1,90 @@
+package mangadex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/abibby/manga/site"
+)
+
+var _ site.Searcher = &MangaDex{}
+
+type searchResponse struct {
+	Data []struct {
+		ID         string `json:"id"`
+		Attributes struct {
+			Title     map[string]string   `json:"title"`
+			AltTitles []map[string]string `json:"altTitles"`
+			Status    string              `json:"status"`
+		} `json:"attributes"`
+		Relationships []struct {
+			Type       string `json:"type"`
+			Attributes struct {
+				Name string `json:"name"`
+			} `json:"attributes"`
+		} `json:"relationships"`
+	} `json:"data"`
+}
+
+// Search uses the mangadex title search, the authors are included in the
+// response so only one request is made.
+func (m *MangaDex) Search(query string) ([]*site.SearchResult, error) {
+	q := url.Values{
+		"title":            {query},
+		"limit":            {"20"},
+		"includes[]":       {"author"},
+		"contentRating[]":  {"safe", "suggestive", "erotica"},
+		"order[relevance]": {"desc"},
+	}
+	resp, err := apiClient.Get("https://api." + hostName + "/manga?" + q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("search failed with %s: %w", resp.Status, site.ErrHTTPStatus)
+	}
+
+	body := &searchResponse{}
+	err = json.NewDecoder(resp.Body).Decode(body)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode search response: %w", err)
+	}
+
+	results := make([]*site.SearchResult, 0, len(body.Data))
+	for _, manga := range body.Data {
+		authors := []string{}
+		for _, r := range manga.Relationships {
+			if r.Type == "author" && r.Attributes.Name != "" {
+				authors = append(authors, r.Attributes.Name)
+			}
+		}
+		results = append(results, &site.SearchResult{
+			Title:  title(manga.Attributes.Title, manga.Attributes.AltTitles),
+			Author: strings.Join(authors, ", "),
+			Status: manga.Attributes.Status,
+			URL:    "https://" + hostName + "/title/" + manga.ID,
+		})
+	}
+	return results, nil
+}
+
+// title prefers an english title, some series only have one in their alt
+// titles
+func title(titles map[string]string, altTitles []map[string]string) string {
+	if t, ok := titles["en"]; ok {
+		return t
+	}
+	for _, alt := range altTitles {
+		if t, ok := alt["en"]; ok {
+			return t
+		}
+	}
+	for _, t := range titles {
+		return t
+	}
+	return ""
+}
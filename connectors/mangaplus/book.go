@@ -23,6 +23,8 @@ type Book struct {
 }
 
 var _ site.Book = &Book{}
+var _ site.Releaser = &Book{}
+var _ site.SeriesInformer = &Book{}
 
 func (m *MangaPlus) books(uri string) ([]site.Book, error) {
 	u, err := url.Parse(uri)
@@ -128,18 +130,12 @@ func (b *Book) Chapter() float64 {
 func (b *Book) Volume() int {
 	return 0
 }
+func (b *Book) Released() time.Time {
+	return time.Unix(int64(b.chapter.GetStartTimeStamp()), 0)
+}
 func (b *Book) Info() *site.BookInfo {
-	bookPages, err := b.Pages()
-	if err != nil {
-		panic(fmt.Errorf("failed to fetch pages: %w", err))
-	}
-	pages := make([]*site.InfoPage, len(bookPages))
-	for i, p := range bookPages {
-		pages[i] = &site.InfoPage{
-			Type: p.(*Page).pageType,
-		}
-	}
-
+	// Info doesn't make requests, the pages are left empty and the page types
+	// come from the pages when they are downloaded
 	return &site.BookInfo{
 		Series:       b.Series(),
 		Title:        b.chapter.GetSubTitle(),
@@ -148,12 +144,28 @@ func (b *Book) Info() *site.BookInfo {
 		Summary:      b.title.GetOverview(),
 		Author:       b.title.GetTitle().GetAuthor(),
 		Web:          fmt.Sprintf("https://mangaplus.shueisha.co.jp/viewer/%d", b.chapter.GetChapterId()),
-		DateReleased: time.Unix(int64(b.chapter.GetStartTimeStamp()), 0),
+		DateReleased: b.Released(),
 		RightToLeft:  true,
-		Pages:        pages,
 	}
 }
 
+func (b *Book) SeriesInfo() (*site.SeriesInfo, error) {
+	info := &site.SeriesInfo{
+		ID:          b.SeriesID(),
+		Name:        b.Series(),
+		Description: b.title.GetOverview(),
+		Author:      b.title.GetTitle().GetAuthor(),
+		Web:         fmt.Sprintf("https://mangaplus.shueisha.co.jp/titles/%d", b.title.GetTitle().GetTitleId()),
+		Cover:       b.title.GetTitle().GetPortaitImageUrl(),
+		Poster:      b.title.GetTitleImageUrl(),
+	}
+	// finished and paused series have no next chapter date
+	if b.title.GetNextTimeStamp() != 0 {
+		info.Status = site.SeriesStatusOngoing
+	}
+	return info, nil
+}
+
 type Page struct {
 	url           string
 	encryptionKey []byte
@@ -162,10 +174,14 @@ type Page struct {
 
 var _ site.Page = &Page{}
 var _ site.ImageDecrypter = &Page{}
+var _ site.PageTyper = &Page{}
 
 func (p *Page) URL() (string, error) {
 	return p.url, nil
 }
+func (p *Page) Type() site.PageType {
+	return p.pageType
+}
 func (p *Page) ImageDecrypt(encrypted io.Reader) io.Reader {
 	keyLen := len(p.encryptionKey)
 	if keyLen == 0 {
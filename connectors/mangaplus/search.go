@@ -0,0 +1,30 @@
+package mangaplus
+
+import (
+	"fmt"
+
+	"github.com/abibby/manga/site"
+)
+
+var _ site.Searcher = &MangaPlus{}
+
+// Search filters the list of all titles, mangaplus has no search endpoint.
+func (m *MangaPlus) Search(query string) ([]*site.SearchResult, error) {
+	result, err := m.client.Get("https://jumpg-webapi.tokyo-cdn.com/api/title_list/all")
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*site.SearchResult{}
+	for _, t := range result.GetAllTitlesView().GetTitles() {
+		if !site.SearchMatch(t.GetName(), query) {
+			continue
+		}
+		results = append(results, &site.SearchResult{
+			Title:  t.GetName(),
+			Author: t.GetAuthor(),
+			URL:    fmt.Sprintf("https://mangaplus.shueisha.co.jp/titles/%d", t.GetTitleId()),
+		})
+	}
+	return results, nil
+}
@@ -1,46 +1,106 @@
 package viz
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
-	"image"
-	"image/png"
 	"io"
+	"log/slog"
 	"net/url"
-	"strconv"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/abibby/manga/connectors/viz/vizapi"
 	"github.com/abibby/manga/site"
 	"github.com/abibby/manga/streams"
-	"github.com/rwcarlsen/goexif/exif"
 	"github.com/spf13/viper"
 )
 
-var newAPI = sync.OnceValues(func() (*vizapi.Client, error) {
-	return vizapi.New(
+var (
+	apiMtx    sync.Mutex
+	api       *vizapi.Client
+	apiConfig [3]string
+)
+
+// newAPI returns the shared viz client, a new client is created whenever the
+// credentials or cookie file in the config change.
+func newAPI() (*vizapi.Client, error) {
+	cfg := [3]string{
 		viper.GetString("viz.username"),
 		viper.GetString("viz.password"),
-		viper.GetString("cookie_file"),
-	)
-})
+		cookieFile(),
+	}
+
+	apiMtx.Lock()
+	defer apiMtx.Unlock()
+
+	if api != nil && apiConfig == cfg {
+		return api, nil
+	}
+	if api != nil {
+		slog.Info("Viz credentials changed, creating a new client")
+	}
+
+	c, err := vizapi.New(cfg[0], cfg[1], cfg[2])
+	if err != nil {
+		return nil, err
+	}
+	api = c
+	apiConfig = cfg
+	return api, nil
+}
+
+var cookieFileWarning sync.Once
+
+// cookieFile returns the path of the viz cookie store, each connector keeps
+// its own file in cookie_dir unless viz.cookie_file is set. The top level
+// cookie_file from before cookie_dir is still used when it is set.
+func cookieFile() string {
+	if f := viper.GetString("viz.cookie_file"); f != "" {
+		return f
+	}
+	if f := viper.GetString("cookie_file"); f != "" {
+		cookieFileWarning.Do(func() {
+			slog.Warn("cookie_file is deprecated, use cookie_dir or viz.cookie_file", "cookie_file", f)
+		})
+		return f
+	}
+	return filepath.Join(viper.GetString("cookie_dir"), "viz.json")
+}
 
 type Book struct {
 	chapter  *vizapi.Chapter
-	series   string
+	series   *vizapi.SeriesInfo
 	seriesID string
 	c        *vizapi.Client
+	encode   encodeOptions
+
+	pages []site.Page
+	meta  *vizapi.ChapterMetadata
 }
 
 var _ site.Book = &Book{}
+var _ site.Releaser = &Book{}
+var _ site.SeriesInformer = &Book{}
 
 func books(uri string) ([]site.Book, error) {
 	c, err := newAPI()
 	if err != nil {
 		return nil, err
 	}
+	err = c.EnsureSession()
+	if err != nil {
+		return nil, err
+	}
+
+	encode, err := newEncodeOptions(
+		viper.GetString("viz.image_format"),
+		viper.GetInt("viz.jpeg_quality"),
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	u, err := url.Parse(uri)
 	if err != nil {
@@ -53,21 +113,34 @@ func books(uri string) ([]site.Book, error) {
 		return nil, err
 	}
 
-	books := make([]site.Book, len(series.Chapters))
-	for i, chapter := range series.Chapters {
-		books[i] = &Book{
+	freeOnly := !c.HasCredentials()
+	if freeOnly {
+		slog.Debug("No viz credentials, only downloading free chapters", "series", seriesSlug)
+	}
+
+	books := make([]site.Book, 0, len(series.Chapters))
+	for _, chapter := range series.Chapters {
+		if freeOnly && !chapter.Free {
+			continue
+		}
+		books = append(books, &Book{
 			chapter:  chapter,
 			seriesID: seriesSlug,
-			series:   series.Title,
+			series:   series,
 			c:        c,
-		}
+			encode:   encode,
+		})
 	}
 	return books, nil
 }
 
 func (b *Book) Pages() ([]site.Page, error) {
+	if b.pages != nil {
+		return b.pages, nil
+	}
+
 	d, err := b.c.GetMangaURL(b.chapter.ID, []int{0})
-	if errors.Is(err, vizapi.ErrNotOK) {
+	if errors.Is(err, vizapi.ErrNotOK) && b.c.HasCredentials() {
 		err = b.c.Reauthenticate()
 		if err != nil {
 			return nil, err
@@ -88,6 +161,7 @@ func (b *Book) Pages() ([]site.Page, error) {
 		return nil, err
 	}
 
+	b.meta = meta
 	pages := make([]site.Page, 0, pageCount)
 
 	chunkSize := 5
@@ -111,12 +185,14 @@ func (b *Book) Pages() ([]site.Page, error) {
 				cover:       cover,
 				meta:        meta,
 				getMangaURL: getMangaURL,
+				encode:      b.encode,
 			})
 			if cover {
 				cover = false
 			}
 		}
 	}
+	b.pages = pages
 	return pages, nil
 }
 
@@ -124,7 +200,7 @@ func (b *Book) ID() string {
 	return fmt.Sprint(b.chapter.ID)
 }
 func (b *Book) Series() string {
-	return b.series
+	return b.series.Title
 }
 func (b *Book) SeriesID() string {
 	return fmt.Sprintf("viz:%s", b.seriesID)
@@ -135,14 +211,55 @@ func (b *Book) Chapter() float64 {
 func (b *Book) Volume() int {
 	return 0
 }
+func (b *Book) Released() time.Time {
+	return b.chapter.Released
+}
 func (b *Book) Info() *site.BookInfo {
-	return &site.BookInfo{
-		Series:      b.Series(),
-		Volume:      b.Volume(),
-		Chapter:     b.Chapter(),
-		Web:         b.chapter.URL,
-		RightToLeft: true,
+	info := &site.BookInfo{
+		Series:       b.Series(),
+		Title:        b.chapter.Title,
+		Volume:       b.Volume(),
+		Chapter:      b.Chapter(),
+		Summary:      b.series.Description,
+		Author:       b.series.Author(),
+		Web:          b.chapter.URL,
+		DateReleased: b.Released(),
+		RightToLeft:  true,
+	}
+
+	// the chapter metadata is only used when the pages have already been
+	// loaded, Info doesn't make requests. Without it, or without dimensions,
+	// the pages are left empty so their sizes are read from the downloaded
+	// images.
+	if b.meta == nil {
+		return info
 	}
+	if info.Title == "" {
+		info.Title = b.meta.Title
+	}
+	if b.meta.Width == 0 || b.meta.Height == 0 {
+		return info
+	}
+	info.Pages = make([]*site.InfoPage, len(b.pages))
+	for i, p := range b.pages {
+		info.Pages[i] = &site.InfoPage{
+			Type:   p.(*Page).Type(),
+			Width:  b.meta.Width,
+			Height: b.meta.Height,
+		}
+	}
+	return info
+}
+
+func (b *Book) SeriesInfo() (*site.SeriesInfo, error) {
+	return &site.SeriesInfo{
+		ID:          b.SeriesID(),
+		Name:        b.Series(),
+		Description: b.series.Description,
+		Author:      b.series.Author(),
+		Web:         b.series.URL,
+		Cover:       b.series.Image,
+	}, nil
 }
 
 type Page struct {
@@ -150,6 +267,7 @@ type Page struct {
 	cover       bool
 	getMangaURL func() (*vizapi.MangaURL, error)
 	meta        *vizapi.ChapterMetadata
+	encode      encodeOptions
 }
 
 var _ site.Page = &Page{}
@@ -181,101 +299,6 @@ func (p *Page) Type() site.PageType {
 
 func (p *Page) ImageDecrypt(encrypted io.Reader) io.Reader {
 	return streams.Transformer(encrypted, func(w io.Writer, r io.Reader) error {
-		srcBytes, err := io.ReadAll(encrypted)
-		if err != nil {
-			return err
-		}
-
-		x, err := exif.Decode(bytes.NewReader(srcBytes))
-		if err != nil {
-			return err
-		}
-
-		src, _, err := image.Decode(bytes.NewReader(srcBytes))
-		if err != nil {
-			return err
-		}
-
-		imgWidth, err := getInt(x, "ImageWidth")
-		if err != nil {
-			return err
-		}
-		imgHeight, err := getInt(x, "ImageLength")
-		if err != nil {
-			return err
-		}
-		dest := image.NewRGBA(image.Rect(0, 0, imgWidth, imgHeight))
-
-		xSplit := int(dest.Bounds().Dx() / 10)
-		ySplit := int(dest.Bounds().Dy() / 15)
-		copyRect(
-			src, dest,
-			0, 0,
-			0, 0,
-			dest.Bounds().Dx(), ySplit,
-		)
-		copyRect(
-			src, dest,
-			0, ySplit+10,
-			0, ySplit,
-			xSplit, dest.Bounds().Dy()-2*ySplit,
-		)
-		copyRect(
-			src, dest,
-			0, 14*(ySplit+10),
-			0, 14*ySplit,
-			dest.Bounds().Dx(), src.Bounds().Dy()-14*(ySplit+10),
-		)
-		copyRect(
-			src, dest,
-			9*(xSplit+10), ySplit+10,
-			9*xSplit, ySplit,
-			xSplit+(dest.Bounds().Dx()-10*xSplit), dest.Bounds().Dy()-2*ySplit,
-		)
-
-		imageUniqueID, err := x.Get("ImageUniqueID")
-		if err != nil {
-			return err
-		}
-		id, err := imageUniqueID.StringVal()
-		if err != nil {
-			return err
-		}
-
-		shuffleMap := strings.Split(id, ":")
-		for piece := 0; piece < len(shuffleMap); piece++ {
-			shuffleMapPiece, err := strconv.ParseInt(shuffleMap[piece], 16, 64)
-			if err != nil {
-				return err
-			}
-			copyRect(
-				src, dest,
-				((piece%8)+1)*(xSplit+10), ((piece/8)+1)*(ySplit+10), // sx, y
-				((int(shuffleMapPiece)%8)+1)*xSplit, ((int(shuffleMapPiece)/8)+1)*ySplit, // dx, y
-				xSplit, ySplit,
-			)
-		}
-
-		return png.Encode(w, dest)
+		return descramble(w, r, p.encode)
 	})
 }
-
-func copyRect(src image.Image, dest *image.RGBA, srcX, srcY, destX, destY, w, h int) {
-	for x := range w {
-		for y := range h {
-			dest.Set(destX+x, destY+y, src.At(srcX+x, srcY+y))
-		}
-	}
-}
-
-func getInt(x *exif.Exif, name string) (int, error) {
-	field, err := x.Get(exif.FieldName(name))
-	if err != nil {
-		return 0, err
-	}
-	i, err := field.Int64(0)
-	if err != nil {
-		return 0, err
-	}
-	return int(i), nil
-}
@@ -0,0 +1,47 @@
+package viz
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/connectors/viz/vizapi"
+	"github.com/abibby/manga/site"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCookieFile(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("cookie_dir", "/cookies")
+	assert.Equal(t, filepath.Join("/cookies", "viz.json"), cookieFile())
+
+	// cookie_file from before cookie_dir is still honoured
+	viper.Set("cookie_file", "/old/cookies.json")
+	assert.Equal(t, "/old/cookies.json", cookieFile())
+
+	viper.Set("viz.cookie_file", "/viz.json")
+	assert.Equal(t, "/viz.json", cookieFile())
+}
+
+func TestBook_Info(t *testing.T) {
+	b := &Book{chapter: &vizapi.Chapter{ID: 1, Chapter: 1}, series: &vizapi.SeriesInfo{Title: "One Piece"}}
+	info := b.Info()
+	assert.Nil(t, info.Pages)
+
+	meta := &vizapi.ChapterMetadata{Title: "Romance Dawn", Width: 800, Height: 1200, Spreads: []int{2}}
+	b.meta = meta
+	b.pages = []site.Page{
+		&Page{number: 1, cover: true, meta: meta},
+		&Page{number: 2, meta: meta},
+		&Page{number: 4, meta: meta},
+	}
+	info = b.Info()
+	assert.Equal(t, "Romance Dawn", info.Title)
+	assert.Equal(t, []*site.InfoPage{
+		{Type: site.PageTypeFrontCover, Width: 800, Height: 1200},
+		{Type: site.PageTypeSpreadSplit, Width: 800, Height: 1200},
+		{Type: site.PageTypeStory, Width: 800, Height: 1200},
+	}, info.Pages)
+}
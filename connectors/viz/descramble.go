@@ -0,0 +1,196 @@
+package viz
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// ImageFormat is the format descrambled pages are encoded as.
+type ImageFormat string
+
+const (
+	// ImageFormatAuto keeps PNG pages as PNG and re-encodes everything else
+	// as a high quality JPEG.
+	ImageFormatAuto = ImageFormat("auto")
+	ImageFormatJPEG = ImageFormat("jpeg")
+	ImageFormatPNG  = ImageFormat("png")
+)
+
+const defaultJPEGQuality = 95
+
+type encodeOptions struct {
+	format  ImageFormat
+	quality int
+}
+
+func newEncodeOptions(format string, quality int) (encodeOptions, error) {
+	opts := encodeOptions{
+		format:  ImageFormat(strings.ToLower(format)),
+		quality: quality,
+	}
+	switch opts.format {
+	case "":
+		opts.format = ImageFormatAuto
+	case ImageFormatAuto, ImageFormatJPEG, ImageFormatPNG:
+	default:
+		return encodeOptions{}, fmt.Errorf("unknown viz image format %q", format)
+	}
+	if opts.quality == 0 {
+		opts.quality = defaultJPEGQuality
+	}
+	if opts.quality < 1 || opts.quality > 100 {
+		return encodeOptions{}, fmt.Errorf("viz jpeg quality must be between 1 and 100, got %d", quality)
+	}
+	return opts, nil
+}
+
+// descramble reassembles a scrambled viz page. Pages are split into a 10x15
+// grid with a 10px gutter between tiles, the edge tiles are left in place and
+// the inner 8x13 tiles are shuffled according to the EXIF ImageUniqueID.
+func descramble(w io.Writer, r io.Reader, opts encodeOptions) error {
+	srcBytes, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	x, err := exif.Decode(bytes.NewReader(srcBytes))
+	if err != nil {
+		return err
+	}
+
+	src, srcFormat, err := image.Decode(bytes.NewReader(srcBytes))
+	if err != nil {
+		return err
+	}
+
+	imgWidth, err := getInt(x, "ImageWidth")
+	if err != nil {
+		return err
+	}
+	imgHeight, err := getInt(x, "ImageLength")
+	if err != nil {
+		return err
+	}
+
+	imageUniqueID, err := x.Get("ImageUniqueID")
+	if err != nil {
+		return err
+	}
+	id, err := imageUniqueID.StringVal()
+	if err != nil {
+		return err
+	}
+
+	dest := newDest(src, image.Rect(0, 0, imgWidth, imgHeight))
+	sb := src.Bounds()
+
+	xSplit := imgWidth / 10
+	ySplit := imgHeight / 15
+	copyRect(
+		src, dest,
+		0, 0,
+		0, 0,
+		imgWidth, ySplit,
+	)
+	copyRect(
+		src, dest,
+		0, ySplit+10,
+		0, ySplit,
+		xSplit, imgHeight-2*ySplit,
+	)
+	copyRect(
+		src, dest,
+		0, 14*(ySplit+10),
+		0, 14*ySplit,
+		imgWidth, sb.Dy()-14*(ySplit+10),
+	)
+	copyRect(
+		src, dest,
+		9*(xSplit+10), ySplit+10,
+		9*xSplit, ySplit,
+		xSplit+(imgWidth-10*xSplit), imgHeight-2*ySplit,
+	)
+
+	shuffleMap := strings.Split(id, ":")
+	for piece := 0; piece < len(shuffleMap); piece++ {
+		shuffleMapPiece, err := strconv.ParseInt(shuffleMap[piece], 16, 64)
+		if err != nil {
+			return err
+		}
+		copyRect(
+			src, dest,
+			((piece%8)+1)*(xSplit+10), ((piece/8)+1)*(ySplit+10), // sx, y
+			((int(shuffleMapPiece)%8)+1)*xSplit, ((int(shuffleMapPiece)/8)+1)*ySplit, // dx, y
+			xSplit, ySplit,
+		)
+	}
+
+	return encode(w, dest, srcFormat, opts)
+}
+
+// newDest creates the image the page is reassembled into. Grayscale pages stay
+// grayscale, everything else is drawn into an RGBA image which draw.Draw has
+// fast paths for.
+func newDest(src image.Image, r image.Rectangle) draw.Image {
+	switch src.(type) {
+	case *image.Gray:
+		return image.NewGray(r)
+	default:
+		return image.NewRGBA(r)
+	}
+}
+
+func encode(w io.Writer, img image.Image, srcFormat string, opts encodeOptions) error {
+	format := opts.format
+	if format == ImageFormatAuto {
+		if srcFormat == "png" {
+			format = ImageFormatPNG
+		} else {
+			format = ImageFormatJPEG
+		}
+	}
+
+	switch format {
+	case ImageFormatPNG:
+		return png.Encode(w, img)
+	case ImageFormatJPEG:
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: opts.quality})
+	default:
+		return fmt.Errorf("unknown viz image format %q", format)
+	}
+}
+
+func copyRect(src image.Image, dest draw.Image, srcX, srcY, destX, destY, w, h int) {
+	if w <= 0 || h <= 0 {
+		return
+	}
+	sb := src.Bounds()
+	draw.Draw(
+		dest,
+		image.Rect(destX, destY, destX+w, destY+h),
+		src,
+		image.Pt(sb.Min.X+srcX, sb.Min.Y+srcY),
+		draw.Src,
+	)
+}
+
+func getInt(x *exif.Exif, name string) (int, error) {
+	field, err := x.Get(exif.FieldName(name))
+	if err != nil {
+		return 0, err
+	}
+	i, err := field.Int64(0)
+	if err != nil {
+		return 0, err
+	}
+	return int(i), nil
+}
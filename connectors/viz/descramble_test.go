@@ -0,0 +1,239 @@
+package viz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const (
+	fixtureWidth  = 200
+	fixtureHeight = 300
+)
+
+var (
+	scrambledFixture   = filepath.Join("testdata", "scrambled.jpg")
+	descrambledFixture = filepath.Join("testdata", "descrambled.png")
+)
+
+func TestDescramble_golden(t *testing.T) {
+	if *update {
+		writeScrambledFixture(t)
+	}
+	scrambled, err := os.ReadFile(scrambledFixture)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	err = descramble(out, bytes.NewReader(scrambled), encodeOptions{format: ImageFormatPNG})
+	require.NoError(t, err)
+
+	if *update {
+		err = os.WriteFile(descrambledFixture, out.Bytes(), 0644)
+		require.NoError(t, err)
+	}
+
+	got, err := png.Decode(bytes.NewReader(out.Bytes()))
+	require.NoError(t, err)
+
+	goldenFile, err := os.Open(descrambledFixture)
+	require.NoError(t, err)
+	defer goldenFile.Close()
+	golden, err := png.Decode(goldenFile)
+	require.NoError(t, err)
+
+	require.Equal(t, golden.Bounds(), got.Bounds())
+	for y := golden.Bounds().Min.Y; y < golden.Bounds().Max.Y; y++ {
+		for x := golden.Bounds().Min.X; x < golden.Bounds().Max.X; x++ {
+			if !assert.Equal(t, rgba(golden.At(x, y)), rgba(got.At(x, y)), "pixel %d,%d", x, y) {
+				return
+			}
+		}
+	}
+}
+
+func TestDescramble_matchesOriginal(t *testing.T) {
+	scrambled, err := os.ReadFile(scrambledFixture)
+	require.NoError(t, err)
+
+	out := &bytes.Buffer{}
+	err = descramble(out, bytes.NewReader(scrambled), encodeOptions{format: ImageFormatPNG})
+	require.NoError(t, err)
+
+	got, err := png.Decode(out)
+	require.NoError(t, err)
+
+	original := originalFixture()
+	require.Equal(t, original.Bounds(), got.Bounds())
+
+	// the fixture went through a jpeg encode so allow a little drift
+	for y := 0; y < fixtureHeight; y++ {
+		for x := 0; x < fixtureWidth; x++ {
+			a := rgba(original.At(x, y))
+			b := rgba(got.At(x, y))
+			if !assert.InDelta(t, a.R, b.R, 24, "pixel %d,%d", x, y) ||
+				!assert.InDelta(t, a.G, b.G, 24, "pixel %d,%d", x, y) ||
+				!assert.InDelta(t, a.B, b.B, 24, "pixel %d,%d", x, y) {
+				return
+			}
+		}
+	}
+}
+
+func TestDescramble_format(t *testing.T) {
+	scrambled, err := os.ReadFile(scrambledFixture)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		format ImageFormat
+		want   string
+	}{
+		{ImageFormatAuto, "jpeg"},
+		{ImageFormatJPEG, "jpeg"},
+		{ImageFormatPNG, "png"},
+	}
+	for _, tc := range testCases {
+		t.Run(string(tc.format), func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err = descramble(out, bytes.NewReader(scrambled), encodeOptions{format: tc.format, quality: defaultJPEGQuality})
+			require.NoError(t, err)
+
+			cfg, format, err := image.DecodeConfig(out)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, format)
+			assert.Equal(t, fixtureWidth, cfg.Width)
+			assert.Equal(t, fixtureHeight, cfg.Height)
+		})
+	}
+}
+
+func TestNewEncodeOptions(t *testing.T) {
+	opts, err := newEncodeOptions("", 0)
+	require.NoError(t, err)
+	assert.Equal(t, encodeOptions{format: ImageFormatAuto, quality: defaultJPEGQuality}, opts)
+
+	opts, err = newEncodeOptions("PNG", 80)
+	require.NoError(t, err)
+	assert.Equal(t, encodeOptions{format: ImageFormatPNG, quality: 80}, opts)
+
+	_, err = newEncodeOptions("gif", 0)
+	assert.Error(t, err)
+
+	_, err = newEncodeOptions("jpeg", 101)
+	assert.Error(t, err)
+}
+
+func rgba(c color.Color) color.RGBA {
+	return color.RGBAModel.Convert(c).(color.RGBA)
+}
+
+// originalFixture is the unscrambled page, every tile gets its own flat colour
+// so a misplaced tile is easy to spot.
+func originalFixture() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, fixtureWidth, fixtureHeight))
+	xSplit := fixtureWidth / 10
+	ySplit := fixtureHeight / 15
+	for y := 0; y < fixtureHeight; y++ {
+		for x := 0; x < fixtureWidth; x++ {
+			tx, ty := x/xSplit, y/ySplit
+			img.SetRGBA(x, y, color.RGBA{
+				R: uint8(tx * 25),
+				G: uint8(ty * 17),
+				B: uint8((tx + ty) % 2 * 200),
+				A: 255,
+			})
+		}
+	}
+	return img
+}
+
+// writeScrambledFixture scrambles originalFixture the same way viz does and
+// stores the shuffle map in the EXIF ImageUniqueID.
+func writeScrambledFixture(t *testing.T) {
+	original := originalFixture()
+	xSplit := fixtureWidth / 10
+	ySplit := fixtureHeight / 15
+
+	scrambled := image.NewRGBA(image.Rect(0, 0, fixtureWidth+90, fixtureHeight+140))
+	draw.Draw(scrambled, scrambled.Bounds(), image.NewUniform(color.RGBA{255, 0, 255, 255}), image.Point{}, draw.Src)
+
+	place := func(srcX, srcY, destX, destY, w, h int) {
+		draw.Draw(scrambled, image.Rect(srcX, srcY, srcX+w, srcY+h), original, image.Pt(destX, destY), draw.Src)
+	}
+	place(0, 0, 0, 0, fixtureWidth, ySplit)
+	place(0, ySplit+10, 0, ySplit, xSplit, fixtureHeight-2*ySplit)
+	place(0, 14*(ySplit+10), 0, 14*ySplit, fixtureWidth, fixtureHeight-14*ySplit)
+	place(9*(xSplit+10), ySplit+10, 9*xSplit, ySplit, fixtureWidth-9*xSplit, fixtureHeight-2*ySplit)
+
+	shuffle := make([]string, 8*13)
+	for piece := range shuffle {
+		dest := (piece*37 + 11) % len(shuffle)
+		shuffle[piece] = fmt.Sprintf("%x", dest)
+		place(
+			((piece%8)+1)*(xSplit+10), ((piece/8)+1)*(ySplit+10),
+			((dest%8)+1)*xSplit, ((dest/8)+1)*ySplit,
+			xSplit, ySplit,
+		)
+	}
+
+	b := &bytes.Buffer{}
+	err := jpeg.Encode(b, scrambled, &jpeg.Options{Quality: 100})
+	require.NoError(t, err)
+
+	err = os.MkdirAll(filepath.Dir(scrambledFixture), 0755)
+	require.NoError(t, err)
+	err = os.WriteFile(scrambledFixture, withExif(b.Bytes(), strings.Join(shuffle, ":")), 0644)
+	require.NoError(t, err)
+}
+
+// withExif inserts an APP1 segment holding ImageWidth, ImageLength and
+// ImageUniqueID directly after the jpeg SOI marker.
+func withExif(jpg []byte, uniqueID string) []byte {
+	le := binary.LittleEndian
+	tiff := []byte{'I', 'I', 0x2a, 0x00}
+	tiff = le.AppendUint32(tiff, 8)
+
+	entry := func(b []byte, tag, typ uint16, count, value uint32) []byte {
+		b = le.AppendUint16(b, tag)
+		b = le.AppendUint16(b, typ)
+		b = le.AppendUint32(b, count)
+		return le.AppendUint32(b, value)
+	}
+
+	const ifd0Entries = 3
+	exifIFD := uint32(8 + 2 + ifd0Entries*12 + 4)
+	tiff = le.AppendUint16(tiff, ifd0Entries)
+	tiff = entry(tiff, 0x0100, 4, 1, fixtureWidth)
+	tiff = entry(tiff, 0x0101, 4, 1, fixtureHeight)
+	tiff = entry(tiff, 0x8769, 4, 1, exifIFD)
+	tiff = le.AppendUint32(tiff, 0)
+
+	id := append([]byte(uniqueID), 0)
+	tiff = le.AppendUint16(tiff, 1)
+	tiff = entry(tiff, 0xa420, 2, uint32(len(id)), exifIFD+2+12+4)
+	tiff = le.AppendUint32(tiff, 0)
+	tiff = append(tiff, id...)
+
+	app1 := []byte{0xff, 0xe1}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(payload)+2))
+	app1 = append(app1, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, app1...)
+	return append(out, jpg[2:]...)
+}
@@ -0,0 +1,32 @@
+package viz
+
+import (
+	"github.com/abibby/manga/site"
+)
+
+var _ site.Searcher = &Viz{}
+
+// Search filters the viz series index, it has no search endpoint for the
+// shonen jump series.
+func (m *Viz) Search(query string) ([]*site.SearchResult, error) {
+	c, err := newAPI()
+	if err != nil {
+		return nil, err
+	}
+	series, err := c.GetSeriesIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []*site.SearchResult{}
+	for _, s := range series {
+		if !site.SearchMatch(s.Title, query) {
+			continue
+		}
+		results = append(results, &site.SearchResult{
+			Title: s.Title,
+			URL:   s.URL,
+		})
+	}
+	return results, nil
+}
@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 )
@@ -44,7 +46,7 @@ func (m *MangaURL) GetMetadata() (*ChapterMetadata, error) {
 	meta := &ChapterMetadata{}
 	err = json.NewDecoder(resp.Body).Decode(meta)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not decode chapter metadata: %w", err)
 	}
 	return meta, nil
 }
@@ -52,20 +54,18 @@ func (m *MangaURL) GetMetadata() (*ChapterMetadata, error) {
 var ErrNotOK = errors.New("not OK")
 
 func (c *Client) GetMangaURL(mangaID int, pages []int) (*MangaURL, error) {
-	uri := fmt.Sprintf("https://www.viz.com/manga/get_manga_url?device_id=3&manga_id=%d&pages=%s", mangaID, join(pages, ","))
+	uri := fmt.Sprintf("%s/manga/get_manga_url?device_id=3&manga_id=%d&pages=%s", c.baseURL, mangaID, join(pages, ","))
 
 	resp, err := c.get(uri)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
-	// io.Copy(os.Stdout, resp.Body)
-	// os.Exit(1)
 	m := &mangaURL{}
 
 	err = json.NewDecoder(resp.Body).Decode(m)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not decode get_manga_url response: %w", err)
 	}
 
 	if m.OK != 1 {
@@ -98,82 +98,142 @@ func join[T any](arr []T, joiner string) string {
 }
 
 type Chapter struct {
-	ID      int
-	Chapter float64
-	URL     string
-	c       *Client
+	ID       int
+	Chapter  float64
+	Title    string
+	Released time.Time
+	URL      string
+	// Free is true for chapters that can be read without logging in
+	Free bool
+	c    *Client
 }
 
 type SeriesInfo struct {
-	Title    string
+	Title       string
+	Description string
+	Credits     []*Credit
+	// Image is the url of the series art, empty if the page has none
+	Image    string
+	URL      string
 	Chapters []*Chapter
 }
 
+var (
+	chapterURLRE = regexp.MustCompile(`/shonenjump/[^/]+/chapter/(\d+)`)
+	pageCountRE  = regexp.MustCompile(`var\s+pages\s*=\s*(\d+)`)
+)
+
+// ErrUnexpectedLayout is returned when a viz page doesn't look the way the
+// parser expects, it usually means viz has changed their markup.
+var ErrUnexpectedLayout = errors.New("unexpected viz page layout")
+
 func (c *Client) GetSeries(seriesSlug string) (*SeriesInfo, error) {
-	resp, err := c.get(c.baseURL + "/shonenjump/chapters/" + seriesSlug)
+	uri := c.baseURL + "/shonenjump/chapters/" + seriesSlug
+	resp, err := c.get(uri)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	// b, err := io.ReadAll(resp.Body)
-	// if err != nil {
-	// 	return nil,  err
-	// }
-
-	re := regexp.MustCompile(`/shonenjump/[^/]+/chapter/(\d+)`)
-
-	// matches := re.FindAllSubmatch(b, -1)
-	// for _, m := range matches {
-	// 	spew.Dump(string(m[1]), string(m[2]))
-	// }
-
 	d, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("could not parse %s: %w", uri, err)
+	}
+
+	intro := d.Find("#series-intro")
+	if intro.Length() == 0 {
+		return nil, fmt.Errorf("%s has no #series-intro: %w", uri, ErrUnexpectedLayout)
 	}
 
 	chaptersLinks := d.Find("a[name]")
 
 	s := &SeriesInfo{
-		Title:    d.Find("#series-intro h2").Text(),
-		Chapters: make([]*Chapter, 0, chaptersLinks.Length()),
+		Title:       cleanText(intro.Find("h2").First().Text()),
+		Description: seriesDescription(intro),
+		Credits:     seriesCredits(intro),
+		Image:       c.absURL(seriesImage(d)),
+		URL:         uri,
+		Chapters:    make([]*Chapter, 0, chaptersLinks.Length()),
+	}
+	if s.Title == "" {
+		return nil, fmt.Errorf("%s has no series title: %w", uri, ErrUnexpectedLayout)
 	}
 
-	for _, chapterNode := range chaptersLinks.Nodes {
-		node := d.FindNodes(chapterNode)
-		chapterNumber, err := strconv.ParseFloat(node.AttrOr("name", ""), 64)
+	chaptersLinks.Each(func(i int, node *goquery.Selection) {
+		chapter, err := c.parseChapter(node)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse chapter number: %w", err)
+			slog.Warn("Skipping viz chapter", "series", seriesSlug, "err", err)
+			return
 		}
+		s.Chapters = append(s.Chapters, chapter)
+	})
+
+	if len(s.Chapters) == 0 && chaptersLinks.Length() > 0 {
+		return nil, fmt.Errorf("could not parse any of the %d chapters on %s: %w", chaptersLinks.Length(), uri, ErrUnexpectedLayout)
+	}
 
-		href, _ := node.Attr("href")
-		if strings.HasPrefix(href, "/") {
+	return s, nil
+}
+
+// absURL resolves links relative to the viz site
+func (c *Client) absURL(href string) string {
+	if strings.HasPrefix(href, "//") {
+		return "https:" + href
+	}
+	if strings.HasPrefix(href, "/") {
+		return c.baseURL + href
+	}
+	return href
+}
+
+func (c *Client) parseChapter(node *goquery.Selection) (*Chapter, error) {
+	name := node.AttrOr("name", "")
+	chapterNumber, err := strconv.ParseFloat(name, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chapter number %q: %w", name, err)
+	}
+
+	href, _ := node.Attr("href")
+	free := true
+	if strings.HasPrefix(href, "/") {
+		href = c.baseURL + href
+	} else if strings.HasPrefix(href, "http") {
+		// noop
+	} else if onclick, ok := node.Attr("onclick"); ok {
+		// chapters behind the paywall open the login modal instead of
+		// linking directly
+		href = chapterURLRE.FindString(onclick)
+		if href != "" {
 			href = c.baseURL + href
-		} else if strings.HasPrefix(href, "http") {
-			// noop
-		} else if onclick, ok := node.Attr("onclick"); ok {
-			href = c.baseURL + re.FindString(onclick)
-		}
-		if href == "" {
-			return nil, fmt.Errorf("cound not find chapter link for chapter %f", chapterNumber)
-		}
-		matches := re.FindStringSubmatch(href)
-		id, err := strconv.Atoi(matches[1])
-		if err != nil {
-			return nil, err
 		}
+		free = false
+	} else {
+		href = ""
+	}
+	if href == "" {
+		return nil, fmt.Errorf("cound not find chapter link for chapter %g: %w", chapterNumber, ErrUnexpectedLayout)
+	}
 
-		s.Chapters = append(s.Chapters, &Chapter{
-			ID:      id,
-			Chapter: chapterNumber,
-			URL:     href,
-			c:       c,
-		})
+	matches := chapterURLRE.FindStringSubmatch(href)
+	if matches == nil {
+		return nil, fmt.Errorf("chapter %g link %s has no chapter id: %w", chapterNumber, href, ErrUnexpectedLayout)
+	}
+	id, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chapter id %q: %w", matches[1], err)
 	}
 
-	return s, nil
+	return &Chapter{
+		ID:       id,
+		Chapter:  chapterNumber,
+		Title:    chapterTitle(node),
+		Released: chapterReleased(node),
+		URL:      href,
+		Free:     free,
+		c:        c,
+	}, nil
 }
+
 func (c *Chapter) GetPageCount() (int, error) {
 	resp, err := c.c.get(c.URL)
 	if err != nil {
@@ -186,6 +246,77 @@ func (c *Chapter) GetPageCount() (int, error) {
 		return 0, err
 	}
 
-	bPageCount := regexp.MustCompile(`var\s+pages\s*=\s*(\d+)`).FindSubmatch(responseData)[1]
-	return strconv.Atoi(string(bPageCount))
+	match := pageCountRE.FindSubmatch(responseData)
+	if match == nil {
+		return 0, fmt.Errorf("no page count found on %s: %w", c.URL, ErrUnexpectedLayout)
+	}
+	pageCount, err := strconv.Atoi(string(match[1]))
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse page count %q on %s: %w", match[1], c.URL, err)
+	}
+	return pageCount, nil
+}
+
+// SeriesLink is a series listed on the series index
+type SeriesLink struct {
+	Slug  string
+	Title string
+	URL   string
+}
+
+var seriesURLRE = regexp.MustCompile(`/shonenjump/chapters/([^/?#]+)`)
+
+// GetSeriesIndex returns every series listed in the shonen jump section.
+func (c *Client) GetSeriesIndex() ([]*SeriesLink, error) {
+	uri := c.baseURL + "/read/shonenjump/section/free-chapters"
+	resp, err := c.get(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	d, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", uri, err)
+	}
+
+	series := []*SeriesLink{}
+	found := map[string]*SeriesLink{}
+	d.Find(`a[href*="/shonenjump/chapters/"]`).Each(func(i int, node *goquery.Selection) {
+		matches := seriesURLRE.FindStringSubmatch(node.AttrOr("href", ""))
+		if matches == nil {
+			return
+		}
+		slug := matches[1]
+		title := cleanText(node.Text())
+		if title == "" {
+			title = cleanText(node.Find("img").AttrOr("alt", ""))
+		}
+
+		// series are often linked twice, once from the cover and once
+		// from the title
+		if s, ok := found[slug]; ok {
+			if s.Title == "" {
+				s.Title = title
+			}
+			return
+		}
+		s := &SeriesLink{
+			Slug:  slug,
+			Title: title,
+			URL:   c.baseURL + "/shonenjump/chapters/" + slug,
+		}
+		found[slug] = s
+		series = append(series, s)
+	})
+
+	if len(series) == 0 {
+		return nil, fmt.Errorf("%s has no series links: %w", uri, ErrUnexpectedLayout)
+	}
+	for _, s := range series {
+		if s.Title == "" {
+			s.Title = s.Slug
+		}
+	}
+	return series, nil
 }
@@ -0,0 +1,270 @@
+package vizapi_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/connectors/viz/vizapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureServer serves the files in testdata, routes maps a request path to a
+// fixture file. BASE_URL in fixtures is replaced with the server url.
+func fixtureServer(t *testing.T, routes map[string]string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fixture, ok := routes[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		b, err := os.ReadFile(filepath.Join("testdata", fixture))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(strings.ReplaceAll(string(b), "BASE_URL", srv.URL)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newClient(t *testing.T, srv *httptest.Server, username, password string) *vizapi.Client {
+	t.Helper()
+	c, err := vizapi.New(
+		username,
+		password,
+		filepath.Join(t.TempDir(), "viz.json"),
+		vizapi.WithBaseURL(srv.URL),
+		vizapi.WithTransport(http.DefaultTransport),
+	)
+	require.NoError(t, err)
+	return c
+}
+
+func TestGetSeries(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/shonenjump/chapters/one-punch-man": "series.html",
+	})
+	c := newClient(t, srv, "", "")
+
+	series, err := c.GetSeries("one-punch-man")
+	require.NoError(t, err)
+
+	assert.Equal(t, "One-Punch Man", series.Title)
+	assert.Equal(t, "Nothing about Saitama passes the eyeball test when it comes to superheroes.", series.Description)
+	assert.Equal(t, []*vizapi.Credit{
+		{Role: "Story", Name: "ONE"},
+		{Role: "Art", Name: "Yusuke Murata"},
+	}, series.Credits)
+	assert.Equal(t, "ONE, Yusuke Murata", series.Author())
+	assert.Equal(t, "https://dw9to29mmj727.cloudfront.net/properties/2016-09/onepunchman_series.jpg", series.Image)
+	assert.Equal(t, srv.URL+"/shonenjump/chapters/one-punch-man", series.URL)
+
+	// the bonus chapter has no number and chapter 169 has no link, both are
+	// skipped rather than failing the whole series
+	require.Len(t, series.Chapters, 2)
+
+	ch := series.Chapters[0]
+	assert.Equal(t, 31552, ch.ID)
+	assert.Equal(t, 171.0, ch.Chapter)
+	assert.Equal(t, "Blast", ch.Title)
+	assert.Equal(t, time.Date(2024, time.October, 3, 0, 0, 0, 0, time.UTC), ch.Released)
+	assert.Equal(t, srv.URL+"/shonenjump/one-punch-man-chapter-171/chapter/31552", ch.URL)
+	assert.True(t, ch.Free)
+
+	ch = series.Chapters[1]
+	assert.Equal(t, 31501, ch.ID)
+	assert.Equal(t, 170.5, ch.Chapter)
+	assert.Equal(t, "", ch.Title)
+	assert.Equal(t, time.Date(2024, time.September, 12, 0, 0, 0, 0, time.UTC), ch.Released)
+	assert.Equal(t, srv.URL+"/shonenjump/one-punch-man-chapter-170.5/chapter/31501", ch.URL)
+	assert.False(t, ch.Free)
+}
+
+func TestGetSeries_layoutChanged(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/shonenjump/chapters/one-punch-man": "series-layout-changed.html",
+	})
+	c := newClient(t, srv, "", "")
+
+	_, err := c.GetSeries("one-punch-man")
+	assert.ErrorIs(t, err, vizapi.ErrUnexpectedLayout)
+}
+
+func TestGetSeries_notFound(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{})
+	c := newClient(t, srv, "", "")
+
+	_, err := c.GetSeries("one-punch-man")
+	assert.ErrorContains(t, err, "404")
+}
+
+func TestGetSeriesIndex(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/read/shonenjump/section/free-chapters": "series-index.html",
+	})
+	c := newClient(t, srv, "", "")
+
+	series, err := c.GetSeriesIndex()
+	require.NoError(t, err)
+
+	assert.Equal(t, []*vizapi.SeriesLink{
+		{Slug: "one-piece", Title: "One Piece", URL: srv.URL + "/shonenjump/chapters/one-piece"},
+		{Slug: "one-punch-man", Title: "One-Punch Man", URL: srv.URL + "/shonenjump/chapters/one-punch-man"},
+		{Slug: "my-hero-academia", Title: "My Hero Academia", URL: srv.URL + "/shonenjump/chapters/my-hero-academia"},
+	}, series)
+}
+
+func TestGetSeriesIndex_layoutChanged(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/read/shonenjump/section/free-chapters": "series-layout-changed.html",
+	})
+	c := newClient(t, srv, "", "")
+
+	_, err := c.GetSeriesIndex()
+	assert.ErrorIs(t, err, vizapi.ErrUnexpectedLayout)
+}
+
+func TestChapter_GetPageCount(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fixture string
+		want    int
+		wantErr error
+	}{
+		{"pages", "chapter.html", 19, nil},
+		{"no pages", "chapter-no-pages.html", 0, vizapi.ErrUnexpectedLayout},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := fixtureServer(t, map[string]string{
+				"/shonenjump/chapters/one-punch-man":                  "series.html",
+				"/shonenjump/one-punch-man-chapter-171/chapter/31552": tc.fixture,
+			})
+			c := newClient(t, srv, "", "")
+			series, err := c.GetSeries("one-punch-man")
+			require.NoError(t, err)
+
+			count, err := series.Chapters[0].GetPageCount()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, count)
+		})
+	}
+}
+
+func TestGetMangaURL(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/manga/get_manga_url": "get_manga_url.json",
+		"/metadata/31552.json": "metadata.json",
+	})
+	c := newClient(t, srv, "", "")
+
+	urls, err := c.GetMangaURL(31552, []int{0, 1, 2})
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{
+		0: srv.URL + "/images/31552/0.jpg",
+		1: srv.URL + "/images/31552/1.jpg",
+		2: srv.URL + "/images/31552/2.jpg",
+	}, urls.Data)
+
+	meta, err := urls.GetMetadata()
+	require.NoError(t, err)
+	assert.Equal(t, "One-Punch Man, Chapter 171", meta.Title)
+	assert.Equal(t, 1600, meta.Width)
+	assert.Equal(t, 2400, meta.Height)
+	assert.Equal(t, []int{6, 12}, meta.Spreads)
+}
+
+func TestGetMangaURL_notOK(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/manga/get_manga_url": "get_manga_url-not-ok.json",
+	})
+	c := newClient(t, srv, "", "")
+
+	_, err := c.GetMangaURL(31552, []int{0})
+	assert.ErrorIs(t, err, vizapi.ErrNotOK)
+}
+
+func TestEnsureSession_noCredentials(t *testing.T) {
+	srv := fixtureServer(t, map[string]string{
+		"/account/refresh_login_links": "refresh_login_links.html",
+	})
+	c := newClient(t, srv, "", "")
+
+	err := c.EnsureSession()
+	require.NoError(t, err)
+	assert.False(t, c.HasCredentials())
+	assert.False(t, c.LoggedIn())
+	assert.ErrorIs(t, c.Reauthenticate(), vizapi.ErrLoginRequired)
+}
+
+func TestEnsureSession_login(t *testing.T) {
+	loggedIn := false
+	expires := time.Now().Add(30 * 24 * time.Hour).Truncate(time.Second)
+	fixtures := fixtureServer(t, map[string]string{
+		"/account/refresh_login_links": "refresh_login_links.html",
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/account/try_login":
+			if err := r.ParseForm(); !assert.NoError(t, err) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			assert.Equal(t, "user@example.com", r.Form.Get("login"))
+			assert.Equal(t, "hunter2", r.Form.Get("pass"))
+			loggedIn = true
+			http.SetCookie(w, &http.Cookie{Name: "remember_token", Value: "token", Expires: expires})
+		case "/account/refresh_login_links":
+			fixture := "refresh_login_links.html"
+			if loggedIn {
+				fixture = "refresh_login_links-logged-in.html"
+			}
+			b, err := os.ReadFile(filepath.Join("testdata", fixture))
+			if !assert.NoError(t, err) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write(b)
+		default:
+			http.Redirect(w, r, fixtures.URL+r.URL.Path, http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	cookieFile := filepath.Join(t.TempDir(), "cookies", "viz.json")
+	c, err := vizapi.New("user@example.com", "hunter2", cookieFile,
+		vizapi.WithBaseURL(srv.URL),
+		vizapi.WithTransport(http.DefaultTransport),
+	)
+	require.NoError(t, err)
+
+	err = c.EnsureSession()
+	require.NoError(t, err)
+	assert.True(t, c.LoggedIn())
+	assert.True(t, expires.Equal(c.SessionExpires()))
+
+	stat, err := os.Stat(cookieFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+
+	// a new client picks up the saved session
+	c, err = vizapi.New("user@example.com", "hunter2", cookieFile,
+		vizapi.WithBaseURL(srv.URL),
+		vizapi.WithTransport(http.DefaultTransport),
+	)
+	require.NoError(t, err)
+	assert.True(t, expires.Equal(c.SessionExpires()))
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/abibby/manga/services/httpratelimit"
@@ -20,12 +21,36 @@ type Client struct {
 	username string
 	password string
 	csrf     string
+	loggedIn bool
 
 	cookieFile string
+	session    *session
 	baseURL    string
 }
 
-func New(username, password, cookieFile string) (*Client, error) {
+const DefaultBaseURL = "https://www.viz.com"
+
+type Option func(c *Client)
+
+// WithBaseURL points the client at a different host, it is used to test
+// against saved pages.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTransport replaces the default rate limited transport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = transport
+	}
+}
+
+// New creates a viz client, no requests are made until the client is used.
+// The username and password may be empty in which case only free chapters are
+// available.
+func New(username, password, cookieFile string, opts ...Option) (*Client, error) {
 	jar, err := cookiejar.New(&cookiejar.Options{})
 	if err != nil {
 		return nil, err
@@ -39,9 +64,13 @@ func New(username, password, cookieFile string) (*Client, error) {
 		},
 		jar:        jar,
 		cookieFile: cookieFile,
-		baseURL:    "https://www.viz.com",
+		baseURL:    DefaultBaseURL,
 		username:   username,
 		password:   password,
+		session:    &session{},
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 	err = c.loadCookies()
 	if err != nil {
@@ -76,10 +105,10 @@ func (c *Client) doRequest(r *http.Request) (*http.Response, error) {
 	r.Header.Add("accept-language", "en-US,en;q=0.9,fr-CA;q=0.8,fr;q=0.7,en-CA;q=0.6")
 	r.Header.Add("cache-control", "no-cache")
 	r.Header.Add("content-type", "application/x-www-form-urlencoded; charset=UTF-8")
-	r.Header.Add("origin", "https://www.viz.com")
+	r.Header.Add("origin", c.baseURL)
 	r.Header.Add("pragma", "no-cache")
 	r.Header.Add("priority", "u=1, i")
-	r.Header.Add("referer", "https://www.viz.com/")
+	r.Header.Add("referer", c.baseURL+"/")
 	r.Header.Add("sec-ch-ua", `"Not/A)Brand";v="8", "Chromium";v="126", "Google Chrome";v="126"`)
 	r.Header.Add("sec-ch-ua-mobile", "?0")
 	r.Header.Add("sec-ch-ua-platform", `"macOS"`)
@@ -96,6 +125,7 @@ func (c *Client) doRequest(r *http.Request) (*http.Response, error) {
 		return nil, err
 	}
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
 		return nil, fmt.Errorf("request %s %s failed: %s", r.Method, r.URL, resp.Status)
 	}
 	return resp, nil
@@ -1,45 +1,89 @@
 package vizapi
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
-	"net/http"
 	"net/url"
-	"os"
 	"regexp"
+	"time"
+)
+
+var ErrLoginRequired = errors.New("viz login required")
+
+// expiryMargin is how long before the recorded cookie expiry a new login is
+// made.
+const expiryMargin = time.Hour
+
+var (
+	csrfRE     = regexp.MustCompile(`var AUTH_TOKEN = "([a-zA-Z0-9\+\/=]+)";`)
+	loggedInRE = regexp.MustCompile(`(?i)/account/logout|log\s?out`)
 )
 
 func (c *Client) loadCookies() error {
-	b, err := os.ReadFile(c.cookieFile)
-	if os.IsNotExist(err) {
-		return c.Reauthenticate()
-	} else if err != nil {
+	s, err := loadSession(c.cookieFile)
+	if err != nil {
 		return err
 	}
-	cookies := []*http.Cookie{}
-	err = json.Unmarshal(b, &cookies)
+	u, err := url.Parse(c.baseURL + "/")
 	if err != nil {
 		return err
 	}
-	u, err := url.Parse("https://www.viz.com/")
+	c.jar.SetCookies(u, s.Cookies)
+	c.session = s
+	return nil
+}
+
+// HasCredentials reports if a username and password have been configured.
+// Without them only free chapters can be read.
+func (c *Client) HasCredentials() bool {
+	return c.username != "" && c.password != ""
+}
+
+// LoggedIn reports if the last check of refresh_login_links found an active
+// login.
+func (c *Client) LoggedIn() bool {
+	return c.loggedIn
+}
+
+// SessionExpires returns when the current login expires, it is the zero time
+// if the expiry is unknown.
+func (c *Client) SessionExpires() time.Time {
+	return c.session.Expires
+}
+
+// EnsureSession fetches a csrf token and makes sure the client is logged in,
+// logging in again if the session has expired or been logged out. Clients
+// without credentials are left logged out.
+func (c *Client) EnsureSession() error {
+	if c.HasCredentials() && c.session.expired(expiryMargin) {
+		slog.Info("Viz session expired", "expires", c.session.Expires)
+		return c.Reauthenticate()
+	}
+
+	err := c.refreshLoginLinks()
 	if err != nil {
 		return err
 	}
-	c.jar.SetCookies(u, cookies)
-
-	return c.updateCSRF()
+	if c.HasCredentials() && !c.loggedIn {
+		return c.Reauthenticate()
+	}
+	return nil
 }
 
 func (c *Client) Reauthenticate() error {
+	if !c.HasCredentials() {
+		return ErrLoginRequired
+	}
+
 	slog.Info("Viz new login")
-	err := c.updateCSRF()
+	err := c.refreshLoginLinks()
 	if err != nil {
 		return err
 	}
 
-	resp, err := c.postForm("https://www.viz.com/account/try_login", url.Values{
+	resp, err := c.postForm(c.baseURL+"/account/try_login", url.Values{
 		"login": []string{c.username},
 		"pass":  []string{c.password},
 		"uid":   []string{"0"},
@@ -48,26 +92,30 @@ func (c *Client) Reauthenticate() error {
 		return err
 	}
 	defer resp.Body.Close()
+	expires := sessionExpiry(resp.Cookies())
 
-	u, err := url.Parse("https://www.viz.com/")
+	err = c.refreshLoginLinks()
 	if err != nil {
 		return err
 	}
-	cookies := c.jar.Cookies(u)
+	if !c.loggedIn {
+		return fmt.Errorf("viz login failed for %s", c.username)
+	}
 
-	b, err := json.MarshalIndent(cookies, "", "    ")
+	u, err := url.Parse(c.baseURL + "/")
 	if err != nil {
 		return err
 	}
-	err = os.WriteFile(c.cookieFile, b, 0666)
-	if err != nil {
-		return err
+	c.session = &session{
+		Expires: expires,
+		Cookies: c.jar.Cookies(u),
 	}
-	return nil
+	return c.session.save(c.cookieFile)
 }
 
-func (c *Client) updateCSRF() error {
-	resp, err := c.get("https://www.viz.com/account/refresh_login_links")
+// refreshLoginLinks updates the csrf token and the logged in state.
+func (c *Client) refreshLoginLinks() error {
+	resp, err := c.get(c.baseURL + "/account/refresh_login_links")
 	if err != nil {
 		return err
 	}
@@ -77,10 +125,11 @@ func (c *Client) updateCSRF() error {
 		return err
 	}
 
-	csrf := regexp.MustCompile(`var AUTH_TOKEN = "([a-zA-Z0-9\+\/=]+)";`).FindSubmatch(b)
+	csrf := csrfRE.FindSubmatch(b)
 	if len(csrf) == 0 {
 		return fmt.Errorf("no csrf token")
 	}
 	c.csrf = string(csrf[1])
+	c.loggedIn = loggedInRE.Match(b)
 	return nil
 }
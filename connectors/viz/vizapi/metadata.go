@@ -0,0 +1,111 @@
+package vizapi
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Credit is a person credited on a series, Role is what comes before "by" in
+// credits like "Story and Art by Eiichiro Oda".
+type Credit struct {
+	Role string
+	Name string
+}
+
+var (
+	creditRE  = regexp.MustCompile(`(?i)^\s*(.*?)\s*\bby\s+(.+?)\s*$`)
+	releaseRE = regexp.MustCompile(`[A-Z][a-z]+\.? \d{1,2}, \d{4}`)
+	spaceRE   = regexp.MustCompile(`\s+`)
+)
+
+var releaseLayouts = []string{
+	"January 2, 2006",
+	"Jan 2, 2006",
+	"Jan. 2, 2006",
+}
+
+// Author returns the names of everyone credited on the series.
+func (s *SeriesInfo) Author() string {
+	names := []string{}
+	for _, credit := range s.Credits {
+		names = appendUnique(names, strings.Split(credit.Name, ", ")...)
+	}
+	return strings.Join(names, ", ")
+}
+
+func seriesCredits(intro *goquery.Selection) []*Credit {
+	credits := []*Credit{}
+	intro.Find(".disp-bl--bm, .creator, .author").Each(func(i int, sel *goquery.Selection) {
+		for _, line := range strings.Split(sel.Text(), "\n") {
+			m := creditRE.FindStringSubmatch(cleanText(line))
+			if m == nil {
+				continue
+			}
+			role := m[1]
+			if role == "" {
+				role = "Created"
+			}
+			names := strings.ReplaceAll(m[2], " and ", ", ")
+			credits = append(credits, &Credit{
+				Role: role,
+				Name: names,
+			})
+		}
+	})
+	return credits
+}
+
+func seriesDescription(intro *goquery.Selection) string {
+	desc := intro.Find(".series-desc, p").FilterFunction(func(i int, sel *goquery.Selection) bool {
+		return cleanText(sel.Text()) != ""
+	}).First()
+	return cleanText(desc.Text())
+}
+
+// seriesImage returns the series art, the open graph image is the key art
+// used when the page is shared and the intro image is the fallback.
+func seriesImage(d *goquery.Document) string {
+	if img := d.Find(`meta[property="og:image"]`).AttrOr("content", ""); img != "" {
+		return strings.TrimSpace(img)
+	}
+	return strings.TrimSpace(d.Find("#series-intro img").First().AttrOr("src", ""))
+}
+
+func chapterTitle(node *goquery.Selection) string {
+	return cleanText(node.Find(".chapter-title, [class*=title]").First().Text())
+}
+
+func chapterReleased(node *goquery.Selection) time.Time {
+	date := releaseRE.FindString(node.Text())
+	if date == "" {
+		return time.Time{}
+	}
+	for _, layout := range releaseLayouts {
+		t, err := time.Parse(layout, date)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func cleanText(s string) string {
+	return strings.TrimSpace(spaceRE.ReplaceAllString(s, " "))
+}
+
+func appendUnique(arr []string, values ...string) []string {
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !slices.Contains(arr, v) {
+			arr = append(arr, v)
+		}
+	}
+	return arr
+}
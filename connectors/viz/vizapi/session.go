@@ -0,0 +1,87 @@
+package vizapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// session is the on disk format of the viz cookie store.
+type session struct {
+	Expires time.Time      `json:"expires"`
+	Cookies []*http.Cookie `json:"cookies"`
+}
+
+// loadSession reads the cookie store, files written before expiry tracking
+// was added only contain the list of cookies.
+func loadSession(file string) (*session, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return &session{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	s := &session{}
+	err = json.Unmarshal(b, s)
+	if err == nil {
+		return s, nil
+	}
+
+	cookies := []*http.Cookie{}
+	legacyErr := json.Unmarshal(b, &cookies)
+	if legacyErr != nil {
+		return nil, err
+	}
+	return &session{Cookies: cookies}, nil
+}
+
+func (s *session) save(file string) error {
+	b, err := json.MarshalIndent(s, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(file, b, 0600)
+	if err != nil {
+		return err
+	}
+	// WriteFile only applies the permissions when it creates the file
+	return os.Chmod(file, 0600)
+}
+
+// expired reports if the session will expire within the given margin. A
+// session with no known expiry never expires, refresh_login_links is used to
+// detect those.
+func (s *session) expired(margin time.Duration) bool {
+	if s.Expires.IsZero() {
+		return false
+	}
+	return time.Now().Add(margin).After(s.Expires)
+}
+
+// sessionExpiry returns the earliest expiry of the cookies set by the login
+// response, session cookies are ignored.
+func sessionExpiry(cookies []*http.Cookie) time.Time {
+	expires := time.Time{}
+	for _, cookie := range cookies {
+		var e time.Time
+		if cookie.MaxAge > 0 {
+			e = time.Now().Add(time.Duration(cookie.MaxAge) * time.Second)
+		} else if !cookie.Expires.IsZero() {
+			e = cookie.Expires
+		} else {
+			continue
+		}
+		if expires.IsZero() || e.Before(expires) {
+			expires = e
+		}
+	}
+	return expires
+}
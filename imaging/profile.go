@@ -0,0 +1,243 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"slices"
+	"strings"
+
+	_ "image/gif"
+
+	_ "golang.org/x/image/bmp"
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+)
+
+// DefaultQuality is the jpeg quality used when a profile changes a page and
+// doesn't set one.
+const DefaultQuality = 90
+
+// grayLevels is the number of shades e-ink screens can show, dithered pages
+// are reduced to this many grays.
+const grayLevels = 16
+
+// Resolution is the screen size of a device in portrait.
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// Devices are the screens a profile can be sized for with device.
+var Devices = map[string]Resolution{
+	"kobo":                 {1072, 1448},
+	"kobo-clara":           {1072, 1448},
+	"kobo-libra":           {1264, 1680},
+	"kobo-sage":            {1440, 1920},
+	"kobo-elipsa":          {1404, 1872},
+	"kindle":               {1236, 1648},
+	"kindle-basic":         {1072, 1448},
+	"kindle-paperwhite":    {1236, 1648},
+	"kindle-oasis":         {1264, 1680},
+	"kindle-scribe":        {1860, 2480},
+	"remarkable":           {1404, 1872},
+	"remarkable-2":         {1404, 1872},
+	"remarkable-paper-pro": {1620, 2160},
+}
+
+// Profile is a set of changes made to pages after they are downloaded and
+// before they are archived. The zero Profile leaves pages untouched.
+type Profile struct {
+	// Device sizes pages for one of the Devices, Width and Height override
+	// it
+	Device string
+	// Width and Height are the box pages are shrunk to fit in, 0 doesn't
+	// limit that side. Spreads are fit in the box turned sideways.
+	Width  int
+	Height int
+	// Grayscale converts pages to gray, Dither also reduces them to the 16
+	// shades of an e-ink screen
+	Grayscale bool
+	Dither    bool
+	// Quality recompresses jpeg pages, it is also the quality of pages
+	// converted to jpeg
+	Quality int
+	// Convert is the format lossless pages (png, gif and bmp) are saved as,
+	// jpeg or png. They are kept as png when it isn't set. Converting to webp
+	// was dropped since there is no webp encoder in the standard library or
+	// golang.org/x/image.
+	Convert string
+}
+
+// Validate checks the device and formats are known.
+func (p *Profile) Validate() error {
+	if p.Device != "" {
+		if _, ok := Devices[strings.ToLower(p.Device)]; !ok {
+			return fmt.Errorf("unknown device %q, expected one of %s", p.Device, strings.Join(DeviceNames(), ", "))
+		}
+	}
+	if p.Width < 0 || p.Height < 0 {
+		return fmt.Errorf("width and height can't be negative")
+	}
+	if p.Quality < 0 || p.Quality > 100 {
+		return fmt.Errorf("quality must be between 1 and 100, got %d", p.Quality)
+	}
+	switch strings.ToLower(p.Convert) {
+	case "", FormatPNG, FormatJPEG:
+	case "webp":
+		return fmt.Errorf("pages can't be converted to webp, use jpeg or png")
+	default:
+		return fmt.Errorf("unknown convert format %q, expected jpeg or png", p.Convert)
+	}
+	return nil
+}
+
+// DeviceNames returns the sorted names of the Devices.
+func DeviceNames() []string {
+	names := make([]string, 0, len(Devices))
+	for name := range Devices {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// box returns the size pages are fit in, 0 is unlimited.
+func (p *Profile) box() (int, int) {
+	r := Devices[strings.ToLower(p.Device)]
+	if p.Width != 0 {
+		r.Width = p.Width
+	}
+	if p.Height != 0 {
+		r.Height = p.Height
+	}
+	return r.Width, r.Height
+}
+
+// fit returns the size of a w by h page after it is shrunk into the box.
+// Pages are never enlarged.
+func (p *Profile) fit(w, h int) (int, int) {
+	bw, bh := p.box()
+	if w > h && bw != 0 && bw < bh {
+		bw, bh = bh, bw
+	}
+	scale := 1.0
+	if bw != 0 {
+		scale = min(scale, float64(bw)/float64(w))
+	}
+	if bh != 0 {
+		scale = min(scale, float64(bh)/float64(h))
+	}
+	if scale >= 1 {
+		return w, h
+	}
+	return max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+}
+
+// Process runs the profile on an encoded image and returns the new image
+// and its format. Images the profile wouldn't change are returned as is.
+func (p *Profile) Process(b []byte) ([]byte, string, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+
+	w, h := p.fit(cfg.Width, cfg.Height)
+	resize := w != cfg.Width || h != cfg.Height
+	gray := (p.Grayscale || p.Dither) && cfg.ColorModel != color.GrayModel
+	recompress := format == FormatJPEG && p.Quality != 0
+
+	target := format
+	switch format {
+	case FormatPNG, "gif", "bmp":
+		target = FormatPNG
+		if p.Convert != "" {
+			target = strings.ToLower(p.Convert)
+		}
+	}
+	if !resize && !gray && !p.Dither && !recompress && target == format {
+		return b, format, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, "", err
+	}
+	if resize {
+		img = scale(img, w, h)
+	}
+	if p.Grayscale || p.Dither {
+		img = toGray(img)
+	}
+	if p.Dither {
+		img = dither(img.(*image.Gray))
+	}
+
+	buf := &bytes.Buffer{}
+	switch target {
+	case FormatJPEG:
+		quality := p.Quality
+		if quality == 0 {
+			quality = DefaultQuality
+		}
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: quality})
+	default:
+		target = FormatPNG
+		err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(buf, img)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), target, nil
+}
+
+func scale(src image.Image, w, h int) image.Image {
+	rect := image.Rect(0, 0, w, h)
+	var dst draw.Image
+	if _, ok := src.(*image.Gray); ok {
+		dst = image.NewGray(rect)
+	} else {
+		dst = image.NewRGBA(rect)
+	}
+	xdraw.CatmullRom.Scale(dst, rect, src, src.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+func toGray(src image.Image) *image.Gray {
+	if g, ok := src.(*image.Gray); ok {
+		return g
+	}
+	b := src.Bounds()
+	dst := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// dither reduces a gray image to grayLevels shades with Floyd-Steinberg
+// error diffusion.
+func dither(src *image.Gray) *image.Gray {
+	palette := make(color.Palette, grayLevels)
+	step := 255 / (grayLevels - 1)
+	for i := range palette {
+		palette[i] = color.Gray{Y: uint8(i * step)}
+	}
+	b := src.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), src, b.Min)
+
+	dst := image.NewGray(paletted.Bounds())
+	for i, idx := range paletted.Pix {
+		dst.Pix[i] = uint8(int(idx) * step)
+	}
+	return dst
+}
@@ -0,0 +1,126 @@
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	require.NoError(t, png.Encode(buf, img))
+	return buf.Bytes()
+}
+
+func colorPage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 200, 255})
+		}
+	}
+	return img
+}
+
+func TestProfile_fit(t *testing.T) {
+	testCases := []struct {
+		name    string
+		profile *Profile
+		w, h    int
+		wantW   int
+		wantH   int
+	}{
+		{"no box", &Profile{}, 2000, 3000, 2000, 3000},
+		{"device", &Profile{Device: "kobo-clara"}, 2144, 2896, 1072, 1448},
+		{"height limits", &Profile{Device: "Kindle-Scribe"}, 1000, 4960, 500, 2480},
+		{"spread", &Profile{Device: "kobo-clara"}, 2896, 2144, 1448, 1072},
+		{"never enlarged", &Profile{Device: "remarkable"}, 800, 1200, 800, 1200},
+		{"width overrides device", &Profile{Device: "kobo", Width: 536}, 1072, 1448, 536, 724},
+		{"only height", &Profile{Height: 100}, 400, 200, 200, 100},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, h := tc.profile.fit(tc.w, tc.h)
+			assert.Equal(t, tc.wantW, w)
+			assert.Equal(t, tc.wantH, h)
+		})
+	}
+}
+
+func TestProfile_Validate(t *testing.T) {
+	assert.NoError(t, (&Profile{Device: "kobo-libra", Grayscale: true, Quality: 80, Convert: "jpeg"}).Validate())
+	assert.ErrorContains(t, (&Profile{Convert: "webp"}).Validate(), "can't be converted to webp")
+	assert.Error(t, (&Profile{Device: "nook"}).Validate())
+	assert.Error(t, (&Profile{Quality: 101}).Validate())
+	assert.Error(t, (&Profile{Width: -1}).Validate())
+	assert.Error(t, (&Profile{Convert: "avif"}).Validate())
+}
+
+func TestProfile_Process_unchanged(t *testing.T) {
+	b := encodePNG(t, colorPage(100, 150))
+	out, format, err := (&Profile{Device: "kobo"}).Process(b)
+	require.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+	assert.Equal(t, b, out)
+}
+
+func TestProfile_Process(t *testing.T) {
+	src := colorPage(400, 600)
+	jpg := &bytes.Buffer{}
+	require.NoError(t, jpeg.Encode(jpg, src, &jpeg.Options{Quality: 100}))
+
+	testCases := []struct {
+		name       string
+		profile    *Profile
+		src        []byte
+		wantFormat string
+		wantW      int
+		wantH      int
+		wantModel  color.Model
+	}{
+		{"resize png", &Profile{Width: 200}, encodePNG(t, src), FormatPNG, 200, 300, color.RGBAModel},
+		{"grayscale jpeg", &Profile{Grayscale: true}, jpg.Bytes(), FormatJPEG, 400, 600, color.GrayModel},
+		{"recompress jpeg", &Profile{Quality: 50}, jpg.Bytes(), FormatJPEG, 400, 600, color.YCbCrModel},
+		{"png to jpeg", &Profile{Convert: "jpeg"}, encodePNG(t, src), FormatJPEG, 400, 600, color.YCbCrModel},
+		{"dithered png", &Profile{Convert: "png", Height: 300, Dither: true}, encodePNG(t, src), FormatPNG, 200, 300, color.GrayModel},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, format, err := tc.profile.Process(tc.src)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFormat, format)
+
+			cfg, decodedFormat, err := image.DecodeConfig(bytes.NewReader(out))
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantFormat, decodedFormat)
+			assert.Equal(t, tc.wantW, cfg.Width)
+			assert.Equal(t, tc.wantH, cfg.Height)
+			assert.Equal(t, tc.wantModel, cfg.ColorModel)
+		})
+	}
+	recompressed, _, err := (&Profile{Quality: 50}).Process(jpg.Bytes())
+	require.NoError(t, err)
+	assert.Less(t, len(recompressed), jpg.Len())
+}
+
+func TestDither(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(i % 64 * 4)
+	}
+	got := dither(src)
+	shades := map[uint8]bool{}
+	for _, y := range got.Pix {
+		assert.Zero(t, y%17)
+		shades[y] = true
+	}
+	assert.LessOrEqual(t, len(shades), grayLevels)
+	assert.Greater(t, len(shades), 2)
+}
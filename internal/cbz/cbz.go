@@ -0,0 +1,72 @@
+// Package cbz has the archive helpers shared by the downloader and the
+// library.
+package cbz
+
+import (
+	"archive/zip"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var imageExts = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp"}
+
+// IsImage returns true if the name has the extension of a page image.
+func IsImage(name string) bool {
+	return slices.Contains(imageExts, strings.ToLower(path.Ext(name)))
+}
+
+// WriteEntry adds a compressed entry to an archive.
+func WriteEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// WriteFile writes an archive with write to a temp file next to file and
+// renames it over file, so the archive is never left half written.
+func WriteFile(file string, mode os.FileMode, write func(zw *zip.Writer) error) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	zw := zip.NewWriter(tmp)
+	err = write(zw)
+	if err != nil {
+		return err
+	}
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), mode)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
@@ -0,0 +1,46 @@
+package cbz
+
+import (
+	"archive/zip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsImage(t *testing.T) {
+	assert.True(t, IsImage("001.JPG"))
+	assert.True(t, IsImage("/pages/002.webp"))
+	assert.False(t, IsImage("book.json"))
+	assert.False(t, IsImage("jpg"))
+}
+
+func TestWriteFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "book.cbz")
+	require.NoError(t, WriteFile(file, 0600, func(zw *zip.Writer) error {
+		return WriteEntry(zw, "book.json", []byte("{}"))
+	}))
+
+	stat, err := os.Stat(file)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), stat.Mode().Perm())
+	zr, err := zip.OpenReader(file)
+	require.NoError(t, err)
+	defer zr.Close()
+	require.Len(t, zr.File, 1)
+	assert.Equal(t, "book.json", zr.File[0].Name)
+	assert.Equal(t, zip.Deflate, zr.File[0].Method)
+
+	// a failed write leaves the old file and no temp file behind
+	err = WriteFile(file, 0644, func(zw *zip.Writer) error {
+		return errors.New("broken")
+	})
+	assert.EqualError(t, err, "broken")
+	entries, err := os.ReadDir(filepath.Dir(file))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "book.cbz", entries[0].Name())
+}
@@ -0,0 +1,39 @@
+// Package testutil has fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/require"
+)
+
+// WriteBook writes a cbz the way site.zipit does, with a leading slash on
+// every entry. The pages are tiny fake jpegs whose third byte is the page
+// number, they are written in reverse so readers have to sort them.
+func WriteBook(t *testing.T, file string, info *site.BookInfo, pages int) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for i := pages - 1; i >= 0; i-- {
+		w, err := zw.Create(fmt.Sprintf("/%03d.jpg", i))
+		require.NoError(t, err)
+		_, err = w.Write([]byte{0xff, 0xd8, byte(i)})
+		require.NoError(t, err)
+	}
+	if info != nil {
+		w, err := zw.Create("/book.json")
+		require.NoError(t, err)
+		require.NoError(t, json.NewEncoder(w).Encode(info))
+	}
+	require.NoError(t, zw.Close())
+}
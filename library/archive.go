@@ -0,0 +1,142 @@
+package library
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/abibby/manga/internal/cbz"
+	"github.com/abibby/manga/site"
+)
+
+// Archive is an open cbz file.
+type Archive struct {
+	zr    *zip.ReadCloser
+	pages []*zip.File
+	files map[string]*zip.File
+}
+
+func OpenArchive(file string) (*Archive, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Archive{
+		zr:    zr,
+		pages: []*zip.File{},
+		files: map[string]*zip.File{},
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := EntryName(f)
+		a.files[name] = f
+		if cbz.IsImage(name) {
+			a.pages = append(a.pages, f)
+		}
+	}
+	slices.SortFunc(a.pages, func(x, y *zip.File) int {
+		return NaturalCompare(EntryName(x), EntryName(y))
+	})
+	return a, nil
+}
+
+func (b *Book) Open() (*Archive, error) {
+	return OpenArchive(b.Path)
+}
+
+func (a *Archive) Close() error {
+	return a.zr.Close()
+}
+
+// Pages returns the images in the archive in page order.
+func (a *Archive) Pages() []*zip.File {
+	return a.pages
+}
+
+// Page returns the page at index i.
+func (a *Archive) Page(i int) (*zip.File, error) {
+	if i < 0 || i >= len(a.pages) {
+		return nil, fmt.Errorf("page %d: %w", i, ErrNotFound)
+	}
+	return a.pages[i], nil
+}
+
+// File returns the entry with the given name.
+func (a *Archive) File(name string) (*zip.File, bool) {
+	f, ok := a.files[name]
+	return f, ok
+}
+
+// Info returns the contents of book.json, it returns nil if the archive
+// doesn't have one.
+func (a *Archive) Info() (*site.BookInfo, error) {
+	f, ok := a.files["book.json"]
+	if !ok {
+		return nil, nil
+	}
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	info := &site.BookInfo{}
+	err = json.NewDecoder(r).Decode(info)
+	if err != nil {
+		return nil, fmt.Errorf("invalid book.json: %w", err)
+	}
+	return info, nil
+}
+
+// ReadFile reads the whole entry into memory.
+func ReadFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// EntryName returns the name of a zip entry without the leading slash older
+// archives were written with.
+func EntryName(f *zip.File) string {
+	return strings.TrimPrefix(f.Name, "/")
+}
+
+// ContentType returns the mime type of an image entry.
+func ContentType(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".bmp":
+		return "image/bmp"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// Cover returns the front cover, page 000 when the archive has one and the
+// first page otherwise.
+func (a *Archive) Cover() (*zip.File, error) {
+	for _, f := range a.pages {
+		name := path.Base(EntryName(f))
+		if strings.TrimSuffix(name, path.Ext(name)) == "000" {
+			return f, nil
+		}
+	}
+	return a.Page(0)
+}
@@ -0,0 +1,65 @@
+package library
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// ServePage writes page i of a book.
+func ServePage(w http.ResponseWriter, r *http.Request, b *Book, i int) {
+	serveArchiveFile(w, r, b, func(a *Archive) (*zip.File, error) {
+		return a.Page(i)
+	})
+}
+
+// ServeCover writes the cover of a book.
+func ServeCover(w http.ResponseWriter, r *http.Request, b *Book) {
+	serveArchiveFile(w, r, b, (*Archive).Cover)
+}
+
+func serveArchiveFile(w http.ResponseWriter, r *http.Request, b *Book, find func(a *Archive) (*zip.File, error)) {
+	a, err := b.Open()
+	if err != nil {
+		HTTPError(w, err)
+		return
+	}
+	defer a.Close()
+
+	f, err := find(a)
+	if err != nil {
+		HTTPError(w, err)
+		return
+	}
+	ServeEntry(w, r, f, b.ModTime)
+}
+
+// ServeEntry writes a file from inside an archive without extracting it to
+// disk. The entry's crc is used as the etag so clients can cache pages until
+// the archive is replaced.
+func ServeEntry(w http.ResponseWriter, r *http.Request, f *zip.File, modTime time.Time) {
+	b, err := ReadFile(f)
+	if err != nil {
+		HTTPError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentType(EntryName(f)))
+	w.Header().Set("ETag", fmt.Sprintf(`"%08x-%d"`, f.CRC32, f.UncompressedSize64))
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeContent(w, r, EntryName(f), modTime, bytes.NewReader(b))
+}
+
+// HTTPError writes err with 404 for missing series, books and pages.
+func HTTPError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	slog.Error("Request failed", "err", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
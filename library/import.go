@@ -0,0 +1,275 @@
+package library
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/internal/cbz"
+	"github.com/abibby/manga/site"
+)
+
+var lastNumberRE = regexp.MustCompile(`(?i)(?:^|[^\d.])c?(\d+(?:\.\d+)?)\s*$`)
+
+// Entry is a book in a series folder, either a cbz or a folder of images
+// with a book.json.
+type Entry struct {
+	Series string
+	// Rel is the path relative to the library dir
+	Rel     string
+	Path    string
+	IsDir   bool
+	ModTime time.Time
+}
+
+// Entries returns every cbz and folder of images in the series folders passed,
+// or in every series folder if none are passed. Folders without a book.json,
+// or named by the naming template, are skipped since they are downloads that
+// haven't finished and will be picked up again by the downloader.
+func (l *Library) Entries(naming *site.Naming, series ...string) ([]*Entry, error) {
+	if len(series) == 0 {
+		dirs, err := os.ReadDir(l.dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return []*Entry{}, nil
+		} else if err != nil {
+			return nil, err
+		}
+		for _, d := range dirs {
+			if d.IsDir() && !strings.HasPrefix(d.Name(), ".") {
+				series = append(series, d.Name())
+			}
+		}
+	}
+
+	entries := []*Entry{}
+	for _, s := range series {
+		if !validName(s) {
+			return nil, fmt.Errorf("series %q: %w", s, ErrNotFound)
+		}
+		dir := filepath.Join(l.dir, s)
+		files, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("series %q: %w", s, ErrNotFound)
+		} else if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if strings.HasPrefix(f.Name(), ".") {
+				continue
+			}
+			path := filepath.Join(dir, f.Name())
+			if f.IsDir() {
+				if naming.Matches(s, f.Name()) {
+					continue
+				}
+				ok, err := isFinishedDownload(path)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+			} else if !strings.EqualFold(filepath.Ext(f.Name()), ".cbz") {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, &Entry{
+				Series:  s,
+				Rel:     s + "/" + f.Name(),
+				Path:    path,
+				IsDir:   f.IsDir(),
+				ModTime: info.ModTime(),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// Pack archives a folder entry into a cbz next to it and removes the folder so
+// the book can be recorded, the entry is changed to point at the cbz.
+func (e *Entry) Pack() error {
+	if !e.IsDir {
+		return nil
+	}
+	file := e.Path + ".cbz"
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("%s already exists", file)
+	}
+	files, err := os.ReadDir(e.Path)
+	if err != nil {
+		return err
+	}
+
+	err = cbz.WriteFile(file, 0644, func(zw *zip.Writer) error {
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(e.Path, f.Name()))
+			if err != nil {
+				return err
+			}
+			err = cbz.WriteEntry(zw, f.Name(), data)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(e.Path)
+	if err != nil {
+		return err
+	}
+
+	e.Path = file
+	e.Rel += ".cbz"
+	e.IsDir = false
+	return nil
+}
+
+// isFinishedDownload returns true if dir has images and the book.json that is
+// written once every page has been saved.
+func isFinishedDownload(dir string) (bool, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	images, info := false, false
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if cbz.IsImage(f.Name()) {
+			images = true
+		} else if f.Name() == "book.json" {
+			info = true
+		}
+	}
+	return images && info, nil
+}
+
+// Identity is what a book is, read from its book.json, ComicInfo.xml or name.
+type Identity struct {
+	Series  string  `json:"series"`
+	Volume  int     `json:"volume,omitempty"`
+	Chapter float64 `json:"chapter,omitempty"`
+	Title   string  `json:"title,omitempty"`
+	// BookID and Source are set when book.json records what the book was
+	// downloaded from
+	BookID string `json:"book_id,omitempty"`
+	Source string `json:"source,omitempty"`
+	// From is where the volume and chapter were read from, book.json,
+	// ComicInfo.xml or name
+	From string `json:"from"`
+}
+
+// comicInfo is the part of a ComicRack ComicInfo.xml that is used to
+// identify books.
+type comicInfo struct {
+	Series string `xml:"Series"`
+	Title  string `xml:"Title"`
+	Number string `xml:"Number"`
+	Volume int    `xml:"Volume"`
+}
+
+// Identify works out the series, volume and chapter of an entry. book.json is
+// preferred, then ComicInfo.xml and then the file name. The series defaults to
+// the name of the series folder.
+func (e *Entry) Identify() (*Identity, error) {
+	name := strings.TrimSuffix(filepath.Base(e.Path), filepath.Ext(e.Path))
+	if e.IsDir {
+		name = filepath.Base(e.Path)
+	}
+	id := &Identity{Series: e.Series}
+	volume, chapter, ok := ParseName(name)
+	if ok {
+		id.Volume, id.Chapter, id.From = volume, chapter, "name"
+	} else if m := lastNumberRE.FindStringSubmatch(name); m != nil {
+		// other tools often name books like "One Piece 021" or "c021"
+		id.Chapter, _ = strconv.ParseFloat(m[1], 64)
+		id.From = "name"
+	}
+
+	open := e.openDir
+	if !e.IsDir {
+		a, err := OpenArchive(e.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer a.Close()
+		open = func(name string) (io.ReadCloser, error) {
+			f, ok := a.File(name)
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return f.Open()
+		}
+	}
+
+	ci := &comicInfo{}
+	err := decodeFile(open, "ComicInfo.xml", func(r io.Reader) error { return xml.NewDecoder(r).Decode(ci) })
+	if errors.Is(err, os.ErrNotExist) {
+		ci = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid ComicInfo.xml: %w", err)
+	}
+	if ci != nil {
+		chapter, err := strconv.ParseFloat(strings.TrimSpace(ci.Number), 64)
+		if err == nil || ci.Volume != 0 {
+			id.Chapter, id.Volume, id.From = chapter, ci.Volume, "ComicInfo.xml"
+		}
+		id.Series = cmp.Or(ci.Series, id.Series)
+		id.Title = ci.Title
+	}
+
+	info := &site.BookInfo{}
+	err = decodeFile(open, "book.json", func(r io.Reader) error { return json.NewDecoder(r).Decode(info) })
+	if errors.Is(err, os.ErrNotExist) {
+		info = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("invalid book.json: %w", err)
+	}
+	if info != nil {
+		if info.Chapter != 0 || info.Volume != 0 {
+			id.Chapter, id.Volume, id.From = info.Chapter, info.Volume, "book.json"
+		}
+		id.Series = cmp.Or(info.Series, id.Series)
+		id.Title = cmp.Or(info.Title, id.Title)
+		id.BookID = info.ID
+		id.Source = info.Source
+	}
+
+	if id.From == "" && id.BookID == "" {
+		return nil, fmt.Errorf("could not find a volume or chapter number in %q", name)
+	}
+	return id, nil
+}
+
+func (e *Entry) openDir(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(e.Path, name))
+}
+
+func decodeFile(open func(name string) (io.ReadCloser, error), name string, decode func(r io.Reader) error) error {
+	r, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return decode(r)
+}
@@ -0,0 +1,132 @@
+package library
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/internal/testutil"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEntries(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteBook(t, filepath.Join(dir, "One Piece", "One Piece #1.cbz"), nil, 1)
+	writeFiles := func(folder string, files ...string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "One Piece", folder), 0755))
+		for _, f := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "One Piece", folder, f), []byte{}, 0644))
+		}
+	}
+	writeFiles("Chapter 2", "001.jpg", "book.json")
+	// pages from another tool, or a download that never finished
+	writeFiles("Chapter 3", "001.jpg")
+	// a download watch will finish, even if it got as far as book.json
+	writeFiles("One Piece #4", "000.jpg", "book.json")
+	writeFiles("extras")
+	writeFiles("", "notes.txt")
+
+	naming, err := site.ParseNaming("")
+	require.NoError(t, err)
+	entries, err := New(dir).Entries(naming)
+	require.NoError(t, err)
+
+	rel := []string{}
+	for _, e := range entries {
+		rel = append(rel, e.Rel)
+	}
+	assert.ElementsMatch(t, []string{"One Piece/One Piece #1.cbz", "One Piece/Chapter 2"}, rel)
+
+	_, err = New(dir).Entries(naming, "Naruto")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestIdentify(t *testing.T) {
+	dir := t.TempDir()
+
+	testCases := []struct {
+		name  string
+		setup func(t *testing.T, path string) *Entry
+		want  *Identity
+	}{
+		{
+			name: "name",
+			setup: func(t *testing.T, path string) *Entry {
+				file := filepath.Join(path, "One Piece V2 #12.cbz")
+				testutil.WriteBook(t, file, nil, 1)
+				return &Entry{Series: "One Piece", Path: file}
+			},
+			want: &Identity{Series: "One Piece", Volume: 2, Chapter: 12, From: "name"},
+		},
+		{
+			name: "trailing number",
+			setup: func(t *testing.T, path string) *Entry {
+				file := filepath.Join(path, "One Piece 012.5.cbz")
+				testutil.WriteBook(t, file, nil, 1)
+				return &Entry{Series: "One Piece", Path: file}
+			},
+			want: &Identity{Series: "One Piece", Chapter: 12.5, From: "name"},
+		},
+		{
+			name: "comic info",
+			setup: func(t *testing.T, path string) *Entry {
+				folder := filepath.Join(path, "op-12")
+				require.NoError(t, os.MkdirAll(folder, 0755))
+				require.NoError(t, os.WriteFile(filepath.Join(folder, "ComicInfo.xml"), []byte(`<?xml version="1.0"?>
+<ComicInfo><Series>One Piece</Series><Title>The Pirate</Title><Number>13</Number><Volume>2</Volume></ComicInfo>`), 0644))
+				return &Entry{Series: "OP", Path: folder, IsDir: true}
+			},
+			want: &Identity{Series: "One Piece", Title: "The Pirate", Volume: 2, Chapter: 13, From: "ComicInfo.xml"},
+		},
+		{
+			name: "book.json",
+			setup: func(t *testing.T, path string) *Entry {
+				file := filepath.Join(path, "renamed #99.cbz")
+				testutil.WriteBook(t, file, &site.BookInfo{ID: "abc", Source: "https://example.com", Series: "One Piece", Chapter: 14}, 1)
+				return &Entry{Series: "OP", Path: file}
+			},
+			want: &Identity{Series: "One Piece", Chapter: 14, BookID: "abc", Source: "https://example.com", From: "book.json"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name)
+			require.NoError(t, os.MkdirAll(path, 0755))
+			id, err := tc.setup(t, path).Identify()
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, id)
+		})
+	}
+
+	t.Run("unknown", func(t *testing.T) {
+		file := filepath.Join(dir, "extras.cbz")
+		testutil.WriteBook(t, file, nil, 1)
+		_, err := (&Entry{Series: "One Piece", Path: file}).Identify()
+		assert.Error(t, err)
+	})
+}
+
+func TestEntry_Pack(t *testing.T) {
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "One Piece", "Chapter 2")
+	require.NoError(t, os.MkdirAll(folder, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(folder, "001.jpg"), []byte("page"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(folder, "book.json"), []byte("{}"), 0644))
+
+	e := &Entry{Series: "One Piece", Path: folder, Rel: "One Piece/Chapter 2", IsDir: true}
+	require.NoError(t, e.Pack())
+	assert.Equal(t, &Entry{Series: "One Piece", Path: folder + ".cbz", Rel: "One Piece/Chapter 2.cbz"}, e)
+	assert.NoDirExists(t, folder)
+
+	zr, err := zip.OpenReader(e.Path)
+	require.NoError(t, err)
+	defer zr.Close()
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"001.jpg", "book.json"}, names)
+}
@@ -0,0 +1,201 @@
+package library
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Library is the folder of series folders that books are downloaded into.
+type Library struct {
+	dir string
+}
+
+func New(dir string) *Library {
+	return &Library{dir: dir}
+}
+
+func (l *Library) Dir() string {
+	return l.dir
+}
+
+type Series struct {
+	Name    string
+	Path    string
+	ModTime time.Time
+}
+
+type Book struct {
+	Series  string
+	Name    string
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// RelPath returns the path of the book relative to the library dir.
+func (b *Book) RelPath() string {
+	return b.Series + "/" + b.Name + ".cbz"
+}
+
+// Series returns every folder in the library that contains books.
+func (l *Library) Series() ([]*Series, error) {
+	entries, err := os.ReadDir(l.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*Series{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	series := []*Series{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		books, err := l.Books(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if len(books) == 0 {
+			continue
+		}
+		s := &Series{
+			Name: entry.Name(),
+			Path: filepath.Join(l.dir, entry.Name()),
+		}
+		for _, b := range books {
+			if b.ModTime.After(s.ModTime) {
+				s.ModTime = b.ModTime
+			}
+		}
+		series = append(series, s)
+	}
+	slices.SortFunc(series, func(a, b *Series) int {
+		return NaturalCompare(a.Name, b.Name)
+	})
+	return series, nil
+}
+
+// Books returns the books in a series in reading order.
+func (l *Library) Books(series string) ([]*Book, error) {
+	if !validName(series) {
+		return nil, fmt.Errorf("series %q: %w", series, ErrNotFound)
+	}
+	dir := filepath.Join(l.dir, series)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("series %q: %w", series, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+
+	books := []*Book{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".cbz") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, &Book{
+			Series:  series,
+			Name:    strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Path:    filepath.Join(dir, entry.Name()),
+			ModTime: info.ModTime(),
+			Size:    info.Size(),
+		})
+	}
+	slices.SortFunc(books, func(a, b *Book) int {
+		return NaturalCompare(a.Name, b.Name)
+	})
+	return books, nil
+}
+
+// Book returns a single book, series and name come from untrusted input so
+// they are checked to not escape the library.
+func (l *Library) Book(series, name string) (*Book, error) {
+	if !validName(series) || !validName(name) {
+		return nil, fmt.Errorf("book %q: %w", name, ErrNotFound)
+	}
+	path := filepath.Join(l.dir, series, name+".cbz")
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("book %q: %w", name, ErrNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	return &Book{
+		Series:  series,
+		Name:    name,
+		Path:    path,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+	}, nil
+}
+
+// AllBooks returns every book in the library.
+func (l *Library) AllBooks() ([]*Book, error) {
+	series, err := l.Series()
+	if err != nil {
+		return nil, err
+	}
+	books := []*Book{}
+	for _, s := range series {
+		b, err := l.Books(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, b...)
+	}
+	return books, nil
+}
+
+func validName(name string) bool {
+	return name != "" &&
+		name != "." &&
+		name != ".." &&
+		!strings.ContainsAny(name, `/\`)
+}
+
+// NaturalCompare compares strings treating runs of digits as numbers so
+// "#9" sorts before "#10".
+func NaturalCompare(a, b string) int {
+	for a != "" && b != "" {
+		aDigits := leadingDigits(a)
+		bDigits := leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			aNum := strings.TrimLeft(aDigits, "0")
+			bNum := strings.TrimLeft(bDigits, "0")
+			if len(aNum) != len(bNum) {
+				return len(aNum) - len(bNum)
+			}
+			if c := strings.Compare(aNum, bNum); c != 0 {
+				return c
+			}
+			a = a[len(aDigits):]
+			b = b[len(bDigits):]
+			continue
+		}
+		if a[0] != b[0] {
+			return int(a[0]) - int(b[0])
+		}
+		a = a[1:]
+		b = b[1:]
+	}
+	return len(a) - len(b)
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
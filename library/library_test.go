@@ -0,0 +1,71 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/internal/testutil"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	assert.Negative(t, NaturalCompare("One Piece #9", "One Piece #10"))
+	assert.Negative(t, NaturalCompare("One Piece #10", "One Piece #10.5"))
+	assert.Negative(t, NaturalCompare("One Piece V2 #10", "One Piece V10 #1"))
+	assert.Positive(t, NaturalCompare("b", "a"))
+	assert.Zero(t, NaturalCompare("a #007", "a #7"))
+}
+
+func TestLibrary(t *testing.T) {
+	dir := t.TempDir()
+	testutil.WriteBook(t, filepath.Join(dir, "One Piece", "One Piece #10.cbz"), &site.BookInfo{Series: "One Piece", Chapter: 10}, 3)
+	testutil.WriteBook(t, filepath.Join(dir, "One Piece", "One Piece #9.cbz"), nil, 2)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Empty"), 0755))
+
+	l := New(dir)
+
+	series, err := l.Series()
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Equal(t, "One Piece", series[0].Name)
+
+	books, err := l.Books("One Piece")
+	require.NoError(t, err)
+	require.Len(t, books, 2)
+	assert.Equal(t, "One Piece #9", books[0].Name)
+	assert.Equal(t, "One Piece/One Piece #10.cbz", books[1].RelPath())
+
+	_, err = l.Books("..")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = l.Book("One Piece", "../../etc/passwd")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = l.Book("One Piece", "One Piece #11")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestArchive(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "book.cbz")
+	testutil.WriteBook(t, file, &site.BookInfo{Series: "One Piece", Title: "Romance Dawn"}, 12)
+
+	a, err := OpenArchive(file)
+	require.NoError(t, err)
+	defer a.Close()
+
+	require.Len(t, a.Pages(), 12)
+	assert.Equal(t, "000.jpg", EntryName(a.Pages()[0]))
+	assert.Equal(t, "011.jpg", EntryName(a.Pages()[11]))
+
+	cover, err := a.Cover()
+	require.NoError(t, err)
+	assert.Equal(t, "000.jpg", EntryName(cover))
+
+	info, err := a.Info()
+	require.NoError(t, err)
+	assert.Equal(t, "Romance Dawn", info.Title)
+
+	_, err = a.Page(12)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
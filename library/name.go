@@ -0,0 +1,48 @@
+package library
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+var (
+	volumeRE  = regexp.MustCompile(`(?i)(?:\bV|\bVol\.?\s*|\bVolume\s+)(\d+)\b`)
+	chapterRE = regexp.MustCompile(`(?i)(?:#|\bCh\.?\s*|\bChapter\s+)(\d+(?:\.\d+)?)`)
+)
+
+// ParseName reads the volume and chapter numbers from a book name like
+// "One Piece V3 #21", the format downloadBook names files with. It also
+// understands "Vol. 3 Ch. 21" and "Volume 3 Chapter 21".
+func ParseName(name string) (volume int, chapter float64, ok bool) {
+	if m := volumeRE.FindStringSubmatch(name); m != nil {
+		volume, _ = strconv.Atoi(m[1])
+		ok = true
+	}
+	if m := chapterRE.FindStringSubmatch(name); m != nil {
+		chapter, _ = strconv.ParseFloat(m[1], 64)
+		ok = true
+	}
+	return volume, chapter, ok
+}
+
+// Gaps returns the whole chapter numbers between the first and last chapter
+// that are missing.
+func Gaps(chapters []float64) []float64 {
+	have := map[float64]bool{}
+	first, last := math.Inf(1), math.Inf(-1)
+	for _, c := range chapters {
+		have[c] = true
+		first = min(first, c)
+		last = max(last, c)
+	}
+	gaps := []float64{}
+	for c := math.Ceil(first); c < last; c++ {
+		if !have[c] {
+			gaps = append(gaps, c)
+		}
+	}
+	slices.Sort(gaps)
+	return gaps
+}
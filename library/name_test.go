@@ -0,0 +1,40 @@
+package library
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseName(t *testing.T) {
+	testCases := []struct {
+		name    string
+		volume  int
+		chapter float64
+		ok      bool
+	}{
+		{"One Piece #1000", 0, 1000, true},
+		{"One Piece V3 #21", 3, 21, true},
+		{"One Piece #10.5", 0, 10.5, true},
+		{"Sakamoto Days Vol. 2 Ch. 9", 2, 9, true},
+		{"Chainsaw Man Volume 11 Chapter 97", 11, 97, true},
+		{"Oneshot 6a1f", 0, 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			volume, chapter, ok := ParseName(tc.name)
+			assert.Equal(t, tc.volume, volume)
+			assert.Equal(t, tc.chapter, chapter)
+			assert.Equal(t, tc.ok, ok)
+		})
+	}
+}
+
+func TestGaps(t *testing.T) {
+	assert.Equal(t, []float64{}, Gaps(nil))
+	assert.Equal(t, []float64{}, Gaps([]float64{1, 2, 3}))
+	assert.Equal(t, []float64{3, 4, 6}, Gaps([]float64{7, 1, 2, 5}))
+	assert.Equal(t, []float64{}, Gaps([]float64{1, 1.5, 2}))
+	// an extra chapter doesn't fill the gap before it
+	assert.Equal(t, []float64{10}, Gaps([]float64{9, 10.5, 11}))
+}
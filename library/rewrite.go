@@ -0,0 +1,67 @@
+package library
+
+import (
+	"archive/zip"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/abibby/manga/internal/cbz"
+)
+
+// Rewrite replaces entries in a cbz and adds the ones it doesn't have. The
+// other entries are copied without being recompressed. The new archive is
+// written next to the old one and renamed over it so the book is never left
+// half written.
+func Rewrite(file string, entries map[string][]byte) error {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	stat, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	return cbz.WriteFile(file, stat.Mode(), func(zw *zip.Writer) error {
+		// keep the leading slash older archives were written with
+		prefix := ""
+		written := map[string]bool{}
+		for _, f := range zr.File {
+			if strings.HasPrefix(f.Name, "/") {
+				prefix = "/"
+			}
+			name := EntryName(f)
+			data, ok := entries[name]
+			if !ok {
+				err := zw.Copy(f)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			err := cbz.WriteEntry(zw, f.Name, data)
+			if err != nil {
+				return err
+			}
+			written[name] = true
+		}
+
+		names := []string{}
+		for name := range entries {
+			if !written[name] {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			err := cbz.WriteEntry(zw, prefix+name, entries[name])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,54 @@
+package library
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/internal/testutil"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "S", "S #1.cbz")
+	testutil.WriteBook(t, file, &site.BookInfo{Title: "Old"}, 3)
+
+	err := Rewrite(file, map[string][]byte{
+		"book.json":     []byte(`{"title":"New"}`),
+		"ComicInfo.xml": []byte(`<ComicInfo></ComicInfo>`),
+	})
+	require.NoError(t, err)
+
+	a, err := OpenArchive(file)
+	require.NoError(t, err)
+	defer a.Close()
+
+	info, err := a.Info()
+	require.NoError(t, err)
+	assert.Equal(t, "New", info.Title)
+	assert.Len(t, a.Pages(), 3)
+	data, err := ReadFile(a.Pages()[1])
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xd8, 1}, data)
+
+	ci, ok := a.File("ComicInfo.xml")
+	require.True(t, ok)
+	assert.Equal(t, "/ComicInfo.xml", ci.Name)
+
+	files, err := os.ReadDir(filepath.Dir(file))
+	require.NoError(t, err)
+	assert.Len(t, files, 1, "the temp file should be renamed")
+}
+
+func TestRewrite_invalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "book.cbz")
+	require.NoError(t, os.WriteFile(file, []byte("not a zip"), 0644))
+
+	assert.Error(t, Rewrite(file, map[string][]byte{"book.json": {}}))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	assert.Equal(t, "not a zip", string(data))
+}
@@ -0,0 +1,303 @@
+package library
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/abibby/manga/site"
+)
+
+// SeriesMove is a file moved into another series folder, the paths are
+// relative to the library dir.
+type SeriesMove struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+const (
+	MoveMoved     = "moved"
+	MoveWouldMove = "would move"
+	MoveExists    = "exists"
+	MoveFailed    = "failed"
+)
+
+// SeriesEdit is the result of renaming or merging series.
+type SeriesEdit struct {
+	// Target is the folder the series were moved into
+	Target string
+	Moves  []*SeriesMove
+	// Renamed are the old names of the series that were moved by name,
+	// sources pinned to them should be pinned to Target
+	Renamed []string
+}
+
+// Failed returns the number of files that could not be moved.
+func (e *SeriesEdit) Failed() int {
+	failed := 0
+	for _, m := range e.Moves {
+		if m.Status == MoveExists || m.Status == MoveFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// seriesRef is a series found from a name or id
+type seriesRef struct {
+	// Name is the folder the series is saved in
+	Name string
+	// OnDisk is true if the folder exists
+	OnDisk bool
+	IDs    []string
+	// ByID is true when the series was found by id, only the books
+	// recorded for the id are moved
+	ByID bool
+}
+
+// RenameSeries moves a series folder to a new name. The series can be its
+// name or a series id. File names that start with the old name are renamed,
+// the series in the book.json of every book is updated and the database is
+// changed so new chapters are downloaded into the new folder. With dry
+// nothing is changed.
+func (l *Library) RenameSeries(db *site.DB, series, name string, dry bool) (*SeriesEdit, error) {
+	return l.editSeries(db, name, []string{series}, true, dry)
+}
+
+// MergeSeries moves the books of each series into the folder name the same
+// way as RenameSeries, with a series id only the books downloaded for that id
+// are moved. Books that would replace a file that is already in the folder are
+// left where they are.
+func (l *Library) MergeSeries(db *site.DB, name string, series []string, dry bool) (*SeriesEdit, error) {
+	return l.editSeries(db, name, series, false, dry)
+}
+
+func (l *Library) editSeries(db *site.DB, target string, queries []string, rename, dry bool) (*SeriesEdit, error) {
+	if target == "" || target == "." || target == ".." || strings.ContainsAny(target, `/\`) {
+		return nil, fmt.Errorf("%q can't be used as a folder name", target)
+	}
+
+	refs := make([]*seriesRef, len(queries))
+	for i, q := range queries {
+		var err error
+		refs[i], err = l.findSeries(db, q)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := l.findSeries(db, target)
+	if err == nil {
+		if rename && !strings.EqualFold(existing.Name, refs[0].Name) {
+			return nil, fmt.Errorf("a series named %s already exists, use manga series merge to combine them", existing.Name)
+		}
+		if !rename {
+			// keep the case of the folder that is already there
+			target = existing.Name
+		}
+	}
+
+	edit := &SeriesEdit{Target: target, Moves: []*SeriesMove{}, Renamed: []string{}}
+	for _, ref := range refs {
+		if !ref.ByID && ref.Name == target {
+			continue
+		}
+		m, err := l.moveSeries(db, ref, target, dry)
+		if err != nil {
+			return nil, err
+		}
+		edit.Moves = append(edit.Moves, m...)
+		if !ref.ByID {
+			edit.Renamed = append(edit.Renamed, ref.Name)
+		}
+	}
+	return edit, nil
+}
+
+// findSeries finds a series by id or by name, the name matches series in the
+// database and folders in the library.
+func (l *Library) findSeries(db *site.DB, query string) (*seriesRef, error) {
+	names, err := db.AllSeries()
+	if err != nil {
+		return nil, err
+	}
+	ref := &seriesRef{Name: query, IDs: []string{}}
+	if name, ok := names[query]; ok {
+		ref.Name = name
+		ref.IDs = append(ref.IDs, query)
+		ref.ByID = true
+	} else {
+		for id, name := range names {
+			if strings.EqualFold(name, query) {
+				ref.Name = name
+				ref.IDs = append(ref.IDs, id)
+			}
+		}
+	}
+
+	folders, err := l.Series()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range folders {
+		if strings.EqualFold(f.Name, ref.Name) {
+			ref.Name = f.Name
+			ref.OnDisk = true
+			break
+		}
+	}
+
+	if !ref.ByID {
+		books, err := db.Books()
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range books {
+			if strings.HasPrefix(b.File, ref.Name+"/") && !slices.Contains(ref.IDs, b.SeriesID) {
+				ref.IDs = append(ref.IDs, b.SeriesID)
+			}
+		}
+	}
+	if len(ref.IDs) == 0 && !ref.OnDisk {
+		return nil, fmt.Errorf("no series named %q, see manga series for the known series", query)
+	}
+	slices.Sort(ref.IDs)
+	return ref, nil
+}
+
+// moveSeries moves the files of a series into the target folder and updates
+// the database.
+func (l *Library) moveSeries(db *site.DB, ref *seriesRef, target string, dry bool) ([]*SeriesMove, error) {
+	files, err := l.seriesFiles(db, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	moves := []*SeriesMove{}
+	moved := map[string]string{}
+	for _, file := range files {
+		m := &SeriesMove{
+			From: file,
+			To:   target + "/" + renamePrefix(path.Base(file), ref.Name, target),
+		}
+		moves = append(moves, m)
+
+		from := filepath.Join(l.dir, filepath.FromSlash(m.From))
+		to := filepath.Join(l.dir, filepath.FromSlash(m.To))
+		_, err := os.Stat(from)
+		onDisk := err == nil
+		if _, err := os.Stat(to); err == nil && onDisk {
+			m.Status = MoveExists
+			continue
+		}
+		if dry {
+			m.Status = MoveWouldMove
+			continue
+		}
+		if onDisk {
+			err = moveSeriesFile(from, to, target)
+			if err != nil {
+				m.Status, m.Error = MoveFailed, err.Error()
+				continue
+			}
+		}
+		m.Status = MoveMoved
+		moved[m.From] = m.To
+	}
+	if dry {
+		return moves, nil
+	}
+
+	err = db.MoveSeries(ref.IDs, target, moved)
+	if err != nil {
+		return nil, err
+	}
+	if ref.OnDisk {
+		// only removes the folder if everything was moved
+		_ = os.Remove(filepath.Join(l.dir, ref.Name))
+	}
+	return moves, nil
+}
+
+// seriesFiles returns the files to move relative to the library dir, every
+// file in the folder or the books recorded for a series id.
+func (l *Library) seriesFiles(db *site.DB, ref *seriesRef) ([]string, error) {
+	files := []string{}
+	books, err := db.Books()
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range books {
+		if ref.ByID && slices.Contains(ref.IDs, b.SeriesID) {
+			files = append(files, b.File)
+		} else if !ref.ByID && strings.HasPrefix(b.File, ref.Name+"/") {
+			files = append(files, b.File)
+		}
+	}
+	if ref.ByID || !ref.OnDisk {
+		return files, nil
+	}
+
+	entries, err := os.ReadDir(filepath.Join(l.dir, ref.Name))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		file := ref.Name + "/" + e.Name()
+		if !slices.Contains(files, file) {
+			files = append(files, file)
+		}
+	}
+	slices.SortFunc(files, NaturalCompare)
+	return files, nil
+}
+
+// renamePrefix replaces the old series name at the start of a file name.
+func renamePrefix(name, old, target string) string {
+	if len(name) >= len(old) && strings.EqualFold(name[:len(old)], old) {
+		return target + name[len(old):]
+	}
+	return name
+}
+
+func moveSeriesFile(from, to, series string) error {
+	if strings.EqualFold(filepath.Ext(from), ".cbz") {
+		err := setBookSeries(from, series)
+		if err != nil {
+			return err
+		}
+	}
+	err := os.MkdirAll(filepath.Dir(to), 0775)
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+// setBookSeries changes the series in the book.json of a cbz.
+func setBookSeries(file, series string) error {
+	a, err := OpenArchive(file)
+	if err != nil {
+		return err
+	}
+	info, err := a.Info()
+	a.Close()
+	if err != nil {
+		return err
+	}
+	if info == nil || info.Series == series {
+		return nil
+	}
+	info.Series = series
+	data, err := json.MarshalIndent(info, "", "    ")
+	if err != nil {
+		return err
+	}
+	return Rewrite(file, map[string][]byte{"book.json": data})
+}
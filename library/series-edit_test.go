@@ -0,0 +1,118 @@
+package library
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/internal/testutil"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// seriesLibrary writes the books of a series, each with a database record
+// downloaded for seriesID.
+func seriesLibrary(t *testing.T, lib *Library, db *site.DB, seriesID, series string, files ...string) {
+	t.Helper()
+	require.NoError(t, db.InitSeriesName(seriesID, series))
+	for _, f := range files {
+		testutil.WriteBook(t, filepath.Join(lib.Dir(), series, f), &site.BookInfo{Series: series}, 1)
+		require.NoError(t, db.AddBook(&site.BookRecord{File: series + "/" + f, SeriesID: seriesID, Series: series}))
+	}
+}
+
+func openSeriesDB(t *testing.T) *site.DB {
+	t.Helper()
+	db, err := site.OpenDB(filepath.Join(t.TempDir(), "manga.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func bookSeries(t *testing.T, file string) string {
+	t.Helper()
+	a, err := OpenArchive(file)
+	require.NoError(t, err)
+	defer a.Close()
+	info, err := a.Info()
+	require.NoError(t, err)
+	return info.Series
+}
+
+func TestLibrary_RenameSeries(t *testing.T) {
+	lib := New(t.TempDir())
+	db := openSeriesDB(t)
+	seriesLibrary(t, lib, db, "mangaplus:1", "One Piece", "One Piece #1.cbz", "extra.cbz")
+	seriesLibrary(t, lib, db, "mangaplus:2", "Naruto", "Naruto #1.cbz")
+	require.NoError(t, db.SetReadProgress("adam", "One Piece/One Piece #1.cbz", &site.ReadProgress{Page: 3}))
+
+	edit, err := lib.RenameSeries(db, "one piece", "OP", true)
+	require.NoError(t, err)
+	assert.Equal(t, []*SeriesMove{
+		{From: "One Piece/One Piece #1.cbz", To: "OP/OP #1.cbz", Status: MoveWouldMove},
+		{From: "One Piece/extra.cbz", To: "OP/extra.cbz", Status: MoveWouldMove},
+	}, edit.Moves)
+	assert.FileExists(t, filepath.Join(lib.Dir(), "One Piece", "One Piece #1.cbz"))
+
+	edit, err = lib.RenameSeries(db, "one piece", "OP", false)
+	require.NoError(t, err)
+	assert.Equal(t, "OP", edit.Target)
+	assert.Equal(t, []string{"One Piece"}, edit.Renamed)
+	assert.Zero(t, edit.Failed())
+	assert.NoDirExists(t, filepath.Join(lib.Dir(), "One Piece"))
+	assert.Equal(t, "OP", bookSeries(t, filepath.Join(lib.Dir(), "OP", "OP #1.cbz")))
+
+	r, err := db.Book("OP/OP #1.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "OP", r.Series)
+	p, err := db.ReadProgress("adam", "OP/OP #1.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	assert.Equal(t, 3, p.Page)
+	names, err := db.AllSeries()
+	require.NoError(t, err)
+	assert.Equal(t, "OP", names["mangaplus:1"])
+
+	_, err = lib.RenameSeries(db, "OP", "naruto", false)
+	assert.ErrorContains(t, err, "a series named Naruto already exists")
+	assert.FileExists(t, filepath.Join(lib.Dir(), "OP", "OP #1.cbz"))
+
+	_, err = lib.RenameSeries(db, "OP", "a/b", false)
+	assert.Error(t, err)
+	_, err = lib.RenameSeries(db, "Bleach", "B", false)
+	assert.ErrorContains(t, err, `no series named "Bleach"`)
+}
+
+func TestLibrary_MergeSeries(t *testing.T) {
+	lib := New(t.TempDir())
+	db := openSeriesDB(t)
+	seriesLibrary(t, lib, db, "mangaplus:1", "One Piece", "One Piece #1.cbz", "One Piece #2.cbz")
+	seriesLibrary(t, lib, db, "mangadex:op", "One Piece (Official)", "One Piece (Official) #2.cbz", "One Piece (Official) #3.cbz")
+
+	edit, err := lib.MergeSeries(db, "one piece", []string{"mangadex:op"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "One Piece", edit.Target)
+	assert.Equal(t, []string{}, edit.Renamed)
+	assert.Equal(t, []*SeriesMove{
+		{From: "One Piece (Official)/One Piece (Official) #2.cbz", To: "One Piece/One Piece #2.cbz", Status: MoveExists},
+		{From: "One Piece (Official)/One Piece (Official) #3.cbz", To: "One Piece/One Piece #3.cbz", Status: MoveMoved},
+	}, edit.Moves)
+	assert.Equal(t, 1, edit.Failed())
+
+	// the conflicting book is left where it was
+	assert.FileExists(t, filepath.Join(lib.Dir(), "One Piece (Official)", "One Piece (Official) #2.cbz"))
+	assert.Equal(t, "One Piece (Official)", bookSeries(t, filepath.Join(lib.Dir(), "One Piece (Official)", "One Piece (Official) #2.cbz")))
+	r, err := db.Book("One Piece (Official)/One Piece (Official) #2.cbz")
+	require.NoError(t, err)
+	assert.NotNil(t, r)
+
+	assert.Equal(t, "One Piece", bookSeries(t, filepath.Join(lib.Dir(), "One Piece", "One Piece #3.cbz")))
+	r, err = db.Book("One Piece/One Piece #3.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "mangadex:op", r.SeriesID)
+	names, err := db.AllSeries()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"mangaplus:1": "One Piece", "mangadex:op": "One Piece"}, names)
+}
@@ -0,0 +1,101 @@
+package library
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"path"
+	"strings"
+
+	"github.com/abibby/manga/internal/cbz"
+)
+
+// Problem is something wrong with a book found by Verify.
+type Problem struct {
+	// Entry is the file in the archive with the problem, it is empty for
+	// problems with the whole book
+	Entry   string `json:"entry,omitempty"`
+	Message string `json:"message"`
+}
+
+func (p *Problem) String() string {
+	if p.Entry == "" {
+		return p.Message
+	}
+	return p.Entry + ": " + p.Message
+}
+
+// Verify reads every entry in the archive to check its crc, decodes the header
+// of every page and compares the pages with book.json. An archive with no
+// problems returns an empty list.
+func (b *Book) Verify() []*Problem {
+	problems := []*Problem{}
+	add := func(entry, format string, args ...any) {
+		problems = append(problems, &Problem{Entry: entry, Message: fmt.Sprintf(format, args...)})
+	}
+
+	a, err := b.Open()
+	if err != nil {
+		add("", "could not open archive: %v", err)
+		return problems
+	}
+	defer a.Close()
+
+	for _, f := range a.zr.File {
+		if f.FileInfo().IsDir() || cbz.IsImage(EntryName(f)) {
+			continue
+		}
+		_, err := ReadFile(f)
+		if err != nil {
+			add(EntryName(f), "%v", err)
+		}
+	}
+
+	pages := make([]image.Config, len(a.pages))
+	readable := make([]bool, len(a.pages))
+	for i, f := range a.pages {
+		// reading the whole entry checks the crc
+		data, err := ReadFile(f)
+		if err != nil {
+			add(EntryName(f), "%v", err)
+			continue
+		}
+		pages[i], _, err = image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			add(EntryName(f), "could not decode image: %v", err)
+			continue
+		}
+		readable[i] = true
+	}
+
+	if len(a.pages) == 0 {
+		add("", "no pages")
+	} else if name := path.Base(EntryName(a.pages[0])); strings.TrimSuffix(name, path.Ext(name)) != "000" {
+		add("", "missing cover, the first page is %s", EntryName(a.pages[0]))
+	}
+
+	info, err := a.Info()
+	if err != nil {
+		add("book.json", "%v", err)
+		return problems
+	}
+	if info == nil {
+		add("", "missing book.json")
+		return problems
+	}
+	if len(info.Pages) == 0 {
+		return problems
+	}
+	if len(info.Pages) != len(a.pages) {
+		add("book.json", "lists %d pages, the archive has %d", len(info.Pages), len(a.pages))
+	}
+	for i, p := range info.Pages {
+		if i >= len(a.pages) || !readable[i] || p.Width == 0 || p.Height == 0 {
+			continue
+		}
+		if p.Width != pages[i].Width || p.Height != pages[i].Height {
+			add(EntryName(a.pages[i]), "is %dx%d, book.json says %dx%d", pages[i].Width, pages[i].Height, p.Width, p.Height)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,156 @@
+package library
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testEntry struct {
+	name string
+	data []byte
+	// badCRC stores the entry with the wrong checksum
+	badCRC bool
+}
+
+func pngPage(t *testing.T, w, h int) []byte {
+	t.Helper()
+	b := &bytes.Buffer{}
+	require.NoError(t, png.Encode(b, image.NewGray(image.Rect(0, 0, w, h))))
+	return b.Bytes()
+}
+
+func bookJSON(t *testing.T, info *site.BookInfo) []byte {
+	t.Helper()
+	b, err := json.Marshal(info)
+	require.NoError(t, err)
+	return b
+}
+
+func writeEntries(t *testing.T, file string, entries []testEntry) *Book {
+	t.Helper()
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		crc := crc32.ChecksumIEEE(e.data)
+		if e.badCRC {
+			crc++
+		}
+		w, err := zw.CreateRaw(&zip.FileHeader{
+			Name:               "/" + e.name,
+			Method:             zip.Store,
+			CRC32:              crc,
+			CompressedSize64:   uint64(len(e.data)),
+			UncompressedSize64: uint64(len(e.data)),
+		})
+		require.NoError(t, err)
+		_, err = w.Write(e.data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return &Book{Name: "book", Path: file}
+}
+
+func problemStrings(problems []*Problem) []string {
+	s := make([]string, len(problems))
+	for i, p := range problems {
+		s[i] = p.String()
+	}
+	return s
+}
+
+func TestVerify(t *testing.T) {
+	info := &site.BookInfo{Pages: []*site.InfoPage{
+		{Type: site.PageTypeFrontCover, Width: 20, Height: 30},
+		{Type: site.PageTypeStory, Width: 20, Height: 30},
+	}}
+
+	testCases := []struct {
+		name    string
+		entries []testEntry
+		want    []string
+	}{
+		{
+			name: "ok",
+			entries: []testEntry{
+				{name: "000.png", data: pngPage(t, 20, 30)},
+				{name: "001.png", data: pngPage(t, 20, 30)},
+				{name: "book.json", data: bookJSON(t, info)},
+			},
+			want: []string{},
+		},
+		{
+			name: "bad crc",
+			entries: []testEntry{
+				{name: "000.png", data: pngPage(t, 20, 30)},
+				{name: "001.png", data: pngPage(t, 20, 30), badCRC: true},
+				{name: "book.json", data: bookJSON(t, info)},
+			},
+			want: []string{"001.png: zip: checksum error"},
+		},
+		{
+			name: "undecodable page",
+			entries: []testEntry{
+				{name: "000.png", data: pngPage(t, 20, 30)},
+				{name: "001.png", data: []byte("not an image")},
+				{name: "book.json", data: bookJSON(t, info)},
+			},
+			want: []string{"001.png: could not decode image: image: unknown format"},
+		},
+		{
+			name: "dimensions",
+			entries: []testEntry{
+				{name: "000.png", data: pngPage(t, 20, 30)},
+				{name: "001.png", data: pngPage(t, 40, 30)},
+				{name: "book.json", data: bookJSON(t, info)},
+			},
+			want: []string{"001.png: is 40x30, book.json says 20x30"},
+		},
+		{
+			name: "missing page and cover",
+			entries: []testEntry{
+				{name: "001.png", data: pngPage(t, 20, 30)},
+				{name: "book.json", data: bookJSON(t, info)},
+			},
+			want: []string{
+				"missing cover, the first page is 001.png",
+				"book.json: lists 2 pages, the archive has 1",
+			},
+		},
+		{
+			name: "missing book.json",
+			entries: []testEntry{
+				{name: "000.png", data: pngPage(t, 20, 30)},
+			},
+			want: []string{"missing book.json"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := writeEntries(t, filepath.Join(t.TempDir(), "book.cbz"), tc.entries)
+			assert.Equal(t, tc.want, problemStrings(b.Verify()))
+		})
+	}
+}
+
+func TestVerify_notAZip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "book.cbz")
+	require.NoError(t, os.WriteFile(file, []byte("truncated"), 0644))
+
+	problems := (&Book{Path: file}).Verify()
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0].Message, "could not open archive")
+}
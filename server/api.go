@@ -0,0 +1,256 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/site"
+)
+
+type sourceResponse struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name,omitempty"`
+	URL        string    `json:"url"`
+	Connector  string    `json:"connector,omitempty"`
+	From       float64   `json:"from,omitempty"`
+	Frequency  string    `json:"frequency,omitempty"`
+	Schedule   string    `json:"schedule,omitempty"`
+	QuietHours string    `json:"quiet_hours,omitempty"`
+	LastRun    time.Time `json:"last_run,omitzero"`
+	NextRun    time.Time `json:"next_run,omitzero"`
+	LastError  string    `json:"last_error,omitempty"`
+	Running    bool      `json:"running"`
+}
+
+func newSourceResponse(src *site.Source, state site.SourceState, running bool) *sourceResponse {
+	resp := &sourceResponse{
+		ID:         src.ID(),
+		Name:       src.Name,
+		URL:        src.URL,
+		From:       src.From,
+		Schedule:   src.Schedule,
+		QuietHours: src.QuietHours,
+		LastRun:    state.LastRun,
+		NextRun:    state.NextRun,
+		LastError:  state.LastError,
+		Running:    running,
+	}
+	if src.Frequency != 0 {
+		resp.Frequency = src.Frequency.String()
+	}
+	if connector, ok := site.FindSite(src.URL); ok {
+		resp.Connector = connector.SiteName()
+	}
+	return resp
+}
+
+func (s *Server) listSources(w http.ResponseWriter, r *http.Request) {
+	statuses := s.scheduler.Status()
+	sources := make([]*sourceResponse, len(statuses))
+	for i, status := range statuses {
+		sources[i] = newSourceResponse(status.Source, status.State, status.Running)
+	}
+	writeJSON(w, http.StatusOK, sources)
+}
+
+func (s *Server) findSource(id string) (*site.Source, bool) {
+	for _, src := range s.config().Sources {
+		if src.ID() == id {
+			return src, true
+		}
+	}
+	return nil, false
+}
+
+type addSourceRequest struct {
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	From       float64 `json:"from"`
+	Frequency  string  `json:"frequency"`
+	Schedule   string  `json:"schedule"`
+	QuietHours string  `json:"quiet_hours"`
+}
+
+// addSource adds the source to the config file, the watcher picks it up and
+// runs it when it reloads the config.
+func (s *Server) addSource(w http.ResponseWriter, r *http.Request) {
+	req := &addSourceRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request: %w", err))
+		return
+	}
+
+	src := &site.Source{
+		Name:       strings.TrimSpace(req.Name),
+		URL:        strings.TrimSpace(req.URL),
+		From:       req.From,
+		Schedule:   req.Schedule,
+		QuietHours: req.QuietHours,
+	}
+	if req.Frequency != "" {
+		src.Frequency, err = time.ParseDuration(req.Frequency)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid frequency: %w", err))
+			return
+		}
+	}
+
+	err = config.ValidateSource(src, s.config().Watch)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	file, err := config.File()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = config.AddSource(file, src)
+	if errors.Is(err, config.ErrSourceExists) {
+		writeError(w, http.StatusConflict, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newSourceResponse(src, site.SourceState{}, false))
+}
+
+func (s *Server) removeSource(w http.ResponseWriter, r *http.Request) {
+	src, ok := s.findSource(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("source not found"))
+		return
+	}
+
+	file, err := config.File()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	err = config.RemoveSource(file, src.URL)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) syncSource(w http.ResponseWriter, r *http.Request) {
+	src, ok := s.findSource(r.PathValue("id"))
+	if !ok || !s.scheduler.Trigger(src.URL) {
+		writeError(w, http.StatusNotFound, errors.New("source not found"))
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *Server) syncAll(w http.ResponseWriter, r *http.Request) {
+	s.scheduler.TriggerAll()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+type progressResponse struct {
+	Source string `json:"source"`
+	Book   string `json:"book"`
+	Page   int    `json:"page"`
+	Pages  int    `json:"pages"`
+}
+
+type statusResponse struct {
+	Progress *progressResponse `json:"progress"`
+	Queue    []*sourceResponse `json:"queue"`
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	resp := &statusResponse{
+		Queue: []*sourceResponse{},
+	}
+	if p := s.scheduler.Progress(); p != nil {
+		resp.Progress = &progressResponse{
+			Source: p.Source,
+			Book:   p.Book,
+			Page:   p.Page,
+			Pages:  p.Pages,
+		}
+	}
+	for _, status := range s.scheduler.Status() {
+		resp.Queue = append(resp.Queue, newSourceResponse(status.Source, status.State, status.Running))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+type seriesResponse struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Books int    `json:"books"`
+}
+
+func (s *Server) listSeries(w http.ResponseWriter, r *http.Request) {
+	series, err := s.db.AllSeries()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	books, err := s.db.Books()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	counts := map[string]int{}
+	for _, b := range books {
+		counts[b.SeriesID]++
+	}
+
+	resp := make([]*seriesResponse, 0, len(series))
+	for id, name := range series {
+		resp = append(resp, &seriesResponse{
+			ID:    id,
+			Name:  name,
+			Books: counts[id],
+		})
+	}
+	slices.SortFunc(resp, func(a, b *seriesResponse) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) listSeriesBooks(w http.ResponseWriter, r *http.Request) {
+	books, err := s.db.SeriesBooks(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sortBooks(books)
+	writeJSON(w, http.StatusOK, books)
+}
+
+func (s *Server) listBooks(w http.ResponseWriter, r *http.Request) {
+	books, err := s.db.Books()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	sortBooks(books)
+	writeJSON(w, http.StatusOK, books)
+}
+
+func sortBooks(books []*site.BookRecord) {
+	slices.SortFunc(books, func(a, b *site.BookRecord) int {
+		return cmp.Or(
+			strings.Compare(a.Series, b.Series),
+			cmp.Compare(a.Volume, b.Volume),
+			cmp.Compare(a.Chapter, b.Chapter),
+		)
+	})
+}
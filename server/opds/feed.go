@@ -0,0 +1,101 @@
+package opds
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+const (
+	typeNavigation  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	typeAcquisition = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	typeOpenSearch  = "application/opensearchdescription+xml"
+	typeCBZ         = "application/vnd.comicbook+zip"
+
+	relAcquisition = "http://opds-spec.org/acquisition"
+	relImage       = "http://opds-spec.org/image"
+	relThumbnail   = "http://opds-spec.org/image/thumbnail"
+	relStream      = "http://vaemendis.net/opds-pse/stream"
+)
+
+type feed struct {
+	XMLName      xml.Name `xml:"feed"`
+	Xmlns        string   `xml:"xmlns,attr"`
+	XmlnsOPDS    string   `xml:"xmlns:opds,attr"`
+	XmlnsPSE     string   `xml:"xmlns:pse,attr"`
+	XmlnsDC      string   `xml:"xmlns:dc,attr"`
+	XmlnsSearch  string   `xml:"xmlns:opensearch,attr"`
+	ID           string   `xml:"id"`
+	Title        string   `xml:"title"`
+	Updated      string   `xml:"updated"`
+	Author       *author  `xml:"author,omitempty"`
+	TotalResults int      `xml:"opensearch:totalResults,omitempty"`
+	Links        []*link  `xml:"link"`
+	Entries      []*entry `xml:"entry"`
+}
+
+type author struct {
+	Name string `xml:"name"`
+}
+
+type link struct {
+	Rel      string `xml:"rel,attr,omitempty"`
+	Href     string `xml:"href,attr"`
+	Type     string `xml:"type,attr,omitempty"`
+	Title    string `xml:"title,attr,omitempty"`
+	PSECount int    `xml:"pse:count,attr,omitempty"`
+	Length   int64  `xml:"length,attr,omitempty"`
+}
+
+type entry struct {
+	ID       string   `xml:"id"`
+	Title    string   `xml:"title"`
+	Updated  string   `xml:"updated"`
+	Authors  []author `xml:"author"`
+	Issued   string   `xml:"dc:issued,omitempty"`
+	Language string   `xml:"dc:language,omitempty"`
+	Content  *content `xml:"content,omitempty"`
+	Links    []*link  `xml:"link"`
+}
+
+type content struct {
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+func newFeed(id, title string, updated time.Time, links ...*link) *feed {
+	return &feed{
+		Xmlns:       "http://www.w3.org/2005/Atom",
+		XmlnsOPDS:   "http://opds-spec.org/2010/catalog",
+		XmlnsPSE:    "http://vaemendis.net/opds-pse/ns",
+		XmlnsDC:     "http://purl.org/dc/terms/",
+		XmlnsSearch: "http://a9.com/-/spec/opensearch/1.1/",
+		ID:          id,
+		Title:       title,
+		Updated:     atomTime(updated),
+		Author:      &author{Name: "manga"},
+		Links:       links,
+		Entries:     []*entry{},
+	}
+}
+
+type openSearchDescription struct {
+	XMLName     xml.Name       `xml:"OpenSearchDescription"`
+	Xmlns       string         `xml:"xmlns,attr"`
+	ShortName   string         `xml:"ShortName"`
+	Description string         `xml:"Description"`
+	InputEnc    string         `xml:"InputEncoding"`
+	OutputEnc   string         `xml:"OutputEncoding"`
+	URL         *openSearchURL `xml:"Url"`
+}
+
+type openSearchURL struct {
+	Type     string `xml:"type,attr"`
+	Template string `xml:"template,attr"`
+}
+
+func atomTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Unix(0, 0)
+	}
+	return t.UTC().Format(time.RFC3339)
+}
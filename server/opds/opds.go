@@ -0,0 +1,348 @@
+// Package opds serves the downloaded library as an OPDS 1.2 catalogue with
+// the OPDS-PSE page streaming extension.
+package opds
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+)
+
+// Prefix is the path the catalogue is served under.
+const Prefix = "/opds"
+
+const (
+	recentCount = 50
+	searchCount = 50
+)
+
+type Handler struct {
+	db      *site.DB
+	library func() *library.Library
+	mux     *http.ServeMux
+}
+
+var _ http.Handler = &Handler{}
+
+// New creates the catalogue handler, lib returns the library to serve so
+// changes to the config's dir are picked up.
+func New(db *site.DB, lib func() *library.Library) *Handler {
+	h := &Handler{
+		db:      db,
+		library: lib,
+		mux:     http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET "+Prefix+"/{$}", h.root)
+	h.mux.HandleFunc("GET "+Prefix+"/recent", h.recent)
+	h.mux.HandleFunc("GET "+Prefix+"/search", h.search)
+	h.mux.HandleFunc("GET "+Prefix+"/opensearch.xml", h.openSearch)
+	h.mux.HandleFunc("GET "+Prefix+"/series/{series}", h.series)
+	h.mux.HandleFunc("GET "+Prefix+"/series/{series}/cover", h.seriesCover)
+	h.mux.HandleFunc("GET "+Prefix+"/books/{series}/{book}/download", h.download)
+	h.mux.HandleFunc("GET "+Prefix+"/books/{series}/{book}/cover", h.bookCover)
+	h.mux.HandleFunc("GET "+Prefix+"/books/{series}/{book}/pages/{page}", h.page)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) root(w http.ResponseWriter, r *http.Request) {
+	series, err := h.library().Series()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+
+	updated := time.Time{}
+	for _, s := range series {
+		if s.ModTime.After(updated) {
+			updated = s.ModTime
+		}
+	}
+
+	f := newFeed("urn:manga:root", "Manga", updated, commonLinks(Prefix+"/", typeNavigation)...)
+	f.Entries = append(f.Entries, &entry{
+		ID:      "urn:manga:recent",
+		Title:   "Recently Added",
+		Updated: atomTime(updated),
+		Links: []*link{
+			{Rel: "subsection", Href: Prefix + "/recent", Type: typeAcquisition},
+		},
+	})
+	for _, s := range series {
+		f.Entries = append(f.Entries, seriesEntry(s))
+	}
+	writeXML(w, f, typeNavigation)
+}
+
+func (h *Handler) series(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("series")
+	books, err := h.library().Books(name)
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+
+	f := newFeed("urn:manga:series:"+name, name, latest(books), commonLinks(seriesHref(name), typeAcquisition)...)
+	f.Entries = bookEntries(books)
+	writeXML(w, f, typeAcquisition)
+}
+
+func (h *Handler) recent(w http.ResponseWriter, r *http.Request) {
+	books, err := h.library().AllBooks()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	slices.SortStableFunc(books, func(a, b *library.Book) int {
+		return b.ModTime.Compare(a.ModTime)
+	})
+	if len(books) > recentCount {
+		books = books[:recentCount]
+	}
+
+	f := newFeed("urn:manga:recent", "Recently Added", latest(books), commonLinks(Prefix+"/recent", typeAcquisition)...)
+	f.Entries = bookEntries(books)
+	writeXML(w, f, typeAcquisition)
+}
+
+// search matches every word of the query against the series and book names
+// and the chapter titles saved when the books were downloaded. Only the books
+// that are returned are opened.
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query().Get("q")
+	terms := strings.Fields(strings.ToLower(query))
+
+	books, err := h.library().AllBooks()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	records, err := h.db.Books()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	titles := make(map[string]string, len(records))
+	for _, rec := range records {
+		titles[rec.File] = rec.Title
+	}
+
+	books = slices.DeleteFunc(books, func(b *library.Book) bool {
+		name := strings.ToLower(b.Series + " " + b.Name + " " + titles[b.RelPath()])
+		for _, term := range terms {
+			if !strings.Contains(name, term) {
+				return true
+			}
+		}
+		return len(terms) == 0
+	})
+	total := len(books)
+	if len(books) > searchCount {
+		books = books[:searchCount]
+	}
+
+	self := Prefix + "/search?q=" + url.QueryEscape(query)
+	f := newFeed("urn:manga:search:"+query, "Search: "+query, latest(books), commonLinks(self, typeAcquisition)...)
+	f.TotalResults = total
+	f.Entries = bookEntries(books)
+	writeXML(w, f, typeAcquisition)
+}
+
+func (h *Handler) openSearch(w http.ResponseWriter, r *http.Request) {
+	writeXML(w, &openSearchDescription{
+		Xmlns:       "http://a9.com/-/spec/opensearch/1.1/",
+		ShortName:   "Manga",
+		Description: "Search the manga library",
+		InputEnc:    "UTF-8",
+		OutputEnc:   "UTF-8",
+		URL: &openSearchURL{
+			Type:     typeAcquisition,
+			Template: Prefix + "/search?q={searchTerms}",
+		},
+	}, typeOpenSearch)
+}
+
+func (h *Handler) download(w http.ResponseWriter, r *http.Request) {
+	b, err := h.library().Book(r.PathValue("series"), r.PathValue("book"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", typeCBZ)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(b.Name+".cbz")))
+	http.ServeFile(w, r, b.Path)
+}
+
+func (h *Handler) seriesCover(w http.ResponseWriter, r *http.Request) {
+	books, err := h.library().Books(r.PathValue("series"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	if len(books) == 0 {
+		library.HTTPError(w, library.ErrNotFound)
+		return
+	}
+	library.ServeCover(w, r, books[0])
+}
+
+func (h *Handler) bookCover(w http.ResponseWriter, r *http.Request) {
+	b, err := h.library().Book(r.PathValue("series"), r.PathValue("book"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	library.ServeCover(w, r, b)
+}
+
+// page serves a single page for OPDS-PSE, page numbers start at 0.
+func (h *Handler) page(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.PathValue("page"))
+	if err != nil {
+		http.Error(w, "invalid page number", http.StatusBadRequest)
+		return
+	}
+	b, err := h.library().Book(r.PathValue("series"), r.PathValue("book"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	library.ServePage(w, r, b, page)
+}
+
+func seriesEntry(s *library.Series) *entry {
+	return &entry{
+		ID:      "urn:manga:series:" + s.Name,
+		Title:   s.Name,
+		Updated: atomTime(s.ModTime),
+		Links: []*link{
+			{Rel: "subsection", Href: seriesHref(s.Name), Type: typeAcquisition},
+			{Rel: relImage, Href: seriesHref(s.Name) + "/cover"},
+			{Rel: relThumbnail, Href: seriesHref(s.Name) + "/cover"},
+		},
+	}
+}
+
+func bookEntries(books []*library.Book) []*entry {
+	entries := make([]*entry, 0, len(books))
+	for _, b := range books {
+		e, err := bookEntry(b)
+		if err != nil {
+			slog.Warn("Skipping unreadable book", "file", b.Path, "err", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func bookEntry(b *library.Book) (*entry, error) {
+	a, err := b.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	info, err := a.Info()
+	if err != nil {
+		slog.Warn("Could not read book.json", "file", b.Path, "err", err)
+	}
+
+	href := bookHref(b)
+	e := &entry{
+		ID:      "urn:manga:book:" + b.Series + "/" + b.Name,
+		Title:   b.Name,
+		Updated: atomTime(b.ModTime),
+		Authors: []author{},
+		Links: []*link{
+			{Rel: relAcquisition, Href: href + "/download", Type: typeCBZ, Length: b.Size},
+			{Rel: relImage, Href: href + "/cover"},
+			{Rel: relThumbnail, Href: href + "/cover"},
+			{Rel: "related", Href: seriesHref(b.Series), Type: typeAcquisition, Title: b.Series},
+		},
+	}
+	if pages := a.Pages(); len(pages) > 0 {
+		// the template must not be escaped, readers substitute it verbatim
+		e.Links = append(e.Links, &link{
+			Rel:      relStream,
+			Href:     href + "/pages/{pageNumber}",
+			Type:     library.ContentType(library.EntryName(pages[0])),
+			PSECount: len(pages),
+		})
+	}
+	if info != nil {
+		if info.Title != "" {
+			e.Title = b.Name + ": " + info.Title
+		}
+		if info.Author != "" {
+			e.Authors = append(e.Authors, author{Name: info.Author})
+		}
+		if !info.DateReleased.IsZero() {
+			e.Issued = info.DateReleased.Format(time.DateOnly)
+		}
+		if info.Summary != "" {
+			e.Content = &content{Type: "text", Text: info.Summary}
+		}
+	}
+	return e, nil
+}
+
+func commonLinks(self, typ string) []*link {
+	return []*link{
+		{Rel: "self", Href: self, Type: typ},
+		{Rel: "start", Href: Prefix + "/", Type: typeNavigation},
+		{Rel: "search", Href: Prefix + "/opensearch.xml", Type: typeOpenSearch},
+	}
+}
+
+func seriesHref(series string) string {
+	return path.Join(Prefix, "series", url.PathEscape(series))
+}
+
+// BookPath is the path of a book in the catalogue, the cover is served from
+// BookPath()+"/cover".
+func BookPath(series, book string) string {
+	return path.Join(Prefix, "books", url.PathEscape(series), url.PathEscape(book))
+}
+
+func bookHref(b *library.Book) string {
+	return BookPath(b.Series, b.Name)
+}
+
+func latest(books []*library.Book) time.Time {
+	t := time.Time{}
+	for _, b := range books {
+		if b.ModTime.After(t) {
+			t = b.ModTime
+		}
+	}
+	return t
+}
+
+func writeXML(w http.ResponseWriter, v any, typ string) {
+	w.Header().Set("Content-Type", typ+";charset=utf-8")
+	_, err := w.Write([]byte(xml.Header))
+	if err == nil {
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		err = enc.Encode(v)
+	}
+	if err != nil {
+		slog.Warn("Failed to write response", "err", err)
+	}
+}
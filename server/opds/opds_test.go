@@ -0,0 +1,180 @@
+package opds
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/internal/testutil"
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	dir := t.TempDir()
+	testutil.WriteBook(t, filepath.Join(dir, "One Piece", "One Piece #1.cbz"), &site.BookInfo{
+		Series:       "One Piece",
+		Title:        "Romance Dawn",
+		Author:       "Eiichiro Oda",
+		Summary:      "Luffy sets out",
+		DateReleased: time.Date(1997, 7, 22, 0, 0, 0, 0, time.UTC),
+	}, 3)
+	testutil.WriteBook(t, filepath.Join(dir, "Sakamoto Days", "Sakamoto Days #2.cbz"), nil, 2)
+
+	db, err := site.OpenDB(filepath.Join(t.TempDir(), "manga.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.AddBook(&site.BookRecord{
+		File:   "Sakamoto Days/Sakamoto Days #2.cbz",
+		Series: "Sakamoto Days",
+		Title:  "Hard-Boiled",
+	}))
+
+	srv := httptest.NewServer(New(db, func() *library.Library {
+		return library.New(dir)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// getFeed fetches and decodes a feed, the decoder doesn't understand the
+// prefixed dc and pse names so the raw body is returned for those.
+func getFeed(t *testing.T, uri string) (*feed, string) {
+	t.Helper()
+	resp, err := http.Get(uri)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "profile=opds-catalog")
+
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	f := &feed{}
+	require.NoError(t, xml.Unmarshal(b, f))
+	return f, string(b)
+}
+
+func findLink(links []*link, rel string) *link {
+	for _, l := range links {
+		if l.Rel == rel {
+			return l
+		}
+	}
+	return nil
+}
+
+func TestRoot(t *testing.T) {
+	srv := newTestServer(t)
+
+	f, _ := getFeed(t, srv.URL+Prefix+"/")
+	require.Len(t, f.Entries, 3)
+	assert.Equal(t, "Recently Added", f.Entries[0].Title)
+	assert.Equal(t, "One Piece", f.Entries[1].Title)
+	assert.Equal(t, "/opds/series/One%20Piece", findLink(f.Entries[1].Links, "subsection").Href)
+	assert.NotNil(t, findLink(f.Links, "search"))
+}
+
+func TestSeries(t *testing.T) {
+	srv := newTestServer(t)
+
+	f, body := getFeed(t, srv.URL+Prefix+"/series/One%20Piece")
+	require.Len(t, f.Entries, 1)
+	e := f.Entries[0]
+	assert.Equal(t, "One Piece #1: Romance Dawn", e.Title)
+	assert.Contains(t, body, "<dc:issued>1997-07-22</dc:issued>")
+	assert.Equal(t, "Luffy sets out", e.Content.Text)
+	assert.Equal(t, []author{{Name: "Eiichiro Oda"}}, e.Authors)
+
+	acquisition := findLink(e.Links, relAcquisition)
+	require.NotNil(t, acquisition)
+	assert.Equal(t, "/opds/books/One%20Piece/One%20Piece%20%231/download", acquisition.Href)
+	assert.Equal(t, typeCBZ, acquisition.Type)
+
+	stream := findLink(e.Links, relStream)
+	require.NotNil(t, stream)
+	assert.Equal(t, "/opds/books/One%20Piece/One%20Piece%20%231/pages/{pageNumber}", stream.Href)
+	assert.Equal(t, "image/jpeg", stream.Type)
+	assert.Contains(t, body, `pse:count="3"`)
+
+	resp, err := http.Get(srv.URL + Prefix + "/series/Missing")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestSearch(t *testing.T) {
+	srv := newTestServer(t)
+
+	f, _ := getFeed(t, srv.URL+Prefix+"/search?q=sakamoto")
+	require.Len(t, f.Entries, 1)
+	assert.Equal(t, "Sakamoto Days #2", f.Entries[0].Title)
+
+	f, _ = getFeed(t, srv.URL+Prefix+"/search?q=boiled+days")
+	require.Len(t, f.Entries, 1)
+	assert.Equal(t, "Sakamoto Days #2", f.Entries[0].Title)
+
+	f, _ = getFeed(t, srv.URL+Prefix+"/search?q=")
+	assert.Len(t, f.Entries, 0)
+}
+
+func TestBookEntry_pageType(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "Test", "Test #1.cbz")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	_, err = zw.Create("000.png")
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+
+	e, err := bookEntry(&library.Book{Series: "Test", Name: "Test #1", Path: file})
+	require.NoError(t, err)
+	stream := findLink(e.Links, relStream)
+	require.NotNil(t, stream)
+	assert.Equal(t, "image/png", stream.Type)
+}
+
+func TestPages(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + Prefix + "/books/One%20Piece/One%20Piece%20%231/pages/2")
+	require.NoError(t, err)
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+	assert.Equal(t, []byte{0xff, 0xd8, 2}, b)
+
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	req, err := http.NewRequest(http.MethodGet, srv.URL+Prefix+"/books/One%20Piece/One%20Piece%20%231/pages/2", nil)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	resp, err = http.Get(srv.URL + Prefix + "/books/One%20Piece/One%20Piece%20%231/cover")
+	require.NoError(t, err)
+	b, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0xd8, 0}, b)
+
+	resp, err = http.Get(srv.URL + Prefix + "/books/One%20Piece/One%20Piece%20%231/pages/3")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
@@ -0,0 +1,348 @@
+// Package reader is a small web ui for browsing and reading the library.
+package reader
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+)
+
+// Prefix is the path the reader is served under.
+const Prefix = "/reader"
+
+const defaultUser = "default"
+
+//go:embed index.html
+var indexHTML []byte
+
+type Handler struct {
+	db      *site.DB
+	library func() *library.Library
+	mux     *http.ServeMux
+}
+
+var _ http.Handler = &Handler{}
+
+// New creates the reader, lib returns the library to serve so changes to the
+// config's dir are picked up.
+func New(db *site.DB, lib func() *library.Library) *Handler {
+	h := &Handler{
+		db:      db,
+		library: lib,
+		mux:     http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("GET "+Prefix+"/{$}", h.index)
+	h.mux.HandleFunc("GET "+Prefix+"/api/series", h.listSeries)
+	h.mux.HandleFunc("GET "+Prefix+"/api/series/{series}", h.listBooks)
+	h.mux.HandleFunc("GET "+Prefix+"/api/books/{series}/{book}", h.getBook)
+	h.mux.HandleFunc("PUT "+Prefix+"/api/books/{series}/{book}/progress", h.setProgress)
+	h.mux.HandleFunc("GET "+Prefix+"/books/{series}/{book}/pages/{page}", h.page)
+	h.mux.HandleFunc("GET "+Prefix+"/series/{series}/cover", h.seriesCover)
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := w.Write(indexHTML)
+	if err != nil {
+		slog.Warn("Failed to write response", "err", err)
+	}
+}
+
+// user returns the name progress is saved under. An authenticating proxy can
+// set Remote-User, otherwise the ui stores the name in a cookie.
+func user(r *http.Request) string {
+	if u := strings.TrimSpace(r.Header.Get("Remote-User")); u != "" {
+		return u
+	}
+	if c, err := r.Cookie("manga_user"); err == nil {
+		if u, err := url.QueryUnescape(c.Value); err == nil && strings.TrimSpace(u) != "" {
+			return strings.TrimSpace(u)
+		}
+	}
+	return defaultUser
+}
+
+type seriesResponse struct {
+	Name    string    `json:"name"`
+	Books   int       `json:"books"`
+	Read    int       `json:"read"`
+	Cover   string    `json:"cover"`
+	Updated time.Time `json:"updated"`
+}
+
+func (h *Handler) listSeries(w http.ResponseWriter, r *http.Request) {
+	lib := h.library()
+	series, err := lib.Series()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	progress, err := h.db.UserProgress(user(r))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+
+	resp := make([]*seriesResponse, 0, len(series))
+	for _, s := range series {
+		books, err := lib.Books(s.Name)
+		if err != nil {
+			library.HTTPError(w, err)
+			return
+		}
+		sr := &seriesResponse{
+			Name:    s.Name,
+			Books:   len(books),
+			Cover:   seriesHref(s.Name) + "/cover",
+			Updated: s.ModTime,
+		}
+		for _, b := range books {
+			if p, ok := progress[b.RelPath()]; ok && p.Finished() {
+				sr.Read++
+			}
+		}
+		resp = append(resp, sr)
+	}
+	writeJSON(w, resp)
+}
+
+type bookResponse struct {
+	Series   string             `json:"series"`
+	Name     string             `json:"name"`
+	Title    string             `json:"title,omitempty"`
+	Cover    string             `json:"cover"`
+	Progress *site.ReadProgress `json:"progress"`
+}
+
+func (h *Handler) listBooks(w http.ResponseWriter, r *http.Request) {
+	books, err := h.library().Books(r.PathValue("series"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	progress, err := h.db.UserProgress(user(r))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+
+	resp := make([]*bookResponse, len(books))
+	for i, b := range books {
+		resp[i] = &bookResponse{
+			Series:   b.Series,
+			Name:     b.Name,
+			Cover:    bookHref(b) + "/pages/0",
+			Progress: progress[b.RelPath()],
+		}
+	}
+	writeJSON(w, resp)
+}
+
+type pageResponse struct {
+	URL    string        `json:"url"`
+	Type   site.PageType `json:"type"`
+	Width  int           `json:"width,omitempty"`
+	Height int           `json:"height,omitempty"`
+}
+
+type bookDetailResponse struct {
+	bookResponse
+	RightToLeft bool            `json:"rtl"`
+	LongStrip   bool            `json:"long_strip"`
+	Pages       []*pageResponse `json:"pages"`
+	Previous    string          `json:"previous,omitempty"`
+	Next        string          `json:"next,omitempty"`
+}
+
+func (h *Handler) getBook(w http.ResponseWriter, r *http.Request) {
+	lib := h.library()
+	b, err := lib.Book(r.PathValue("series"), r.PathValue("book"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	a, err := b.Open()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	defer a.Close()
+
+	info, err := a.Info()
+	if err != nil {
+		slog.Warn("Could not read book.json", "file", b.Path, "err", err)
+	}
+	if info == nil {
+		info = &site.BookInfo{}
+	}
+	progress, err := h.db.ReadProgress(user(r), b.RelPath())
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+
+	resp := &bookDetailResponse{
+		bookResponse: bookResponse{
+			Series:   b.Series,
+			Name:     b.Name,
+			Title:    info.Title,
+			Cover:    bookHref(b) + "/pages/0",
+			Progress: progress,
+		},
+		RightToLeft: info.RightToLeft,
+		LongStrip:   info.LongStrip,
+		Pages:       pages(b, a, info),
+	}
+
+	books, err := lib.Books(b.Series)
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	for i, other := range books {
+		if other.Name != b.Name {
+			continue
+		}
+		if i > 0 {
+			resp.Previous = books[i-1].Name
+		}
+		if i < len(books)-1 {
+			resp.Next = books[i+1].Name
+		}
+	}
+	writeJSON(w, resp)
+}
+
+// pages combines the images in the archive with the page info from
+// book.json, books without page info guess the type from the dimensions the
+// same way downloadBook does.
+func pages(b *library.Book, a *library.Archive, info *site.BookInfo) []*pageResponse {
+	files := a.Pages()
+	pages := make([]*pageResponse, 0, len(files))
+	for i := range files {
+		p := &pageResponse{
+			URL:  fmt.Sprintf("%s/pages/%d", bookHref(b), i),
+			Type: site.PageTypeStory,
+		}
+		if len(info.Pages) == len(files) && info.Pages[i] != nil {
+			p.Type = info.Pages[i].Type
+			p.Width = info.Pages[i].Width
+			p.Height = info.Pages[i].Height
+		} else if i == 0 {
+			p.Type = site.PageTypeFrontCover
+		}
+		if p.Type == "" {
+			p.Type = site.PageTypeStory
+		}
+		pages = append(pages, p)
+	}
+	return pages
+}
+
+type progressRequest struct {
+	Page int `json:"page"`
+}
+
+func (h *Handler) setProgress(w http.ResponseWriter, r *http.Request) {
+	b, err := h.library().Book(r.PathValue("series"), r.PathValue("book"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	req := &progressRequest{}
+	err = json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	a, err := b.Open()
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	pageCount := len(a.Pages())
+	a.Close()
+
+	if req.Page < 0 || req.Page >= pageCount {
+		http.Error(w, fmt.Sprintf("page %d out of range", req.Page), http.StatusBadRequest)
+		return
+	}
+
+	progress := &site.ReadProgress{
+		Page:      req.Page,
+		Pages:     pageCount,
+		UpdatedAt: time.Now(),
+	}
+	err = h.db.SetReadProgress(user(r), b.RelPath(), progress)
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	writeJSON(w, progress)
+}
+
+func (h *Handler) page(w http.ResponseWriter, r *http.Request) {
+	page, err := strconv.Atoi(r.PathValue("page"))
+	if err != nil {
+		http.Error(w, "invalid page number", http.StatusBadRequest)
+		return
+	}
+	b, err := h.library().Book(r.PathValue("series"), r.PathValue("book"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	library.ServePage(w, r, b, page)
+}
+
+func (h *Handler) seriesCover(w http.ResponseWriter, r *http.Request) {
+	books, err := h.library().Books(r.PathValue("series"))
+	if err != nil {
+		library.HTTPError(w, err)
+		return
+	}
+	if len(books) == 0 {
+		library.HTTPError(w, library.ErrNotFound)
+		return
+	}
+	library.ServeCover(w, r, books[0])
+}
+
+// ReadPath is the path of the page in the ui that opens a book.
+func ReadPath(series, book string) string {
+	return Prefix + "/#/read/" + url.PathEscape(series) + "/" + url.PathEscape(book) + "/0"
+}
+
+func seriesHref(series string) string {
+	return path.Join(Prefix, "series", url.PathEscape(series))
+}
+
+func bookHref(b *library.Book) string {
+	return path.Join(Prefix, "books", url.PathEscape(b.Series), url.PathEscape(b.Name))
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Warn("Failed to write response", "err", err)
+	}
+}
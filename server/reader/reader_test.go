@@ -0,0 +1,136 @@
+package reader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/abibby/manga/internal/testutil"
+	"github.com/abibby/manga/library"
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	dir := t.TempDir()
+	testutil.WriteBook(t, filepath.Join(dir, "One Piece", "One Piece #1.cbz"), &site.BookInfo{
+		Series:      "One Piece",
+		RightToLeft: true,
+		Pages: []*site.InfoPage{
+			{Type: site.PageTypeFrontCover, Width: 800, Height: 1200},
+			{Type: site.PageTypeStory, Width: 800, Height: 1200},
+			{Type: site.PageTypeSpread, Width: 1600, Height: 1200},
+		},
+	}, 3)
+	testutil.WriteBook(t, filepath.Join(dir, "One Piece", "One Piece #2.cbz"), nil, 2)
+
+	db, err := site.OpenDB(filepath.Join(t.TempDir(), "manga.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	srv := httptest.NewServer(New(db, func() *library.Library {
+		return library.New(dir)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, method, uri, body, user string, v any) int {
+	t.Helper()
+	req, err := http.NewRequest(method, uri, strings.NewReader(body))
+	require.NoError(t, err)
+	if user != "" {
+		req.AddCookie(&http.Cookie{Name: "manga_user", Value: user})
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if v != nil && resp.StatusCode == http.StatusOK {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(v))
+	}
+	return resp.StatusCode
+}
+
+func TestIndex(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + Prefix + "/")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+}
+
+func TestGetBook(t *testing.T) {
+	srv := newTestServer(t)
+
+	book := &bookDetailResponse{}
+	status := do(t, http.MethodGet, srv.URL+Prefix+"/api/books/One%20Piece/One%20Piece%20%231", "", "", book)
+	require.Equal(t, http.StatusOK, status)
+	assert.True(t, book.RightToLeft)
+	assert.Equal(t, "", book.Previous)
+	assert.Equal(t, "One Piece #2", book.Next)
+	require.Len(t, book.Pages, 3)
+	assert.Equal(t, site.PageTypeSpread, book.Pages[2].Type)
+	assert.Equal(t, "/reader/books/One%20Piece/One%20Piece%20%231/pages/2", book.Pages[2].URL)
+
+	// books without a book.json still get a cover
+	book = &bookDetailResponse{}
+	status = do(t, http.MethodGet, srv.URL+Prefix+"/api/books/One%20Piece/One%20Piece%20%232", "", "", book)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, site.PageTypeFrontCover, book.Pages[0].Type)
+	assert.Equal(t, site.PageTypeStory, book.Pages[1].Type)
+	assert.Equal(t, "One Piece #1", book.Previous)
+}
+
+func TestProgress(t *testing.T) {
+	srv := newTestServer(t)
+	progressURL := srv.URL + Prefix + "/api/books/One%20Piece/One%20Piece%20%231/progress"
+
+	status := do(t, http.MethodPut, progressURL, `{"page":2}`, "alex", nil)
+	require.Equal(t, http.StatusOK, status)
+	status = do(t, http.MethodPut, progressURL, `{"page":1}`, "sam", nil)
+	require.Equal(t, http.StatusOK, status)
+	status = do(t, http.MethodPut, progressURL, `{"page":3}`, "sam", nil)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	series := []*seriesResponse{}
+	do(t, http.MethodGet, srv.URL+Prefix+"/api/series", "", "alex", &series)
+	require.Len(t, series, 1)
+	assert.Equal(t, 1, series[0].Read)
+	assert.Equal(t, 2, series[0].Books)
+
+	books := []*bookResponse{}
+	do(t, http.MethodGet, srv.URL+Prefix+"/api/series/One%20Piece", "", "sam", &books)
+	require.Len(t, books, 2)
+	require.NotNil(t, books[0].Progress)
+	assert.Equal(t, 1, books[0].Progress.Page)
+	assert.Equal(t, 3, books[0].Progress.Pages)
+	assert.Nil(t, books[1].Progress)
+
+	books = []*bookResponse{}
+	do(t, http.MethodGet, srv.URL+Prefix+"/api/series/One%20Piece", "", "", &books)
+	assert.Nil(t, books[0].Progress)
+}
+
+func TestPage(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp, err := http.Get(srv.URL + Prefix + "/books/One%20Piece/One%20Piece%20%231/pages/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+	assert.NotEmpty(t, resp.Header.Get("ETag"))
+	assert.NotEmpty(t, resp.Header.Get("Last-Modified"))
+	assert.Equal(t, "public, max-age=86400", resp.Header.Get("Cache-Control"))
+
+	resp, err = http.Get(srv.URL + Prefix + "/books/..%2F..%2Fetc/passwd/pages/1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
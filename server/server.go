@@ -0,0 +1,117 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/abibby/manga/config"
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/abibby/manga/site"
+)
+
+// Server is the http interface of the watcher.
+type Server struct {
+	db        *site.DB
+	scheduler *scheduler.Scheduler
+	config    func() *config.Config
+	mux       *http.ServeMux
+}
+
+var _ http.Handler = &Server{}
+
+// New creates the server, cfg returns the config currently used by the
+// watcher.
+func New(db *site.DB, s *scheduler.Scheduler, cfg func() *config.Config) *Server {
+	srv := &Server{
+		db:        db,
+		scheduler: s,
+		config:    cfg,
+		mux:       http.NewServeMux(),
+	}
+
+	srv.mux.HandleFunc("GET /api/sources", srv.listSources)
+	srv.mux.HandleFunc("POST /api/sources", srv.requireToken(srv.addSource))
+	srv.mux.HandleFunc("DELETE /api/sources/{id}", srv.requireToken(srv.removeSource))
+	srv.mux.HandleFunc("POST /api/sources/{id}/sync", srv.requireToken(srv.syncSource))
+	srv.mux.HandleFunc("POST /api/sync", srv.requireToken(srv.syncAll))
+	srv.mux.HandleFunc("GET /api/status", srv.status)
+	srv.mux.HandleFunc("GET /api/series", srv.listSeries)
+	srv.mux.HandleFunc("GET /api/series/{id}/books", srv.listSeriesBooks)
+	srv.mux.HandleFunc("GET /api/books", srv.listBooks)
+	srv.mux.HandleFunc("GET /healthz", srv.healthz)
+
+	return srv
+}
+
+// Handle registers an extra handler on the server.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Debug("http request", "method", r.Method, "url", r.URL)
+	s.mux.ServeHTTP(w, r)
+}
+
+// requireToken only serves a handler to requests with the api_token from the
+// config as a bearer token. The handlers change the config file or start
+// downloads so they are disabled when no token is set.
+func (s *Server) requireToken(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := s.config().APIToken
+		if token == "" {
+			writeError(w, http.StatusForbidden, errors.New("set api_token in the config to enable this endpoint"))
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("invalid api token"))
+			return
+		}
+		h(w, r)
+	}
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthz reports if the scheduler is running and the database can be read,
+// it is used by manga healthcheck.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	err := s.db.Check()
+	if err == nil && !s.scheduler.Alive() {
+		err = errors.New("scheduler is not running")
+	}
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, &healthResponse{Status: "unhealthy", Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, &healthResponse{Status: "ok"})
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		slog.Warn("Failed to write response", "err", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	if status >= 500 {
+		slog.Error("Request failed", "err", err)
+	}
+	writeJSON(w, status, &errorResponse{Error: err.Error()})
+}
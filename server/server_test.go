@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abibby/manga/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequireToken(t *testing.T) {
+	cfg := &config.Config{}
+	s := &Server{config: func() *config.Config { return cfg }}
+	h := s.requireToken(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	testCases := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"disabled without a token", "", "Bearer ", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer nope", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"valid", "secret", "Bearer secret", http.StatusNoContent},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg.APIToken = tc.token
+			r := httptest.NewRequest(http.MethodPost, "/api/sync", nil)
+			if tc.header != "" {
+				r.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			h(w, r)
+			assert.Equal(t, tc.want, w.Code)
+		})
+	}
+}
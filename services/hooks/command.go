@@ -0,0 +1,48 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Command runs a program once for every changed series. The series is passed
+// in the environment:
+//
+//	MANGA_SOURCE            the source url
+//	MANGA_SOURCE_NAME       the source name, if it has one
+//	MANGA_LIBRARY_DIR       the library dir
+//	MANGA_SERIES            the series name
+//	MANGA_SERIES_DIR        the series folder
+//	MANGA_FILES             the new cbz files separated by newlines
+//	MANGA_REMOTE_SERIES_DIR the series folder on the hook's dir, the same as
+//	                        MANGA_SERIES_DIR when dir isn't set
+type Command struct {
+	hook *Hook
+}
+
+func (c *Command) Scan(ctx context.Context, scan *Scan) error {
+	for _, s := range scan.Series {
+		cmd := exec.CommandContext(ctx, c.hook.Command[0], c.hook.Command[1:]...)
+		cmd.Env = append(os.Environ(),
+			"MANGA_SOURCE="+scan.SourceURL,
+			"MANGA_SOURCE_NAME="+scan.SourceName,
+			"MANGA_LIBRARY_DIR="+scan.LibraryDir,
+			"MANGA_SERIES="+s.Name,
+			"MANGA_SERIES_DIR="+s.Dir,
+			"MANGA_FILES="+strings.Join(s.Files, "\n"),
+			"MANGA_REMOTE_SERIES_DIR="+c.hook.remotePath(scan.LibraryDir, s.Dir),
+		)
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			slog.Debug("Hook output", "command", c.hook.Command[0], "output", string(out))
+		}
+		if err != nil {
+			return fmt.Errorf("%s failed for %s: %w: %s", c.hook.Command[0], s.Name, err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+// Package hooks tells media servers about new books once a run has finished
+// so they don't have to wait for their next scheduled scan.
+package hooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Hook is an entry in the hooks section of the config file.
+type Hook struct {
+	// Type is one of komga, kavita, jellyfin or command
+	Type     string
+	URL      string
+	APIKey   string `mapstructure:"api_key"`
+	Username string
+	Password string
+	// Dir is the path of the library on the media server, set it when the
+	// server mounts the library somewhere other than dir
+	Dir string
+	// Command is run once per series with MANGA_* environment variables
+	Command []string
+}
+
+// Scan is the set of series that changed during a run.
+type Scan struct {
+	SourceURL  string
+	SourceName string
+	// LibraryDir is the local library dir
+	LibraryDir string
+	Series     []*Series
+}
+
+type Series struct {
+	Name string
+	// Dir is the local path of the series folder
+	Dir   string
+	Files []string
+}
+
+// Scanner asks a media server to pick up the changes in a scan.
+type Scanner interface {
+	Scan(ctx context.Context, scan *Scan) error
+}
+
+// Validate checks that every hook can be created.
+func Validate(hooks []*Hook) error {
+	errs := []error{}
+	for i, h := range hooks {
+		_, err := NewScanner(h)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("hooks[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NewScanner creates the scanner for a hook.
+func NewScanner(h *Hook) (Scanner, error) {
+	if h == nil {
+		return nil, fmt.Errorf("empty hook")
+	}
+	if h.Type == "command" {
+		if len(h.Command) == 0 {
+			return nil, fmt.Errorf("command hooks need a command")
+		}
+		return &Command{hook: h}, nil
+	}
+
+	if h.URL == "" {
+		return nil, fmt.Errorf("%s hooks need a url", h.Type)
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("%s hook url must be absolute, got %q", h.Type, h.URL)
+	}
+	switch h.Type {
+	case "komga":
+		if h.APIKey == "" && h.Username == "" {
+			return nil, fmt.Errorf("komga hooks need an api_key or username and password")
+		}
+		return &Komga{hook: h}, nil
+	case "kavita":
+		if h.APIKey == "" {
+			return nil, fmt.Errorf("kavita hooks need an api_key")
+		}
+		return &Kavita{hook: h}, nil
+	case "jellyfin":
+		if h.APIKey == "" {
+			return nil, fmt.Errorf("jellyfin hooks need an api_key")
+		}
+		return &Jellyfin{hook: h}, nil
+	default:
+		return nil, fmt.Errorf("unknown hook type %q", h.Type)
+	}
+}
+
+// remotePath translates a local path to the path the media server sees.
+func (h *Hook) remotePath(libraryDir, local string) string {
+	if h.Dir == "" {
+		return local
+	}
+	rel, err := filepath.Rel(libraryDir, local)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return local
+	}
+	return path.Join(h.Dir, filepath.ToSlash(rel))
+}
+
+func (h *Hook) endpoint(p string) string {
+	return strings.TrimSuffix(h.URL, "/") + p
+}
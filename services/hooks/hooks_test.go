@@ -0,0 +1,179 @@
+package hooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBook struct{ id string }
+
+func (b *testBook) Pages() ([]site.Page, error) { return nil, nil }
+func (b *testBook) ID() string                  { return b.id }
+func (b *testBook) Series() string              { return "" }
+func (b *testBook) SeriesID() string            { return "" }
+func (b *testBook) Chapter() float64            { return 0 }
+func (b *testBook) Volume() int                 { return 0 }
+func (b *testBook) Info() *site.BookInfo        { return &site.BookInfo{} }
+
+type request struct {
+	method string
+	path   string
+	query  string
+	header http.Header
+	body   string
+}
+
+// standIn records requests and answers them from responses keyed by path.
+type standIn struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*request
+}
+
+func newStandIn(t *testing.T, responses map[string]any) *standIn {
+	s := &standIn{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.requests = append(s.requests, &request{method: r.Method, path: r.URL.Path, query: r.URL.RawQuery, header: r.Header, body: string(body)})
+		s.mu.Unlock()
+		if resp, ok := responses[r.URL.Path]; ok {
+			_ = json.NewEncoder(w).Encode(resp)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func newTestService(t *testing.T, hooks ...*Hook) *Service {
+	s := &Service{pending: map[string]map[string][]string{}}
+	require.NoError(t, s.SetConfig(hooks, "/library"))
+	return s
+}
+
+func downloaded(src *site.Source, file string) *site.Event {
+	return &site.Event{Type: site.EventBookDownloaded, Source: src, Book: &testBook{id: file}, File: file}
+}
+
+func TestKomga(t *testing.T) {
+	komga := newStandIn(t, map[string]any{
+		"/api/v1/libraries": []*komgaLibrary{
+			{ID: "all", Name: "All", Root: "/data"},
+			{ID: "manga", Name: "Manga", Root: "/data/manga"},
+			{ID: "books", Name: "Books", Root: "/data/books"},
+		},
+	})
+	s := newTestService(t, &Hook{Type: "komga", URL: komga.URL, APIKey: "key", Dir: "/data/manga"})
+	src := &site.Source{URL: "https://example.com/one-piece"}
+
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #1.cbz"))
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #2.cbz"))
+	s.onEvent(downloaded(src, "/library/Sakamoto Days/Sakamoto Days #1.cbz"))
+	s.Flush(src)
+
+	require.Len(t, komga.requests, 2)
+	assert.Equal(t, "GET", komga.requests[0].method)
+	assert.Equal(t, "key", komga.requests[0].header.Get("X-API-Key"))
+	assert.Equal(t, "POST", komga.requests[1].method)
+	assert.Equal(t, "/api/v1/libraries/manga/scan", komga.requests[1].path)
+	assert.Equal(t, "deep=false", komga.requests[1].query)
+
+	// nothing downloaded, nothing scanned
+	s.Flush(src)
+	assert.Len(t, komga.requests, 2)
+}
+
+func TestKavita(t *testing.T) {
+	kavita := newStandIn(t, nil)
+	s := newTestService(t, &Hook{Type: "kavita", URL: kavita.URL + "/", APIKey: "key", Dir: "/manga"})
+	src := &site.Source{URL: "https://example.com/one-piece"}
+
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #1.cbz"))
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #2.cbz"))
+	s.Flush(src)
+
+	require.Len(t, kavita.requests, 1)
+	assert.Equal(t, "/api/Library/scan-folder", kavita.requests[0].path)
+	assert.JSONEq(t, `{"apiKey":"key","folderPath":"/manga/One Piece"}`, kavita.requests[0].body)
+}
+
+func TestJellyfin(t *testing.T) {
+	jellyfin := newStandIn(t, nil)
+	s := newTestService(t, &Hook{Type: "jellyfin", URL: jellyfin.URL, APIKey: "key"})
+	src := &site.Source{URL: "https://example.com/one-piece"}
+
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #1.cbz"))
+	s.onEvent(downloaded(src, "/library/Sakamoto Days/Sakamoto Days #1.cbz"))
+	// other sources are flushed separately
+	s.onEvent(downloaded(&site.Source{URL: "https://example.com/other"}, "/library/Other/Other #1.cbz"))
+	s.Flush(src)
+
+	require.Len(t, jellyfin.requests, 1)
+	assert.Equal(t, "/Library/Media/Updated", jellyfin.requests[0].path)
+	assert.Equal(t, `MediaBrowser Token="key"`, jellyfin.requests[0].header.Get("Authorization"))
+	assert.JSONEq(t, `{"Updates":[
+		{"Path":"/library/One Piece","UpdateType":"Created"},
+		{"Path":"/library/Sakamoto Days","UpdateType":"Created"}
+	]}`, jellyfin.requests[0].body)
+}
+
+func TestCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	s := newTestService(t, &Hook{
+		Type:    "command",
+		Dir:     "/data/manga",
+		Command: []string{"sh", "-c", `printf '%s|%s|%s|%s|%s\n' "$MANGA_SOURCE" "$MANGA_SERIES" "$MANGA_SERIES_DIR" "$MANGA_REMOTE_SERIES_DIR" "$MANGA_FILES" >> "$0"`, out},
+	})
+	src := &site.Source{URL: "https://example.com/one-piece"}
+
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #1.cbz"))
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #2.cbz"))
+	s.Flush(src)
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/one-piece|One Piece|/library/One Piece|/data/manga/One Piece|/library/One Piece/One Piece #1.cbz\n/library/One Piece/One Piece #2.cbz\n", string(b))
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(nil))
+	assert.NoError(t, Validate([]*Hook{{Type: "komga", URL: "http://komga", Username: "u", Password: "p"}}))
+	assert.Error(t, Validate([]*Hook{{Type: "komga", URL: "http://komga"}}))
+	assert.Error(t, Validate([]*Hook{{Type: "kavita", URL: "kavita:5000", APIKey: "key"}}))
+	assert.Error(t, Validate([]*Hook{{Type: "plex", URL: "http://plex", APIKey: "key"}}))
+	assert.Error(t, Validate([]*Hook{{Type: "command"}}))
+}
+
+func TestCommand_bundled(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.txt")
+	s := newTestService(t, &Hook{
+		Type:    "command",
+		Command: []string{"sh", "-c", `printf '%s\n' "$MANGA_FILES" >> "$0"`, out},
+	})
+	src := &site.Source{URL: "https://example.com/one-piece"}
+
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #1.cbz"))
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #2.cbz"))
+	s.onEvent(downloaded(src, "/library/One Piece/One Piece #3.cbz"))
+	s.onEvent(&site.Event{
+		Type:    site.EventVolumeBundled,
+		Source:  src,
+		File:    "/library/One Piece/One Piece Vol. 01.cbz",
+		Removed: []string{"/library/One Piece/One Piece #1.cbz", "/library/One Piece/One Piece #2.cbz"},
+	})
+	s.Flush(src)
+
+	b, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "/library/One Piece/One Piece #3.cbz\n/library/One Piece/One Piece Vol. 01.cbz\n", string(b))
+}
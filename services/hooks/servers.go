@@ -0,0 +1,169 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Komga's api can only scan whole libraries, there is no endpoint for a single
+// folder. The library that contains a changed series is scanned without
+// deep so only new and modified files are read, and each library is scanned
+// once per run however many of its series changed.
+type Komga struct {
+	hook *Hook
+}
+
+type komgaLibrary struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Root string `json:"root"`
+}
+
+func (k *Komga) Scan(ctx context.Context, scan *Scan) error {
+	libraries := []*komgaLibrary{}
+	err := k.do(ctx, http.MethodGet, "/api/v1/libraries", &libraries)
+	if err != nil {
+		return err
+	}
+
+	scanned := map[string]bool{}
+	for _, s := range scan.Series {
+		dir := k.hook.remotePath(scan.LibraryDir, s.Dir)
+		lib := komgaLibraryFor(libraries, dir)
+		if lib == nil {
+			slog.Warn("No komga library contains the series", "series", s.Name, "dir", dir)
+			continue
+		}
+		if scanned[lib.ID] {
+			continue
+		}
+		scanned[lib.ID] = true
+		err = k.do(ctx, http.MethodPost, "/api/v1/libraries/"+lib.ID+"/scan?deep=false", nil)
+		if err != nil {
+			return err
+		}
+		slog.Info("Started komga scan", "library", lib.Name)
+	}
+	return nil
+}
+
+// komgaLibraryFor returns the library with the longest root containing dir.
+func komgaLibraryFor(libraries []*komgaLibrary, dir string) *komgaLibrary {
+	var found *komgaLibrary
+	for _, lib := range libraries {
+		root := strings.TrimSuffix(lib.Root, "/")
+		if dir != root && !strings.HasPrefix(dir, root+"/") {
+			continue
+		}
+		if found == nil || len(root) > len(found.Root) {
+			found = lib
+		}
+	}
+	return found
+}
+
+func (k *Komga) do(ctx context.Context, method, p string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, method, k.hook.endpoint(p), nil)
+	if err != nil {
+		return err
+	}
+	if k.hook.APIKey != "" {
+		req.Header.Set("X-API-Key", k.hook.APIKey)
+	} else {
+		req.SetBasicAuth(k.hook.Username, k.hook.Password)
+	}
+	return send(req, v)
+}
+
+// Kavita scans just the series folder.
+type Kavita struct {
+	hook *Hook
+}
+
+type kavitaScanFolder struct {
+	APIKey     string `json:"apiKey"`
+	FolderPath string `json:"folderPath"`
+}
+
+func (k *Kavita) Scan(ctx context.Context, scan *Scan) error {
+	for _, s := range scan.Series {
+		err := postJSON(ctx, k.hook.endpoint("/api/Library/scan-folder"), &kavitaScanFolder{
+			APIKey:     k.hook.APIKey,
+			FolderPath: k.hook.remotePath(scan.LibraryDir, s.Dir),
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Jellyfin is told which series folders changed in a single request.
+type Jellyfin struct {
+	hook *Hook
+}
+
+type jellyfinUpdates struct {
+	Updates []*jellyfinUpdate `json:"Updates"`
+}
+
+type jellyfinUpdate struct {
+	Path       string `json:"Path"`
+	UpdateType string `json:"UpdateType"`
+}
+
+func (j *Jellyfin) Scan(ctx context.Context, scan *Scan) error {
+	body := &jellyfinUpdates{Updates: make([]*jellyfinUpdate, len(scan.Series))}
+	for i, s := range scan.Series {
+		body.Updates[i] = &jellyfinUpdate{
+			Path:       j.hook.remotePath(scan.LibraryDir, s.Dir),
+			UpdateType: "Created",
+		}
+	}
+	return postJSON(ctx, j.hook.endpoint("/Library/Media/Updated"), body, map[string]string{
+		"Authorization": fmt.Sprintf(`MediaBrowser Token="%s"`, j.hook.APIKey),
+	})
+}
+
+func postJSON(ctx context.Context, uri string, body any, headers map[string]string) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return send(req, nil)
+}
+
+func send(req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s failed with status %s: %s", req.Method, path.Clean(req.URL.Path), resp.Status, bytes.TrimSpace(msg))
+	}
+	if v == nil {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return fmt.Errorf("invalid response from %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
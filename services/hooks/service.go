@@ -0,0 +1,117 @@
+package hooks
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abibby/manga/site"
+)
+
+const scanTimeout = 5 * time.Minute
+
+// Service collects the books downloaded during a run and runs the hooks once
+// the run has finished.
+type Service struct {
+	mu       sync.Mutex
+	dir      string
+	scanners []Scanner
+	pending  map[string]map[string][]string
+}
+
+// New creates the service and starts listening for download events, dir is
+// the library dir.
+func New(hooks []*Hook, dir string) (*Service, error) {
+	s := &Service{
+		pending: map[string]map[string][]string{},
+	}
+	err := s.SetConfig(hooks, dir)
+	if err != nil {
+		return nil, err
+	}
+	site.AddListener(s.onEvent)
+	return s, nil
+}
+
+// SetConfig replaces the hooks.
+func (s *Service) SetConfig(hooks []*Hook, dir string) error {
+	scanners := make([]Scanner, len(hooks))
+	for i, h := range hooks {
+		scanner, err := NewScanner(h)
+		if err != nil {
+			return err
+		}
+		scanners[i] = scanner
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scanners = scanners
+	s.dir = dir
+	return nil
+}
+
+func (s *Service) onEvent(e *site.Event) {
+	if e.Type != site.EventBookDownloaded && e.Type != site.EventVolumeBundled {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	series, ok := s.pending[e.Source.URL]
+	if !ok {
+		series = map[string][]string{}
+		s.pending[e.Source.URL] = series
+	}
+	dir := filepath.Dir(e.File)
+	// chapters removed after they were bundled aren't there to scan
+	series[dir] = slices.DeleteFunc(series[dir], func(file string) bool {
+		return slices.Contains(e.Removed, file)
+	})
+	series[dir] = append(series[dir], e.File)
+}
+
+// Flush runs the hooks for the series src changed during its last run.
+func (s *Service) Flush(src *site.Source) {
+	s.mu.Lock()
+	changed := s.pending[src.URL]
+	delete(s.pending, src.URL)
+	scanners := s.scanners
+	dir := s.dir
+	s.mu.Unlock()
+
+	if len(changed) == 0 || len(scanners) == 0 {
+		return
+	}
+
+	scan := &Scan{
+		SourceURL:  src.URL,
+		SourceName: src.Name,
+		LibraryDir: dir,
+		Series:     make([]*Series, 0, len(changed)),
+	}
+	for seriesDir, files := range changed {
+		scan.Series = append(scan.Series, &Series{
+			Name:  filepath.Base(seriesDir),
+			Dir:   seriesDir,
+			Files: files,
+		})
+	}
+	slices.SortFunc(scan.Series, func(a, b *Series) int {
+		return strings.Compare(a.Dir, b.Dir)
+	})
+
+	for _, scanner := range scanners {
+		ctx, cancel := context.WithTimeout(context.Background(), scanTimeout)
+		err := scanner.Scan(ctx, scan)
+		cancel()
+		if err != nil {
+			slog.Error("Library hook failed", "source", src.URL, "err", err)
+		}
+	}
+}
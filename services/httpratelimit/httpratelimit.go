@@ -2,9 +2,19 @@ package httpratelimit
 
 import (
 	"net/http"
+	"sync/atomic"
 	"time"
 )
 
+// waited is the total time requests have spent waiting for the limiter.
+var waited atomic.Int64
+
+// WaitTime returns how long requests from every throttled transport have
+// waited for the rate limiter in total.
+func WaitTime() time.Duration {
+	return time.Duration(waited.Load())
+}
+
 // ThrottledTransport wraps a standard RoundTripper and enforces a rate limit.
 type ThrottledTransport struct {
 	underlying http.RoundTripper
@@ -22,11 +32,14 @@ func NewThrottledTransport(d time.Duration) *ThrottledTransport {
 // RoundTrip intercepts the request and blocks until the rate limiter allows it through.
 func (t *ThrottledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	// Wait for a tick, or abort if the request context is canceled
+	start := time.Now()
 	select {
 	case <-t.limiter:
 	case <-req.Context().Done():
+		waited.Add(int64(time.Since(start)))
 		return nil, req.Context().Err()
 	}
+	waited.Add(int64(time.Since(start)))
 
 	// Fallback to default transport if none was provided
 	transport := t.underlying
@@ -0,0 +1,143 @@
+// Package metrics exposes the watcher's downloads, errors and schedule as
+// prometheus metrics.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"image"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/abibby/manga/services/httpratelimit"
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/abibby/manga/site"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "manga"
+
+// Error classes used for the errors_total metric.
+const (
+	ClassTimeout = "timeout"
+	ClassNetwork = "network"
+	ClassHTTP    = "http"
+	ClassDecode  = "decode"
+	ClassOther   = "other"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	books  *prometheus.CounterVec
+	pages  *prometheus.CounterVec
+	bytes  *prometheus.CounterVec
+	errors *prometheus.CounterVec
+}
+
+// New creates the metrics and starts listening for download events.
+func New(s *scheduler.Scheduler) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		books: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "books_downloaded_total",
+			Help:      "Number of books downloaded.",
+		}, []string{"connector"}),
+		pages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pages_downloaded_total",
+			Help:      "Number of pages downloaded.",
+		}, []string{"connector"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "downloaded_bytes_total",
+			Help:      "Bytes downloaded for pages.",
+		}, []string{"connector"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Number of failed books and source runs by error class.",
+		}, []string{"connector", "class"}),
+	}
+
+	m.registry.MustRegister(
+		m.books,
+		m.pages,
+		m.bytes,
+		m.errors,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ratelimit_wait_seconds_total",
+			Help:      "Time requests have spent waiting for the rate limiter.",
+		}, func() float64 {
+			return httpratelimit.WaitTime().Seconds()
+		}),
+		newSchedulerCollector(s),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	site.AddListener(m.onEvent)
+	return m
+}
+
+// Handler serves the metrics in the prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RecordRun counts the error returned by a source run.
+func (m *Metrics) RecordRun(src *site.Source, err error) {
+	if err == nil {
+		return
+	}
+	m.errors.WithLabelValues(connector(src), ErrorClass(err)).Inc()
+}
+
+func (m *Metrics) onEvent(e *site.Event) {
+	c := e.Connector
+	switch e.Type {
+	case site.EventPageDownloaded:
+		m.pages.WithLabelValues(c).Inc()
+		m.bytes.WithLabelValues(c).Add(float64(e.Bytes))
+	case site.EventBookDownloaded:
+		m.books.WithLabelValues(c).Inc()
+	case site.EventBookFailed:
+		m.errors.WithLabelValues(c, ErrorClass(e.Err)).Inc()
+	}
+}
+
+// ErrorClass groups errors so they can be counted without a label per
+// message.
+func ErrorClass(err error) string {
+	var netErr net.Error
+	isNet := errors.As(err, &netErr)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ClassTimeout
+	case isNet && netErr.Timeout():
+		return ClassTimeout
+	case errors.Is(err, site.ErrHTTPStatus):
+		return ClassHTTP
+	case errors.Is(err, image.ErrFormat):
+		return ClassDecode
+	case isNet:
+		return ClassNetwork
+	default:
+		return ClassOther
+	}
+}
+
+func connector(src *site.Source) string {
+	if src == nil {
+		return ""
+	}
+	if c, ok := site.FindSite(src.URL); ok {
+		return c.SiteName()
+	}
+	return ""
+}
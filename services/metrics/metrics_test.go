@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/abibby/manga/site"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestErrorClass(t *testing.T) {
+	testCases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("failed to fetch image: %w", context.DeadlineExceeded), ClassTimeout},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: timeoutError{}}, ClassTimeout},
+		{&url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, ClassNetwork},
+		{fmt.Errorf("image fetch failed with 404 Not Found: %w", site.ErrHTTPStatus), ClassHTTP},
+		{fmt.Errorf("could not decode image: %w", image.ErrFormat), ClassDecode},
+		{errors.New("something else"), ClassOther},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tc.want, ErrorClass(tc.err))
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	db, err := site.OpenDB(filepath.Join(t.TempDir(), "manga.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	s := scheduler.New(db, func(src *site.Source) error { return nil })
+	src := &site.Source{Name: "One Piece", URL: "https://example.com/one-piece", Frequency: time.Hour}
+	require.NoError(t, s.SetSources([]*site.Source{src}, scheduler.Defaults{Frequency: time.Hour}))
+
+	m := New(s)
+	// a series is labeled by the site each page came from
+	m.onEvent(&site.Event{Type: site.EventPageDownloaded, Source: src, Connector: "MangaDex", Bytes: 1000})
+	m.onEvent(&site.Event{Type: site.EventPageDownloaded, Source: src, Connector: "MangaDex", Bytes: 500})
+	m.onEvent(&site.Event{Type: site.EventPageDownloaded, Source: src, Connector: "MangaPlus", Bytes: 200})
+	m.onEvent(&site.Event{Type: site.EventBookDownloaded, Source: src, Connector: "MangaDex"})
+	m.onEvent(&site.Event{Type: site.EventBookFailed, Source: src, Connector: "MangaDex", Err: fmt.Errorf("%w", site.ErrHTTPStatus)})
+	m.RecordRun(src, errors.New("broken"))
+	m.RecordRun(src, nil)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.pages.WithLabelValues("MangaDex")))
+	assert.Equal(t, 1500.0, testutil.ToFloat64(m.bytes.WithLabelValues("MangaDex")))
+	assert.Equal(t, 200.0, testutil.ToFloat64(m.bytes.WithLabelValues("MangaPlus")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.books.WithLabelValues("MangaDex")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues("MangaDex", ClassHTTP)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues("", ClassOther)))
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `manga_source_next_run_timestamp_seconds{connector="",name="One Piece",source="https://example.com/one-piece"}`)
+	assert.Contains(t, string(body), `manga_source_running{connector="",name="One Piece",source="https://example.com/one-piece"} 0`)
+	assert.Contains(t, string(body), "manga_ratelimit_wait_seconds_total")
+	assert.Contains(t, string(body), "go_goroutines")
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// schedulerCollector reports the state of every source when it is scraped.
+type schedulerCollector struct {
+	scheduler *scheduler.Scheduler
+
+	lastRun     *prometheus.Desc
+	lastSuccess *prometheus.Desc
+	nextRun     *prometheus.Desc
+	running     *prometheus.Desc
+}
+
+var _ prometheus.Collector = &schedulerCollector{}
+
+func newSchedulerCollector(s *scheduler.Scheduler) *schedulerCollector {
+	labels := []string{"source", "name", "connector"}
+	return &schedulerCollector{
+		scheduler: s,
+		lastRun: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "source", "last_run_timestamp_seconds"),
+			"When the source last ran.",
+			labels, nil,
+		),
+		lastSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "source", "last_success_timestamp_seconds"),
+			"When the source last ran without an error.",
+			labels, nil,
+		),
+		nextRun: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "source", "next_run_timestamp_seconds"),
+			"When the source is next scheduled to run.",
+			labels, nil,
+		),
+		running: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "source", "running"),
+			"1 if the source is downloading.",
+			labels, nil,
+		),
+	}
+}
+
+func (c *schedulerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.lastRun
+	ch <- c.lastSuccess
+	ch <- c.nextRun
+	ch <- c.running
+}
+
+func (c *schedulerCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range c.scheduler.Status() {
+		labels := []string{status.Source.URL, status.Source.Name, connector(status.Source)}
+		if t := status.State.LastRun; !t.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastRun, prometheus.GaugeValue, timestamp(t), labels...)
+		}
+		if t := status.State.LastSuccess; !t.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.lastSuccess, prometheus.GaugeValue, timestamp(t), labels...)
+		}
+		if t := status.State.NextRun; !t.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.nextRun, prometheus.GaugeValue, timestamp(t), labels...)
+		}
+		running := 0.0
+		if status.Running {
+			running = 1
+		}
+		ch <- prometheus.MustNewConstMetric(c.running, prometheus.GaugeValue, running, labels...)
+	}
+}
+
+func timestamp(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxItems limits how many books are listed individually in chat messages.
+const maxItems = 10
+
+// Webhook posts the notification as json.
+type Webhook struct {
+	target *Target
+}
+
+func (w *Webhook) Notify(ctx context.Context, n *Notification) error {
+	return postJSON(ctx, w.target, n)
+}
+
+// Ntfy publishes to an ntfy topic, target.URL is the topic url e.g.
+// https://ntfy.sh/manga.
+type Ntfy struct {
+	target *Target
+}
+
+func (nt *Ntfy) Notify(ctx context.Context, n *Notification) error {
+	headers := map[string]string{
+		"Title": mime.QEncoding.Encode("utf-8", n.Title()),
+		"Tags":  "books",
+	}
+	if n.Kind == KindFailing {
+		headers["Tags"] = "warning"
+		headers["Priority"] = "high"
+	}
+	if len(n.Books) > 0 {
+		if n.Books[0].ReadURL != "" {
+			headers["Click"] = n.Books[0].ReadURL
+		}
+		if len(n.Books) == 1 && n.Books[0].CoverURL != "" {
+			headers["Attach"] = n.Books[0].CoverURL
+		}
+	}
+	if nt.target.Token != "" {
+		headers["Authorization"] = "Bearer " + nt.target.Token
+	}
+	return send(ctx, nt.target, "text/plain; charset=utf-8", strings.NewReader(n.Message()), headers)
+}
+
+// Discord posts to a discord webhook with an embed per book.
+type Discord struct {
+	target *Target
+}
+
+type discordMessage struct {
+	Content string          `json:"content"`
+	Embeds  []*discordEmbed `json:"embeds,omitempty"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title,omitempty"`
+	URL         string         `json:"url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Thumbnail   *discordImage  `json:"thumbnail,omitempty"`
+	Footer      *discordFooter `json:"footer,omitempty"`
+}
+
+type discordImage struct {
+	URL string `json:"url"`
+}
+
+type discordFooter struct {
+	Text string `json:"text"`
+}
+
+func (d *Discord) Notify(ctx context.Context, n *Notification) error {
+	msg := &discordMessage{Content: n.Title()}
+	var footer *discordFooter
+	if n.Connector != "" {
+		footer = &discordFooter{Text: n.Connector}
+	}
+	for _, b := range truncate(n.Books) {
+		e := &discordEmbed{
+			Title:  b.Name,
+			URL:    b.ReadURL,
+			Footer: footer,
+		}
+		if b.Title != "" {
+			e.Description = b.Title
+		}
+		if b.CoverURL != "" {
+			e.Thumbnail = &discordImage{URL: b.CoverURL}
+		}
+		msg.Embeds = append(msg.Embeds, e)
+	}
+	if n.Kind == KindFailing {
+		msg.Embeds = append(msg.Embeds, &discordEmbed{
+			Description: n.Message(),
+			Color:       0xd9534f,
+			Footer:      footer,
+		})
+	}
+	if extra := len(n.Books) - maxItems; extra > 0 {
+		msg.Content += fmt.Sprintf(" (and %d more)", extra)
+	}
+	return postJSON(ctx, d.target, msg)
+}
+
+// Slack posts to a slack incoming webhook.
+type Slack struct {
+	target *Target
+}
+
+type slackMessage struct {
+	Text   string        `json:"text"`
+	Blocks []*slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type      string      `json:"type"`
+	Text      *slackText  `json:"text,omitempty"`
+	Accessory *slackImage `json:"accessory,omitempty"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackImage struct {
+	Type     string `json:"type"`
+	ImageURL string `json:"image_url"`
+	AltText  string `json:"alt_text"`
+}
+
+func (s *Slack) Notify(ctx context.Context, n *Notification) error {
+	msg := &slackMessage{
+		Text: n.Title(),
+		Blocks: []*slackBlock{
+			{Type: "header", Text: &slackText{Type: "plain_text", Text: n.Title()}},
+		},
+	}
+	for _, b := range truncate(n.Books) {
+		text := "*" + b.Label() + "*"
+		if b.ReadURL != "" {
+			text = "<" + b.ReadURL + "|" + b.Label() + ">"
+		}
+		block := &slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}}
+		if b.CoverURL != "" {
+			block.Accessory = &slackImage{Type: "image", ImageURL: b.CoverURL, AltText: b.Name}
+		}
+		msg.Blocks = append(msg.Blocks, block)
+	}
+	if n.Kind == KindFailing {
+		msg.Blocks = append(msg.Blocks, &slackBlock{Type: "section", Text: &slackText{Type: "plain_text", Text: n.Message()}})
+	}
+	return postJSON(ctx, s.target, msg)
+}
+
+func truncate(books []*Book) []*Book {
+	if len(books) > maxItems {
+		return books[:maxItems]
+	}
+	return books
+}
+
+func postJSON(ctx context.Context, t *Target, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return send(ctx, t, "application/json", bytes.NewReader(b), nil)
+}
+
+func send(ctx context.Context, t *Target, contentType string, body io.Reader, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s notification failed with status %s: %s", t.Type, resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
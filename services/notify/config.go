@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const defaultFailureThreshold = 3
+
+// Config is the notify section of the config file.
+type Config struct {
+	// BaseURL is the address the watch --listen server can be reached at,
+	// it is used to link to covers and the reader
+	BaseURL string `mapstructure:"base_url"`
+	// FailureThreshold is the number of runs in a row a source or book has
+	// to fail before an alert is sent
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	Targets          []*Target
+}
+
+// Target is a single place notifications are sent to.
+type Target struct {
+	// Type is one of webhook, ntfy, discord, slack or email
+	Type    string
+	URL     string
+	Token   string
+	Headers map[string]string
+
+	// email settings
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+var client = &http.Client{Timeout: 30 * time.Second}
+
+// Validate checks that every target can be created.
+func (c *Config) Validate() error {
+	errs := []error{}
+	if c.BaseURL != "" {
+		u, err := url.Parse(c.BaseURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("notify.base_url must be an absolute url, got %q", c.BaseURL))
+		}
+	}
+	if c.FailureThreshold < 0 {
+		errs = append(errs, fmt.Errorf("notify.failure_threshold must be positive"))
+	}
+	for i, t := range c.Targets {
+		_, err := NewNotifier(t)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notify.targets[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) failureThreshold() int {
+	if c.FailureThreshold == 0 {
+		return defaultFailureThreshold
+	}
+	return c.FailureThreshold
+}
+
+// NewNotifier creates the backend for a target.
+func NewNotifier(t *Target) (Notifier, error) {
+	if t == nil {
+		return nil, fmt.Errorf("empty target")
+	}
+	if t.Type == "email" {
+		if t.Host == "" || t.From == "" || len(t.To) == 0 {
+			return nil, fmt.Errorf("email targets need a host, from and to")
+		}
+		return &Email{target: t}, nil
+	}
+
+	if t.URL == "" {
+		return nil, fmt.Errorf("%s targets need a url", t.Type)
+	}
+	switch t.Type {
+	case "webhook":
+		return &Webhook{target: t}, nil
+	case "ntfy":
+		return &Ntfy{target: t}, nil
+	case "discord":
+		return &Discord{target: t}, nil
+	case "slack":
+		return &Slack{target: t}, nil
+	default:
+		return nil, fmt.Errorf("unknown notification type %q", t.Type)
+	}
+}
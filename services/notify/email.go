@@ -0,0 +1,224 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultSMTPPort = 587
+
+// Email sends an html email with the book covers attached inline.
+type Email struct {
+	target *Target
+}
+
+func (e *Email) Notify(ctx context.Context, n *Notification) error {
+	msg, err := e.message(n, time.Now())
+	if err != nil {
+		return err
+	}
+
+	port := e.target.Port
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	var auth smtp.Auth
+	if e.target.Username != "" {
+		auth = smtp.PlainAuth("", e.target.Username, e.target.Password, e.target.Host)
+	}
+	addr := net.JoinHostPort(e.target.Host, strconv.Itoa(port))
+	return sendMail(ctx, addr, auth, e.target.From, e.target.To, msg)
+}
+
+// sendMail is smtp.SendMail with the connection closed when ctx is done.
+func sendMail(ctx context.Context, addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = deliver(conn, host, auth, from, to, msg)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func deliver(conn net.Conn, host string, auth smtp.Auth, from string, to []string, msg []byte) error {
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: host})
+		if err != nil {
+			return err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return fmt.Errorf("smtp: server doesn't support AUTH")
+		}
+		err = c.Auth(auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(from)
+	if err != nil {
+		return err
+	}
+	for _, addr := range to {
+		err = c.Rcpt(addr)
+		if err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (e *Email) message(n *Notification, now time.Time) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "From: %s\r\n", e.target.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(e.target.To, ", "))
+	fmt.Fprintf(buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", n.Title()))
+	fmt.Fprintf(buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/related; boundary=%s\r\n\r\n", mw.Boundary())
+
+	covers := map[int][]byte{}
+	body := &strings.Builder{}
+	fmt.Fprintf(body, "<h2>%s</h2>\n", html.EscapeString(n.Title()))
+	for i, b := range truncate(n.Books) {
+		body.WriteString("<p>")
+		if thumb, err := thumbnail(b.file); err == nil {
+			covers[i] = thumb
+			fmt.Fprintf(body, `<img src="cid:cover-%d" alt="" style="max-height:200px"><br>`, i)
+		} else {
+			slog.Warn("Could not create thumbnail", "file", b.file, "err", err)
+		}
+		label := html.EscapeString(b.Label())
+		if b.ReadURL != "" {
+			label = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(b.ReadURL), label)
+		}
+		body.WriteString(label + "</p>\n")
+	}
+	if extra := len(n.Books) - maxItems; extra > 0 {
+		fmt.Fprintf(body, "<p>and %d more</p>\n", extra)
+	}
+	for _, f := range n.Failures {
+		name := f.Name
+		if name == "" {
+			name = n.Source
+		}
+		fmt.Fprintf(body, "<p>%s has failed %d runs in a row: %s</p>\n", html.EscapeString(name), f.Runs, html.EscapeString(f.Error))
+	}
+	if n.Connector != "" {
+		fmt.Fprintf(body, "<p><small>from %s</small></p>\n", html.EscapeString(n.Connector))
+	}
+
+	w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=utf-8"}})
+	if err != nil {
+		return nil, err
+	}
+	_, err = w.Write([]byte(body.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range len(n.Books) {
+		thumb, ok := covers[i]
+		if !ok {
+			continue
+		}
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/jpeg"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {fmt.Sprintf(`inline; filename="cover-%d.jpg"`, i)},
+			"Content-Id":                {fmt.Sprintf("<cover-%d>", i)},
+		})
+		if err != nil {
+			return nil, err
+		}
+		enc := base64.NewEncoder(base64.StdEncoding, &lineWriter{w: w})
+		_, err = enc.Write(thumb)
+		if err != nil {
+			return nil, err
+		}
+		err = enc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// lineWriter wraps base64 output at 76 characters as required by mime.
+type lineWriter struct {
+	w   io.Writer
+	col int
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(76-l.col, len(p))
+		_, err := l.w.Write(p[:n])
+		if err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+		if l.col == 76 {
+			_, err = l.w.Write([]byte("\r\n"))
+			if err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+	return written, nil
+}
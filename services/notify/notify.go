@@ -0,0 +1,98 @@
+// Package notify sends notifications when the watcher downloads new books or
+// a source keeps failing.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type Kind string
+
+const (
+	// KindNewBooks is sent after a run that downloaded books
+	KindNewBooks = Kind("new_books")
+	// KindFailing is sent once a source or book has failed
+	// failure_threshold runs in a row
+	KindFailing = Kind("failing")
+)
+
+// Notification is everything that happened to a source in one run. It is the
+// body of the generic webhook.
+type Notification struct {
+	Kind      Kind       `json:"kind"`
+	Source    string     `json:"source"`
+	SourceURL string     `json:"source_url"`
+	Connector string     `json:"connector,omitempty"`
+	Books     []*Book    `json:"books,omitempty"`
+	Failures  []*Failure `json:"failures,omitempty"`
+}
+
+type Book struct {
+	Series   string  `json:"series"`
+	Name     string  `json:"name"`
+	Title    string  `json:"title,omitempty"`
+	Chapter  float64 `json:"chapter,omitempty"`
+	Volume   int     `json:"volume,omitempty"`
+	CoverURL string  `json:"cover_url,omitempty"`
+	ReadURL  string  `json:"read_url,omitempty"`
+
+	// file is the path of the cbz, it is used to build thumbnails for
+	// backends that can't link to the cover
+	file string
+}
+
+type Failure struct {
+	// Name is the book that failed, it is empty when the whole source failed
+	Name  string `json:"name,omitempty"`
+	Error string `json:"error"`
+	Runs  int    `json:"runs"`
+}
+
+// Notifier is a backend notifications can be sent to.
+type Notifier interface {
+	Notify(ctx context.Context, n *Notification) error
+}
+
+// Title is a one line summary of the notification.
+func (n *Notification) Title() string {
+	switch n.Kind {
+	case KindNewBooks:
+		if len(n.Books) == 1 {
+			return "New chapter: " + n.Books[0].Name
+		}
+		return fmt.Sprintf("%d new chapters of %s", len(n.Books), n.Source)
+	case KindFailing:
+		return fmt.Sprintf("%s keeps failing", n.Source)
+	default:
+		return n.Source
+	}
+}
+
+// Message is the plain text body of the notification.
+func (n *Notification) Message() string {
+	lines := []string{}
+	for _, b := range n.Books {
+		lines = append(lines, b.Label())
+	}
+	for _, f := range n.Failures {
+		name := f.Name
+		if name == "" {
+			name = n.Source
+		}
+		lines = append(lines, fmt.Sprintf("%s has failed %d runs in a row: %s", name, f.Runs, f.Error))
+	}
+	if n.Connector != "" {
+		lines = append(lines, "from "+n.Connector)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Label is the book name with its title when it has one.
+func (b *Book) Label() string {
+	if b.Title != "" {
+		return b.Name + ": " + b.Title
+	}
+	return b.Name
+}
@@ -0,0 +1,368 @@
+package notify
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testBook struct {
+	id      string
+	chapter float64
+}
+
+func (b *testBook) Pages() ([]site.Page, error) { return nil, nil }
+func (b *testBook) ID() string                  { return b.id }
+func (b *testBook) Series() string              { return "One Piece" }
+func (b *testBook) SeriesID() string            { return "op" }
+func (b *testBook) Chapter() float64            { return b.chapter }
+func (b *testBook) Volume() int                 { return 0 }
+func (b *testBook) Info() *site.BookInfo        { return &site.BookInfo{} }
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// recorder is a stand-in for the notification services.
+type recorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*request
+}
+
+func newRecorder(t *testing.T) *recorder {
+	r := &recorder{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, &request{header: req.Header, body: body})
+		r.mu.Unlock()
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *recorder) notifications(t *testing.T) []*Notification {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	notifications := make([]*Notification, len(r.requests))
+	for i, req := range r.requests {
+		notifications[i] = &Notification{}
+		require.NoError(t, json.Unmarshal(req.body, notifications[i]))
+	}
+	r.requests = nil
+	return notifications
+}
+
+func newTestService(t *testing.T, cfg Config) *Service {
+	db, err := site.OpenDB(filepath.Join(t.TempDir(), "manga.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	s := &Service{
+		db: db,
+		links: Links{
+			Cover: func(series, book string) string { return "/covers/" + series + "/" + book },
+			Read:  func(series, book string) string { return "/read/" + series + "/" + book },
+		},
+		pending: map[string]*batch{},
+	}
+	require.NoError(t, s.SetConfig(cfg))
+	return s
+}
+
+func downloaded(src *site.Source, chapter float64) *site.Event {
+	name := fmt.Sprintf("One Piece #%g", chapter)
+	return &site.Event{
+		Type:   site.EventBookDownloaded,
+		Source: src,
+		Book:   &testBook{id: fmt.Sprint(chapter), chapter: chapter},
+		Name:   name,
+		File:   "/library/One Piece/" + name + ".cbz",
+		Info:   &site.BookInfo{Series: "One Piece", Title: fmt.Sprintf("Chapter %g", chapter)},
+	}
+}
+
+func TestFlush_batchesPerRun(t *testing.T) {
+	hook := newRecorder(t)
+	s := newTestService(t, Config{
+		BaseURL: "http://manga.local:8080/",
+		Targets: []*Target{{Type: "webhook", URL: hook.URL}},
+	})
+	src := &site.Source{URL: "https://mangaplus.shueisha.co.jp/titles/100020"}
+
+	s.onEvent(downloaded(src, 1))
+	s.onEvent(downloaded(src, 2))
+	assert.Len(t, hook.notifications(t), 0)
+
+	s.Flush(src, nil)
+	notifications := hook.notifications(t)
+	require.Len(t, notifications, 1)
+	n := notifications[0]
+	assert.Equal(t, KindNewBooks, n.Kind)
+	assert.Equal(t, "One Piece", n.Source)
+	require.Len(t, n.Books, 2)
+	assert.Equal(t, "One Piece #1", n.Books[0].Name)
+	assert.Equal(t, "Chapter 1", n.Books[0].Title)
+	assert.Equal(t, float64(1), n.Books[0].Chapter)
+	assert.Equal(t, "http://manga.local:8080/covers/One Piece/One Piece #1", n.Books[0].CoverURL)
+	assert.Equal(t, "http://manga.local:8080/read/One Piece/One Piece #1", n.Books[0].ReadURL)
+
+	// nothing new, nothing sent
+	s.Flush(src, nil)
+	assert.Len(t, hook.notifications(t), 0)
+}
+
+func TestFlush_bundled(t *testing.T) {
+	hook := newRecorder(t)
+	s := newTestService(t, Config{
+		BaseURL: "http://manga.local:8080",
+		Targets: []*Target{{Type: "webhook", URL: hook.URL}},
+	})
+	src := &site.Source{URL: "https://mangaplus.shueisha.co.jp/titles/100020"}
+
+	s.onEvent(downloaded(src, 1))
+	s.onEvent(downloaded(src, 2))
+	s.onEvent(downloaded(src, 3))
+	s.onEvent(&site.Event{
+		Type:    site.EventVolumeBundled,
+		Source:  src,
+		Name:    "One Piece Vol. 01",
+		File:    "/library/One Piece/One Piece Vol. 01.cbz",
+		Info:    &site.BookInfo{Series: "One Piece", Volume: 1},
+		Removed: []string{"/library/One Piece/One Piece #1.cbz", "/library/One Piece/One Piece #2.cbz"},
+	})
+	s.Flush(src, nil)
+
+	notifications := hook.notifications(t)
+	require.Len(t, notifications, 1)
+	books := notifications[0].Books
+	require.Len(t, books, 2)
+	assert.Equal(t, "One Piece #3", books[0].Name)
+	// the deleted chapters are replaced by the volume
+	assert.Equal(t, "One Piece Vol. 01", books[1].Name)
+	assert.Equal(t, 1, books[1].Volume)
+	assert.Equal(t, "http://manga.local:8080/read/One Piece/One Piece Vol. 01", books[1].ReadURL)
+}
+
+func TestFlush_optOut(t *testing.T) {
+	hook := newRecorder(t)
+	s := newTestService(t, Config{Targets: []*Target{{Type: "webhook", URL: hook.URL}}})
+	off := false
+	src := &site.Source{URL: "https://mangaplus.shueisha.co.jp/titles/100020", Notify: &off}
+
+	s.onEvent(downloaded(src, 1))
+	s.Flush(src, nil)
+	assert.Len(t, hook.notifications(t), 0)
+}
+
+func TestFlush_repeatedFailures(t *testing.T) {
+	hook := newRecorder(t)
+	s := newTestService(t, Config{
+		FailureThreshold: 2,
+		Targets:          []*Target{{Type: "webhook", URL: hook.URL}},
+	})
+	src := &site.Source{Name: "One Piece", URL: "https://mangaplus.shueisha.co.jp/titles/100020"}
+	runErr := errors.New("503 service unavailable")
+
+	s.Flush(src, runErr)
+	assert.Len(t, hook.notifications(t), 0)
+
+	s.Flush(src, runErr)
+	notifications := hook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, KindFailing, notifications[0].Kind)
+	assert.Equal(t, []*Failure{{Error: "503 service unavailable", Runs: 2}}, notifications[0].Failures)
+
+	// only alert once
+	s.Flush(src, runErr)
+	assert.Len(t, hook.notifications(t), 0)
+
+	// a good run resets the count
+	s.Flush(src, nil)
+	s.Flush(src, runErr)
+	assert.Len(t, hook.notifications(t), 0)
+	s.Flush(src, runErr)
+	assert.Len(t, hook.notifications(t), 1)
+
+	// book failures are counted separately
+	failed := &site.Event{
+		Type:   site.EventBookFailed,
+		Source: src,
+		Book:   &testBook{id: "1001"},
+		Name:   "One Piece #1001",
+		Err:    errors.New("page 3 missing"),
+	}
+	s.onEvent(failed)
+	s.Flush(src, nil)
+	s.onEvent(failed)
+	s.Flush(src, nil)
+	notifications = hook.notifications(t)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, []*Failure{{Name: "One Piece #1001", Error: "page 3 missing", Runs: 2}}, notifications[0].Failures)
+
+	// a good run that doesn't try the book again resets it too
+	other := &site.Event{
+		Type:   site.EventBookFailed,
+		Source: src,
+		Book:   &testBook{id: "1002"},
+		Name:   "One Piece #1002",
+		Err:    errors.New("page 1 missing"),
+	}
+	s.onEvent(other)
+	s.Flush(src, nil)
+	s.Flush(src, nil)
+	s.onEvent(other)
+	s.Flush(src, nil)
+	assert.Len(t, hook.notifications(t), 0)
+}
+
+func TestBackends(t *testing.T) {
+	n := &Notification{
+		Kind:      KindNewBooks,
+		Source:    "One Piece",
+		Connector: "mangaplus",
+		Books: []*Book{{
+			Series:   "One Piece",
+			Name:     "One Piece #1",
+			Title:    "Romance Dawn",
+			CoverURL: "http://manga.local/cover",
+			ReadURL:  "http://manga.local/read",
+		}},
+	}
+
+	t.Run("ntfy", func(t *testing.T) {
+		r := newRecorder(t)
+		err := (&Ntfy{target: &Target{Type: "ntfy", URL: r.URL, Token: "tk_1"}}).Notify(context.Background(), n)
+		require.NoError(t, err)
+		require.Len(t, r.requests, 1)
+		req := r.requests[0]
+		assert.Equal(t, "New chapter: One Piece #1", req.header.Get("Title"))
+		assert.Equal(t, "http://manga.local/cover", req.header.Get("Attach"))
+		assert.Equal(t, "http://manga.local/read", req.header.Get("Click"))
+		assert.Equal(t, "Bearer tk_1", req.header.Get("Authorization"))
+		assert.Equal(t, "One Piece #1: Romance Dawn\nfrom mangaplus", string(req.body))
+	})
+
+	t.Run("discord", func(t *testing.T) {
+		r := newRecorder(t)
+		err := (&Discord{target: &Target{Type: "discord", URL: r.URL}}).Notify(context.Background(), n)
+		require.NoError(t, err)
+		require.Len(t, r.requests, 1)
+		msg := &discordMessage{}
+		require.NoError(t, json.Unmarshal(r.requests[0].body, msg))
+		assert.Equal(t, "New chapter: One Piece #1", msg.Content)
+		require.Len(t, msg.Embeds, 1)
+		assert.Equal(t, "http://manga.local/cover", msg.Embeds[0].Thumbnail.URL)
+		assert.Equal(t, "mangaplus", msg.Embeds[0].Footer.Text)
+	})
+
+	t.Run("slack", func(t *testing.T) {
+		r := newRecorder(t)
+		err := (&Slack{target: &Target{Type: "slack", URL: r.URL}}).Notify(context.Background(), n)
+		require.NoError(t, err)
+		require.Len(t, r.requests, 1)
+		msg := &slackMessage{}
+		require.NoError(t, json.Unmarshal(r.requests[0].body, msg))
+		require.Len(t, msg.Blocks, 2)
+		assert.Equal(t, "<http://manga.local/read|One Piece #1: Romance Dawn>", msg.Blocks[1].Text.Text)
+		assert.Equal(t, "http://manga.local/cover", msg.Blocks[1].Accessory.ImageURL)
+	})
+
+	t.Run("error status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "bad token", http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+		err := (&Webhook{target: &Target{Type: "webhook", URL: srv.URL}}).Notify(context.Background(), n)
+		assert.ErrorContains(t, err, "bad token")
+	})
+}
+
+func TestEmailMessage(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "One Piece #1.cbz")
+	writeCBZ(t, file)
+
+	e := &Email{target: &Target{Type: "email", Host: "smtp.example.com", From: "manga@example.com", To: []string{"me@example.com"}}}
+	msg, err := e.message(&Notification{
+		Kind:   KindNewBooks,
+		Source: "One Piece",
+		Books:  []*Book{{Series: "One Piece", Name: "One Piece #1", file: file}},
+	}, time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	s := string(msg)
+	assert.Contains(t, s, "Subject: New chapter: One Piece #1\r\n")
+	assert.Contains(t, s, "To: me@example.com\r\n")
+	assert.Contains(t, s, `<img src="cid:cover-0"`)
+	assert.Contains(t, s, "Content-Id: <cover-0>")
+}
+
+func TestEmail_contextCancel(t *testing.T) {
+	// a server that accepts the connection and never sends its greeting
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	e := &Email{target: &Target{Type: "email", Host: "127.0.0.1", Port: port, From: "manga@example.com", To: []string{"me@example.com"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err = e.Notify(ctx, &Notification{Kind: KindNewBooks, Source: "One Piece"})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, (&Config{}).Validate())
+	assert.Error(t, (&Config{BaseURL: "manga.local"}).Validate())
+	assert.Error(t, (&Config{Targets: []*Target{{Type: "pager", URL: "http://example.com"}}}).Validate())
+	assert.Error(t, (&Config{Targets: []*Target{{Type: "ntfy"}}}).Validate())
+	assert.Error(t, (&Config{Targets: []*Target{{Type: "email", Host: "smtp.example.com"}}}).Validate())
+}
+
+func writeCBZ(t *testing.T, file string) {
+	img := image.NewGray(image.Rect(0, 0, 400, 600))
+	page := &bytes.Buffer{}
+	require.NoError(t, jpeg.Encode(page, img, nil))
+
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("/000.jpg")
+	require.NoError(t, err)
+	_, err = w.Write(page.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+}
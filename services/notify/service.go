@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"maps"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abibby/manga/site"
+)
+
+const sendTimeout = 30 * time.Second
+
+// Links builds the paths of a book's cover and reader page on the watch
+// --listen server, they are joined to notify.base_url. Books aren't linked
+// when they are nil.
+type Links struct {
+	Cover func(series, book string) string
+	Read  func(series, book string) string
+}
+
+// Service collects download events and sends them as one notification per
+// run.
+type Service struct {
+	db    *site.DB
+	links Links
+
+	mu        sync.Mutex
+	cfg       Config
+	notifiers []Notifier
+	pending   map[string]*batch
+}
+
+type batch struct {
+	books  []*Book
+	ids    []string
+	failed map[string]*Failure
+}
+
+// New creates the service and starts listening for download events.
+func New(db *site.DB, cfg Config, links Links) (*Service, error) {
+	s := &Service{
+		db:      db,
+		links:   links,
+		pending: map[string]*batch{},
+	}
+	err := s.SetConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	site.AddListener(s.onEvent)
+	return s, nil
+}
+
+// SetConfig replaces the notification targets.
+func (s *Service) SetConfig(cfg Config) error {
+	err := cfg.Validate()
+	if err != nil {
+		return err
+	}
+	notifiers := make([]Notifier, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		notifiers[i], err = NewNotifier(t)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	s.notifiers = notifiers
+	return nil
+}
+
+func (s *Service) onEvent(e *site.Event) {
+	if e.Type != site.EventBookDownloaded && e.Type != site.EventBookFailed && e.Type != site.EventVolumeBundled {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.pending[e.Source.URL]
+	if !ok {
+		b = &batch{failed: map[string]*Failure{}}
+		s.pending[e.Source.URL] = b
+	}
+
+	switch e.Type {
+	case site.EventBookDownloaded:
+		id := e.Book.ID()
+		b.books = append(b.books, s.book(e))
+		b.ids = append(b.ids, id)
+		delete(b.failed, id)
+	case site.EventBookFailed:
+		b.failed[e.Book.ID()] = &Failure{Name: e.Name, Error: e.Err.Error()}
+	case site.EventVolumeBundled:
+		// the volume is linked instead of the chapters it replaced
+		b.books = slices.DeleteFunc(b.books, func(book *Book) bool {
+			return slices.Contains(e.Removed, book.file)
+		})
+		b.books = append(b.books, s.book(e))
+	}
+}
+
+func (s *Service) book(e *site.Event) *Book {
+	series := filepath.Base(filepath.Dir(e.File))
+	name := strings.TrimSuffix(filepath.Base(e.File), ".cbz")
+	b := &Book{
+		Series: series,
+		Name:   e.Name,
+		file:   e.File,
+	}
+	if e.Book != nil {
+		b.Chapter = e.Book.Chapter()
+		b.Volume = e.Book.Volume()
+	}
+	if e.Info != nil {
+		b.Title = e.Info.Title
+		b.Volume = cmp.Or(b.Volume, e.Info.Volume)
+		if e.Info.Series != "" {
+			b.Series = e.Info.Series
+		}
+	}
+	if base := strings.TrimSuffix(s.cfg.BaseURL, "/"); base != "" {
+		if s.links.Cover != nil {
+			b.CoverURL = base + s.links.Cover(series, name)
+		}
+		if s.links.Read != nil {
+			b.ReadURL = base + s.links.Read(series, name)
+		}
+	}
+	return b
+}
+
+// Flush sends the notifications for a finished run of src, runErr is the
+// error the run returned.
+func (s *Service) Flush(src *site.Source, runErr error) {
+	s.mu.Lock()
+	b := s.pending[src.URL]
+	delete(s.pending, src.URL)
+	cfg := s.cfg
+	notifiers := s.notifiers
+	s.mu.Unlock()
+
+	if b == nil {
+		b = &batch{failed: map[string]*Failure{}}
+	}
+
+	n := &Notification{
+		Source:    sourceName(src, b),
+		SourceURL: src.URL,
+	}
+	if connector, ok := site.FindSite(src.URL); ok {
+		n.Connector = connector.SiteName()
+	}
+
+	failures := []*Failure{}
+	for id, f := range b.failed {
+		if s.addFailure(src.URL, id, f, cfg.failureThreshold()) {
+			failures = append(failures, f)
+		}
+	}
+	if runErr != nil {
+		for _, id := range b.ids {
+			s.clearFailure(src.URL, id)
+		}
+		f := &Failure{Error: runErr.Error()}
+		if s.addFailure(src.URL, "", f, cfg.failureThreshold()) {
+			failures = append(failures, f)
+		}
+	} else {
+		// a good run resets the source and every book that didn't fail in
+		// it, including books that weren't tried again
+		s.clearFailures(src.URL, slices.Collect(maps.Keys(b.failed)))
+	}
+
+	if len(b.books) > 0 && src.NotifyEnabled() {
+		books := *n
+		books.Kind = KindNewBooks
+		books.Books = b.books
+		s.send(notifiers, &books)
+	}
+	if len(failures) > 0 {
+		failing := *n
+		failing.Kind = KindFailing
+		failing.Failures = failures
+		s.send(notifiers, &failing)
+	}
+}
+
+// addFailure records the failure and returns true the first time it reaches
+// the threshold so the alert is only sent once.
+func (s *Service) addFailure(sourceURL, id string, f *Failure, threshold int) bool {
+	runs, err := s.db.AddFailure(sourceURL, id)
+	if err != nil {
+		slog.Warn("Failed to record failure", "url", sourceURL, "err", err)
+		return false
+	}
+	f.Runs = runs
+	return runs == threshold
+}
+
+func (s *Service) clearFailure(sourceURL, id string) {
+	err := s.db.ClearFailure(sourceURL, id)
+	if err != nil {
+		slog.Warn("Failed to clear failure", "url", sourceURL, "err", err)
+	}
+}
+
+func (s *Service) clearFailures(sourceURL string, keep []string) {
+	err := s.db.ClearFailures(sourceURL, keep)
+	if err != nil {
+		slog.Warn("Failed to clear failures", "url", sourceURL, "err", err)
+	}
+}
+
+func (s *Service) send(notifiers []Notifier, n *Notification) {
+	for _, notifier := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err := notifier.Notify(ctx, n)
+		cancel()
+		if err != nil {
+			slog.Error("Failed to send notification", "source", n.SourceURL, "err", err)
+		}
+	}
+}
+
+func sourceName(src *site.Source, b *batch) string {
+	if src.Name != "" {
+		return src.Name
+	}
+	if len(b.books) > 0 {
+		return b.books[0].Series
+	}
+	if u, err := url.Parse(src.URL); err == nil && u.Host != "" {
+		return u.Host + u.Path
+	}
+	return src.URL
+}
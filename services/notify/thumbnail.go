@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+
+	"github.com/abibby/manga/library"
+	"golang.org/x/image/draw"
+)
+
+const thumbnailHeight = 300
+
+// thumbnail creates a small jpeg of the cover of a cbz.
+func thumbnail(file string) ([]byte, error) {
+	a, err := library.OpenArchive(file)
+	if err != nil {
+		return nil, err
+	}
+	defer a.Close()
+
+	cover, err := a.Cover()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cover.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := src.Bounds()
+	var img image.Image = src
+	if sb.Dy() > thumbnailHeight {
+		w := sb.Dx() * thumbnailHeight / sb.Dy()
+		dest := image.NewRGBA(image.Rect(0, 0, max(w, 1), thumbnailHeight))
+		draw.ApproxBiLinear.Scale(dest, dest.Bounds(), src, sb, draw.Src, nil)
+		img = dest
+	}
+
+	buf := &bytes.Buffer{}
+	err = jpeg.Encode(buf, img, &jpeg.Options{Quality: 80})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule decides when a source should next be downloaded.
+type Schedule struct {
+	// Frequency runs the source every Frequency aligned to wall clock
+	// multiples, it is ignored if Cron is set.
+	Frequency time.Duration
+	// Cron is an optional cron style schedule.
+	Cron cron.Schedule
+	// Jitter adds a random delay of up to Jitter to every run.
+	Jitter time.Duration
+	// Quiet is an optional window where the source won't run.
+	Quiet *QuietHours
+}
+
+// NewSchedule builds a schedule from the config values, expr is a standard 5
+// field cron expression or a descriptor like @daily and quiet is a time range
+// like 23:00-07:00.
+func NewSchedule(frequency time.Duration, expr string, jitter time.Duration, quiet string) (*Schedule, error) {
+	s := &Schedule{
+		Frequency: frequency,
+		Jitter:    jitter,
+	}
+	if s.Frequency <= 0 {
+		s.Frequency = time.Hour
+	}
+	if jitter < 0 {
+		return nil, fmt.Errorf("jitter must not be negative")
+	}
+	if expr != "" {
+		c, err := cron.ParseStandard(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", expr, err)
+		}
+		s.Cron = c
+	}
+	if quiet != "" {
+		q, err := ParseQuietHours(quiet)
+		if err != nil {
+			return nil, err
+		}
+		s.Quiet = q
+	}
+	return s, nil
+}
+
+// Next returns the next time the source should run after t.
+func (s *Schedule) Next(t time.Time) time.Time {
+	var next time.Time
+	if s.Cron != nil {
+		next = s.Cron.Next(t)
+	} else {
+		next = t.Truncate(s.Frequency).Add(s.Frequency)
+	}
+	return s.Adjust(next.Add(s.jitter()))
+}
+
+// Start returns the time a source with no previous runs should run, it is
+// spread over the jitter window so sources don't all start at once.
+func (s *Schedule) Start(t time.Time) time.Time {
+	return s.Adjust(t.Add(s.jitter()))
+}
+
+// Adjust moves t out of the quiet hours.
+func (s *Schedule) Adjust(t time.Time) time.Time {
+	if s.Quiet == nil || !s.Quiet.Contains(t) {
+		return t
+	}
+	return s.Quiet.End(t).Add(s.jitter())
+}
+
+func (s *Schedule) jitter() time.Duration {
+	if s.Jitter <= 0 {
+		return 0
+	}
+	return rand.N(s.Jitter)
+}
+
+// QuietHours is a daily window in local time, it may wrap past midnight.
+type QuietHours struct {
+	// From and To are offsets from midnight
+	From time.Duration
+	To   time.Duration
+}
+
+// ParseQuietHours parses a range like 23:00-07:00.
+func ParseQuietHours(str string) (*QuietHours, error) {
+	startStr, endStr, ok := strings.Cut(str, "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid quiet hours %q: expected a range like 23:00-07:00", str)
+	}
+	start, err := parseClock(startStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q: %w", str, err)
+	}
+	end, err := parseClock(endStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid quiet hours %q: %w", str, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid quiet hours %q: start and end are the same", str)
+	}
+	return &QuietHours{From: start, To: end}, nil
+}
+
+func parseClock(str string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(str))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports if t falls inside the quiet hours.
+func (q *QuietHours) Contains(t time.Time) bool {
+	offset := sinceMidnight(t)
+	if q.From < q.To {
+		return offset >= q.From && offset < q.To
+	}
+	return offset >= q.From || offset < q.To
+}
+
+// End returns the end of the quiet period containing t.
+func (q *QuietHours) End(t time.Time) time.Time {
+	end := midnight(t).Add(q.To)
+	if !end.After(t) {
+		end = midnight(t.AddDate(0, 0, 1)).Add(q.To)
+	}
+	return end
+}
+
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return t.Sub(midnight(t))
+}
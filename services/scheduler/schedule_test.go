@@ -0,0 +1,66 @@
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abibby/manga/services/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(hour, min int) time.Time {
+	return time.Date(2024, time.March, 10, hour, min, 0, 0, time.UTC)
+}
+
+func TestSchedule_Next(t *testing.T) {
+	testCases := []struct {
+		name      string
+		frequency time.Duration
+		cron      string
+		quiet     string
+		now       time.Time
+		want      time.Time
+	}{
+		{"frequency aligns to the clock", time.Hour, "", "", date(10, 20), date(11, 0)},
+		{"frequency on the boundary", 30 * time.Minute, "", "", date(10, 30), date(11, 0)},
+		{"cron", time.Hour, "15 */6 * * *", "", date(10, 20), date(12, 15)},
+		{"cron descriptor", time.Hour, "@daily", "", date(10, 20), date(24, 0)},
+		{"quiet hours", time.Hour, "", "11:00-14:30", date(10, 20), date(14, 30)},
+		{"quiet hours over midnight", time.Hour, "", "23:00-07:00", date(22, 20), date(31, 0)},
+		{"outside quiet hours", time.Hour, "", "23:00-07:00", date(12, 20), date(13, 0)},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := scheduler.NewSchedule(tc.frequency, tc.cron, 0, tc.quiet)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, s.Next(tc.now))
+		})
+	}
+}
+
+func TestSchedule_jitter(t *testing.T) {
+	s, err := scheduler.NewSchedule(time.Hour, "", 10*time.Minute, "")
+	require.NoError(t, err)
+
+	for range 100 {
+		next := s.Next(date(10, 20))
+		assert.False(t, next.Before(date(11, 0)), next)
+		assert.True(t, next.Before(date(11, 10)), next)
+
+		start := s.Start(date(10, 20))
+		assert.False(t, start.Before(date(10, 20)), start)
+		assert.True(t, start.Before(date(10, 30)), start)
+	}
+}
+
+func TestNewSchedule_invalid(t *testing.T) {
+	_, err := scheduler.NewSchedule(time.Hour, "not a cron", 0, "")
+	assert.Error(t, err)
+
+	_, err = scheduler.NewSchedule(time.Hour, "", 0, "23:00")
+	assert.Error(t, err)
+
+	_, err = scheduler.NewSchedule(time.Hour, "", 0, "25:00-07:00")
+	assert.Error(t, err)
+}
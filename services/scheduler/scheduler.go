@@ -0,0 +1,357 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/abibby/manga/site"
+)
+
+// RunFunc downloads a single source.
+type RunFunc func(s *site.Source) error
+
+// Defaults are used for sources that don't set their own schedule.
+type Defaults struct {
+	Frequency  time.Duration
+	Jitter     time.Duration
+	QuietHours string
+}
+
+// SourceSchedule builds the schedule for a source falling back to the
+// defaults.
+func SourceSchedule(src *site.Source, defaults Defaults) (*Schedule, error) {
+	frequency := src.Frequency
+	if frequency == 0 {
+		frequency = defaults.Frequency
+	}
+	quiet := src.QuietHours
+	if quiet == "" {
+		quiet = defaults.QuietHours
+	}
+	return NewSchedule(frequency, src.Schedule, defaults.Jitter, quiet)
+}
+
+type entry struct {
+	source   *site.Source
+	schedule *Schedule
+	// settings identifies the schedule settings so a reload can tell if they
+	// have changed
+	settings string
+	state    *site.SourceState
+}
+
+// Scheduler runs each source on its own schedule, sources run one at a time.
+// The last and next run of every source is saved in the database so a restart
+// picks up where it left off.
+type Scheduler struct {
+	db  *site.DB
+	run RunFunc
+
+	mu       sync.Mutex
+	entries  []*entry
+	loaded   bool
+	running  string
+	progress *Progress
+	wake     chan struct{}
+	alive    bool
+}
+
+// SourceStatus is the schedule of a source.
+type SourceStatus struct {
+	Source  *site.Source
+	State   site.SourceState
+	Running bool
+}
+
+// Progress is the book currently being downloaded.
+type Progress struct {
+	Source string
+	Book   string
+	Page   int
+	Pages  int
+}
+
+func New(db *site.DB, run RunFunc) *Scheduler {
+	s := &Scheduler{
+		db:   db,
+		run:  run,
+		wake: make(chan struct{}, 1),
+	}
+	site.AddListener(s.onEvent)
+	return s
+}
+
+func (s *Scheduler) onEvent(e *site.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch e.Type {
+	case site.EventBookStarted:
+		s.progress = &Progress{Source: e.Source.URL, Book: e.Name}
+	case site.EventPageDownloaded:
+		s.progress = &Progress{Source: e.Source.URL, Book: e.Name, Page: e.Page + 1, Pages: e.Pages}
+	case site.EventBookDownloaded, site.EventBookFailed:
+		s.progress = nil
+	}
+}
+
+// Status returns every source in the order they will run.
+func (s *Scheduler) Status() []*SourceStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	statuses := make([]*SourceStatus, len(s.entries))
+	for i, e := range s.entries {
+		statuses[i] = &SourceStatus{
+			Source:  e.source,
+			State:   *e.state,
+			Running: e.source.URL == s.running,
+		}
+	}
+	slices.SortStableFunc(statuses, func(a, b *SourceStatus) int {
+		if a.Running != b.Running {
+			if a.Running {
+				return -1
+			}
+			return 1
+		}
+		return a.State.NextRun.Compare(b.State.NextRun)
+	})
+	return statuses
+}
+
+// Progress returns the book currently being downloaded or nil if nothing is
+// downloading.
+func (s *Scheduler) Progress() *Progress {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.progress == nil {
+		return nil
+	}
+	p := *s.progress
+	return &p
+}
+
+// SetSources replaces the scheduled sources. On the first call sources that
+// have never run, or missed their run while the watcher was stopped, are
+// started at a random point in the jitter window. On later calls sources that
+// weren't scheduled before run straight away and sources whose schedule has
+// changed are rescheduled.
+func (s *Scheduler) SetSources(sources []*site.Source, defaults Defaults) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	initial := !s.loaded
+	old := make(map[string]*entry, len(s.entries))
+	for _, e := range s.entries {
+		old[e.source.URL] = e
+	}
+	s.mu.Unlock()
+
+	entries := make([]*entry, 0, len(sources))
+	for _, src := range sources {
+		schedule, err := SourceSchedule(src, defaults)
+		if err != nil {
+			return fmt.Errorf("source %s: %w", src.URL, err)
+		}
+		e := &entry{
+			source:   src,
+			schedule: schedule,
+			settings: fmt.Sprint(src.Frequency, src.Schedule, src.QuietHours, defaults),
+		}
+
+		if prev, ok := old[src.URL]; ok {
+			state := *prev.state
+			if prev.settings != e.settings {
+				state.NextRun = schedule.Next(now)
+			}
+			e.state = &state
+		} else {
+			state, err := s.db.SourceState(src.URL)
+			if err != nil {
+				return err
+			}
+			if !initial {
+				state.NextRun = now
+			} else if state.NextRun.Before(now) {
+				state.NextRun = schedule.Start(now)
+			}
+			e.state = state
+		}
+		entries = append(entries, e)
+	}
+
+	s.mu.Lock()
+	s.entries = entries
+	s.loaded = true
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// TriggerAll queues every source to run as soon as possible.
+func (s *Scheduler) TriggerAll() {
+	s.mu.Lock()
+	now := time.Now()
+	for _, e := range s.entries {
+		state := *e.state
+		state.NextRun = now
+		e.state = &state
+	}
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// Trigger runs the source with the given url as soon as the current download
+// finishes.
+func (s *Scheduler) Trigger(url string) bool {
+	s.mu.Lock()
+	found := false
+	for _, e := range s.entries {
+		if e.source.URL == url {
+			state := *e.state
+			state.NextRun = time.Now()
+			e.state = &state
+			found = true
+		}
+	}
+	s.mu.Unlock()
+
+	if found {
+		s.notify()
+	}
+	return found
+}
+
+// Run runs sources as they come due until the context is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.setAlive(true)
+	defer s.setAlive(false)
+	for {
+		e, nextRun := s.nextDue()
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if e != nil {
+			wait := time.Until(nextRun)
+			if wait <= 0 {
+				s.runEntry(e)
+				continue
+			}
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *Scheduler) setAlive(alive bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alive = alive
+}
+
+// Alive returns true while Run is running.
+func (s *Scheduler) Alive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.alive
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) nextDue() (*entry, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *entry
+	for _, e := range s.entries {
+		if next == nil || e.state.NextRun.Before(next.state.NextRun) {
+			next = e
+		}
+	}
+	if next == nil {
+		return nil, time.Time{}
+	}
+	return next, next.state.NextRun
+}
+
+func (s *Scheduler) runEntry(e *entry) {
+	s.mu.Lock()
+	s.running = e.source.URL
+	lastSuccess := e.state.LastSuccess
+	s.mu.Unlock()
+
+	start := time.Now()
+	err := s.safeRun(e.source)
+
+	state := &site.SourceState{
+		LastRun:     start,
+		LastSuccess: start,
+		NextRun:     e.schedule.Next(time.Now()),
+	}
+	if err != nil {
+		state.LastError = err.Error()
+		state.LastSuccess = lastSuccess
+		slog.Error("Download failed", "url", e.source.URL, "err", err)
+	}
+
+	// the sources may have been reloaded while this one was running
+	s.mu.Lock()
+	s.running = ""
+	s.progress = nil
+	for _, current := range s.entries {
+		if current.source.URL == e.source.URL {
+			if current.settings != e.settings {
+				state.NextRun = current.schedule.Next(time.Now())
+			}
+			current.state = state
+		}
+	}
+	s.mu.Unlock()
+
+	err = s.db.SetSourceState(e.source.URL, state)
+	if err != nil {
+		slog.Error("Failed to save source state", "url", e.source.URL, "err", err)
+	}
+
+	slog.Info("Download complete",
+		"url", e.source.URL,
+		"duration", time.Since(start).Truncate(time.Millisecond),
+		"next", state.NextRun.Truncate(time.Second),
+	)
+}
+
+func (s *Scheduler) safeRun(src *site.Source) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		slog.Error("Download failed due to panic", "err", r, "stack", string(debug.Stack()))
+		err = fmt.Errorf("panic: %v", r)
+	}()
+	return s.run(src)
+}
@@ -0,0 +1,294 @@
+package site
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// BookRecord is a book that has been downloaded into the library.
+type BookRecord struct {
+	// File is the path of the cbz relative to the library dir
+	File         string    `json:"file"`
+	SeriesID     string    `json:"series_id"`
+	Series       string    `json:"series"`
+	BookID       string    `json:"book_id"`
+	Chapter      float64   `json:"chapter"`
+	Volume       int       `json:"volume,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Source       string    `json:"source"`
+	DownloadedAt time.Time `json:"downloaded_at"`
+	// Bundle is the volume cbz the chapter was bundled into, the chapter
+	// file may have been deleted
+	Bundle string `json:"bundle,omitempty"`
+}
+
+func (db *DB) AddBook(r *BookRecord) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b, err := bucket(tx, "books")
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(r.File), v)
+	})
+}
+
+// Book returns the record for a file relative to the library dir, it returns
+// nil if the file isn't in the database.
+func (db *DB) Book(file string) (*BookRecord, error) {
+	var r *BookRecord
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("books"))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(file))
+		if v == nil {
+			return nil
+		}
+		r = &BookRecord{}
+		return json.Unmarshal(v, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// AddBundle records a volume cbz and the chapter files that were bundled
+// into it.
+func (db *DB) AddBundle(r *BookRecord, chapters []string) error {
+	v, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b, err := bucket(tx, "books")
+		if err != nil {
+			return err
+		}
+		for _, file := range chapters {
+			cv := b.Get([]byte(file))
+			if cv == nil {
+				continue
+			}
+			chapter := &BookRecord{}
+			err = json.Unmarshal(cv, chapter)
+			if err != nil {
+				return err
+			}
+			chapter.Bundle = r.File
+			cv, err = json.Marshal(chapter)
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(file), cv)
+			if err != nil {
+				return err
+			}
+		}
+		return b.Put([]byte(r.File), v)
+	})
+}
+
+// MoveBook changes the file of a book record and the read progress of every
+// user in it after the file has been renamed.
+func (db *DB) MoveBook(oldFile, newFile string) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		return moveBook(tx, oldFile, newFile, "")
+	})
+}
+
+// ReplaceBook removes the record of a book that has been replaced by
+// newFile, read progress moves to the new file unless it already has some.
+func (db *DB) ReplaceBook(oldFile, newFile string) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte("books")); b != nil {
+			err := b.Delete([]byte(oldFile))
+			if err != nil {
+				return err
+			}
+		}
+
+		users := tx.Bucket([]byte("progress"))
+		if users == nil {
+			return nil
+		}
+		return users.ForEachBucket(func(user []byte) error {
+			b := users.Bucket(user)
+			v := b.Get([]byte(oldFile))
+			if v == nil {
+				return nil
+			}
+			if b.Get([]byte(newFile)) == nil {
+				err := b.Put([]byte(newFile), v)
+				if err != nil {
+					return err
+				}
+			}
+			return b.Delete([]byte(oldFile))
+		})
+	})
+}
+
+// MoveSeries saves name as the name of the series ids and moves the book
+// records and read progress of files, a map of old file to new file, into
+// the series. It is used after a series folder is renamed or merged into
+// another.
+func (db *DB) MoveSeries(ids []string, name string, files map[string]string) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		series, err := bucket(tx, "series")
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			err = series.Put([]byte(id), []byte(name))
+			if err != nil {
+				return err
+			}
+		}
+		for oldFile, newFile := range files {
+			err = moveBook(tx, oldFile, newFile, name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// moveBook moves a book record and read progress from oldFile to newFile, if
+// series isn't empty the series name of the record is changed too.
+func moveBook(tx *bbolt.Tx, oldFile, newFile, series string) error {
+	if b := tx.Bucket([]byte("books")); b != nil {
+		if v := b.Get([]byte(oldFile)); v != nil {
+			r := &BookRecord{}
+			err := json.Unmarshal(v, r)
+			if err != nil {
+				return err
+			}
+			r.File = newFile
+			if series != "" {
+				r.Series = series
+			}
+			v, err = json.Marshal(r)
+			if err != nil {
+				return err
+			}
+			err = b.Delete([]byte(oldFile))
+			if err != nil {
+				return err
+			}
+			err = b.Put([]byte(newFile), v)
+			if err != nil {
+				return err
+			}
+			err = moveBundle(b, oldFile, newFile)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	users := tx.Bucket([]byte("progress"))
+	if users == nil {
+		return nil
+	}
+	return users.ForEachBucket(func(user []byte) error {
+		b := users.Bucket(user)
+		v := b.Get([]byte(oldFile))
+		if v == nil {
+			return nil
+		}
+		err := b.Delete([]byte(oldFile))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(newFile), v)
+	})
+}
+
+// moveBundle points the chapters bundled into oldFile at newFile.
+func moveBundle(b *bbolt.Bucket, oldFile, newFile string) error {
+	chapters := map[string][]byte{}
+	err := b.ForEach(func(k, v []byte) error {
+		r := &BookRecord{}
+		err := json.Unmarshal(v, r)
+		if err != nil {
+			return err
+		}
+		if r.Bundle != oldFile {
+			return nil
+		}
+		r.Bundle = newFile
+		v, err = json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		chapters[string(k)] = v
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for k, v := range chapters {
+		err = b.Put([]byte(k), v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Books returns every downloaded book ordered by file name.
+func (db *DB) Books() ([]*BookRecord, error) {
+	return db.filterBooks(func(r *BookRecord) bool { return true })
+}
+
+// SourceBooks returns the downloaded books from a source keyed by book id.
+func (db *DB) SourceBooks(sourceURL string) (map[string]*BookRecord, error) {
+	records, err := db.filterBooks(func(r *BookRecord) bool { return r.Source == sourceURL })
+	if err != nil {
+		return nil, err
+	}
+	books := make(map[string]*BookRecord, len(records))
+	for _, r := range records {
+		books[r.BookID] = r
+	}
+	return books, nil
+}
+
+// SeriesBooks returns the downloaded books in a series.
+func (db *DB) SeriesBooks(seriesID string) ([]*BookRecord, error) {
+	return db.filterBooks(func(r *BookRecord) bool { return r.SeriesID == seriesID })
+}
+
+func (db *DB) filterBooks(cb func(r *BookRecord) bool) ([]*BookRecord, error) {
+	records := []*BookRecord{}
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("books"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			r := &BookRecord{}
+			err := json.Unmarshal(v, r)
+			if err != nil {
+				return err
+			}
+			if cb(r) {
+				records = append(records, r)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
@@ -0,0 +1,67 @@
+package site
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMoveBook(t *testing.T) {
+	db := openTestDB(t)
+	require.NoError(t, db.AddBook(&BookRecord{File: "S/old.cbz", BookID: "1"}))
+	require.NoError(t, db.SetReadProgress("adam", "S/old.cbz", &ReadProgress{Page: 3, Pages: 10}))
+
+	require.NoError(t, db.MoveBook("S/old.cbz", "S/new.cbz"))
+
+	old, err := db.Book("S/old.cbz")
+	require.NoError(t, err)
+	assert.Nil(t, old)
+	r, err := db.Book("S/new.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, r)
+	assert.Equal(t, "S/new.cbz", r.File)
+
+	p, err := db.ReadProgress("adam", "S/new.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, p)
+	assert.Equal(t, 3, p.Page)
+	p, err = db.ReadProgress("adam", "S/old.cbz")
+	require.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func TestMoveSeries(t *testing.T) {
+	db := openTestDB(t)
+	require.NoError(t, db.AddBook(&BookRecord{File: "OP/OP #1.cbz", SeriesID: "a", Series: "OP", BookID: "1"}))
+	require.NoError(t, db.AddBook(&BookRecord{File: "Other/Other #1.cbz", SeriesID: "b", Series: "Other", BookID: "2"}))
+
+	err := db.MoveSeries([]string{"a", "c"}, "One Piece", map[string]string{"OP/OP #1.cbz": "One Piece/One Piece #1.cbz"})
+	require.NoError(t, err)
+
+	series, err := db.AllSeries()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "One Piece", "c": "One Piece"}, series)
+
+	books, err := db.Books()
+	require.NoError(t, err)
+	require.Len(t, books, 2)
+	assert.Equal(t, "One Piece/One Piece #1.cbz", books[0].File)
+	assert.Equal(t, "One Piece", books[0].Series)
+	assert.Equal(t, "Other", books[1].Series)
+}
+
+func TestAddBundle(t *testing.T) {
+	db := openTestDB(t)
+	require.NoError(t, db.AddBook(&BookRecord{File: "S/S #1.cbz", BookID: "1", Chapter: 1}))
+	require.NoError(t, db.AddBundle(&BookRecord{File: "S/S Vol. 01.cbz", Volume: 1}, []string{"S/S #1.cbz"}))
+
+	r, err := db.Book("S/S #1.cbz")
+	require.NoError(t, err)
+	assert.Equal(t, "S/S Vol. 01.cbz", r.Bundle)
+
+	require.NoError(t, db.MoveBook("S/S Vol. 01.cbz", "S/S Vol. 1.cbz"))
+	r, err = db.Book("S/S #1.cbz")
+	require.NoError(t, err)
+	assert.Equal(t, "S/S Vol. 1.cbz", r.Bundle)
+}
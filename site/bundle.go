@@ -0,0 +1,527 @@
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abibby/manga/internal/cbz"
+)
+
+const (
+	BundleBundled     = "bundled"
+	BundleWouldBundle = "would bundle"
+	BundleIncomplete  = "incomplete"
+	BundleExists      = "exists"
+	BundleFailed      = "failed"
+)
+
+// VolumeBundle is a volume of a series and the chapter files that are
+// assembled into it.
+type VolumeBundle struct {
+	Series string `json:"series"`
+	Volume int    `json:"volume"`
+	// File is the path of the volume cbz
+	File string `json:"file"`
+	// Chapters are the paths of the chapter cbz files in reading order
+	Chapters []string `json:"chapters"`
+	// Missing are the chapters of the volume that haven't been downloaded
+	Missing []float64 `json:"missing,omitempty"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+
+	folder  string
+	records []*BookRecord
+}
+
+// Bundle assembles each volume of a source whose chapters have all been
+// downloaded into a "Series Vol. NN.cbz". Chapters are assigned to volumes
+// with VolumeMap or the volume the site gives them. With dryRun nothing is
+// written.
+func Bundle(db *DB, path string, s *Source, dryRun bool) ([]*VolumeBundle, error) {
+	var downloads []*sourceDownload
+	if s.IsSeries() {
+		var err error
+		downloads, err = newSeriesDownloads(db, path, s, currentNaming())
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		d, err := newSourceDownload(db, path, s, currentNaming())
+		if err != nil {
+			return nil, err
+		}
+		downloads = []*sourceDownload{d}
+	}
+	for _, d := range downloads {
+		d.dryRun = dryRun
+	}
+	return bundleVolumes(downloads, dryRun)
+}
+
+// bundleLogged bundles the volumes of a download run, errors are logged.
+func bundleLogged(downloads []*sourceDownload) {
+	bundles, err := bundleVolumes(downloads, false)
+	if err != nil {
+		slog.Warn("Could not bundle volumes", "url", downloads[0].eventSource().URL, "err", err)
+		return
+	}
+	for _, b := range bundles {
+		switch b.Status {
+		case BundleBundled:
+			slog.Info("Bundled volume", "file", b.File, "chapters", len(b.Chapters))
+		case BundleFailed:
+			slog.Warn("Could not bundle volume", "file", b.File, "err", b.Error)
+		}
+	}
+}
+
+type volumeKey struct {
+	folder string
+	volume int
+}
+
+func bundleVolumes(downloads []*sourceDownload, dryRun bool) ([]*VolumeBundle, error) {
+	src := downloads[0].eventSource()
+	volumeMap, err := src.volumeMap()
+	if err != nil {
+		return nil, err
+	}
+
+	// the chapters the sites list for each volume
+	expected := map[volumeKey]map[float64]bool{}
+	names := map[volumeKey]string{}
+	for _, d := range downloads {
+		books, err := d.siteBooks()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.source.URL, err)
+		}
+		for _, book := range books {
+			chapter := book.Chapter()
+			volume := chapterVolume(volumeMap, book)
+			if chapter == 0 || volume == 0 {
+				continue
+			}
+			k := volumeKey{folder: d.seriesFolder(book), volume: volume}
+			if expected[k] == nil {
+				expected[k] = map[float64]bool{}
+				names[k] = d.bookSeries(book)
+			}
+			expected[k][chapter] = true
+		}
+	}
+
+	records, err := downloads[0].db.Books()
+	if err != nil {
+		return nil, err
+	}
+	d := downloads[0]
+	bundles := []*VolumeBundle{}
+	for k, chapters := range expected {
+		fillVolume(chapters, volumeMap[k.volume])
+		name := names[k]
+		b := &VolumeBundle{
+			Series:   name,
+			Volume:   k.volume,
+			File:     filepath.Join(k.folder, fmt.Sprintf("%s Vol. %02d.cbz", name, k.volume)),
+			Chapters: []string{},
+			folder:   k.folder,
+		}
+		bundles = append(bundles, b)
+
+		for _, chapter := range slices.Sorted(maps.Keys(chapters)) {
+			r := d.chapterRecord(records, k.folder, chapter)
+			if r == nil {
+				b.Missing = append(b.Missing, chapter)
+				continue
+			}
+			b.records = append(b.records, r)
+			b.Chapters = append(b.Chapters, filepath.Join(d.path, filepath.FromSlash(r.File)))
+		}
+	}
+	slices.SortFunc(bundles, func(a, b *VolumeBundle) int {
+		return cmp.Or(strings.Compare(a.folder, b.folder), cmp.Compare(a.Volume, b.Volume))
+	})
+
+	for _, b := range bundles {
+		switch {
+		case fileExists(b.File) || slices.Contains(b.Chapters, b.File):
+			b.Status = BundleExists
+		case len(b.Missing) > 0:
+			b.Status = BundleIncomplete
+		case dryRun:
+			b.Status = BundleWouldBundle
+		default:
+			err = d.writeBundle(b, src)
+			if err != nil {
+				b.Status, b.Error = BundleFailed, err.Error()
+				continue
+			}
+			b.Status = BundleBundled
+		}
+	}
+	return bundles, nil
+}
+
+// chapterVolume returns the volume a book is in from the volume map, or from
+// the site if the map is empty.
+func chapterVolume(volumeMap map[int][]numberRange, book Book) int {
+	if len(volumeMap) == 0 {
+		return bookVolume(book)
+	}
+	for volume, ranges := range volumeMap {
+		if inRanges(ranges, book.Chapter()) {
+			return volume
+		}
+	}
+	return 0
+}
+
+// fillVolume adds the whole chapters between the first and last chapter of a
+// volume, and the ends of its ranges in the volume map, that the sites don't
+// list, so a volume isn't bundled when a site has lost some of its chapters.
+func fillVolume(chapters map[float64]bool, ranges []numberRange) {
+	first, last := math.Inf(1), math.Inf(-1)
+	for c := range chapters {
+		first = min(first, c)
+		last = max(last, c)
+	}
+	for _, r := range ranges {
+		first = min(first, r.from)
+		if !math.IsInf(r.to, 1) {
+			last = max(last, r.to)
+		}
+	}
+	for c := max(math.Ceil(first), 1); c <= last; c++ {
+		chapters[c] = true
+	}
+}
+
+// chapterRecord returns the record of a chapter in a series folder whose file
+// is on disk.
+func (d *sourceDownload) chapterRecord(records []*BookRecord, folder string, chapter float64) *BookRecord {
+	rel, err := filepath.Rel(d.path, folder)
+	if err != nil {
+		return nil
+	}
+	prefix := filepath.ToSlash(rel) + "/"
+	for _, r := range records {
+		if r.Chapter != chapter || !strings.HasPrefix(r.File, prefix) || strings.Contains(r.File[len(prefix):], "/") {
+			continue
+		}
+		if fileExists(filepath.Join(d.path, filepath.FromSlash(r.File))) {
+			return r
+		}
+	}
+	return nil
+}
+
+// writeBundle writes the volume cbz, records it and removes the chapter files
+// if the source asks for it. EventVolumeBundled is sent for the volume.
+func (d *sourceDownload) writeBundle(b *VolumeBundle, src *Source) error {
+	cover, _ := VolumeCover(b.folder, b.Volume)
+	info, err := writeVolume(b, cover)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(d.path, b.File)
+	if err != nil {
+		return err
+	}
+	chapters := make([]string, len(b.records))
+	for i, r := range b.records {
+		chapters[i] = r.File
+	}
+	err = d.db.AddBundle(&BookRecord{
+		File:         filepath.ToSlash(rel),
+		SeriesID:     b.records[0].SeriesID,
+		Series:       b.Series,
+		Volume:       b.Volume,
+		Source:       src.URL,
+		DownloadedAt: time.Now(),
+	}, chapters)
+	if err != nil {
+		return err
+	}
+
+	removed := []string{}
+	if src.BundleDelete {
+		for _, file := range b.Chapters {
+			err = os.Remove(file)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("Could not remove bundled chapter", "file", file, "err", err)
+				continue
+			}
+			removed = append(removed, file)
+		}
+	}
+
+	// hooks and notifications pick up the volume in place of the chapters
+	// that were removed
+	emit(&Event{
+		Type:    EventVolumeBundled,
+		Source:  src,
+		Name:    strings.TrimSuffix(filepath.Base(b.File), ".cbz"),
+		File:    b.File,
+		Info:    info,
+		Removed: removed,
+	})
+	return nil
+}
+
+// comicInfo is the ComicRack ComicInfo.xml written into volumes, bookmarks
+// mark the first page of each chapter.
+type comicInfo struct {
+	XMLName   xml.Name     `xml:"ComicInfo"`
+	Series    string       `xml:"Series"`
+	Volume    int          `xml:"Volume,omitempty"`
+	Summary   string       `xml:"Summary,omitempty"`
+	Year      int          `xml:"Year,omitempty"`
+	Month     int          `xml:"Month,omitempty"`
+	Day       int          `xml:"Day,omitempty"`
+	Writer    string       `xml:"Writer,omitempty"`
+	Genre     string       `xml:"Genre,omitempty"`
+	Tags      string       `xml:"Tags,omitempty"`
+	Web       string       `xml:"Web,omitempty"`
+	PageCount int          `xml:"PageCount"`
+	Manga     string       `xml:"Manga,omitempty"`
+	Pages     []*comicPage `xml:"Pages>Page"`
+}
+
+type comicPage struct {
+	Image       int    `xml:"Image,attr"`
+	Type        string `xml:"Type,attr,omitempty"`
+	DoublePage  bool   `xml:"DoublePage,attr,omitempty"`
+	ImageWidth  int    `xml:"ImageWidth,attr,omitempty"`
+	ImageHeight int    `xml:"ImageHeight,attr,omitempty"`
+	Bookmark    string `xml:"Bookmark,attr,omitempty"`
+}
+
+// writeVolume writes the pages of the chapters of a bundle into one cbz with
+// the volume cover, if there is one, as the first page. Pages are copied
+// without being recompressed. It returns the book.json of the volume.
+func writeVolume(b *VolumeBundle, cover string) (*BookInfo, error) {
+	info := &BookInfo{Series: b.Series, Volume: b.Volume, Pages: []*InfoPage{}}
+	err := cbz.WriteFile(b.File, 0644, func(zw *zip.Writer) error {
+		bookmarks := map[int]string{}
+
+		if cover != "" {
+			data, err := os.ReadFile(cover)
+			if err != nil {
+				return err
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				return fmt.Errorf("could not decode volume cover: %w", err)
+			}
+			err = cbz.WriteEntry(zw, pageName(0, cover), data)
+			if err != nil {
+				return err
+			}
+			info.Pages = append(info.Pages, &InfoPage{Type: PageTypeFrontCover, Width: cfg.Width, Height: cfg.Height})
+		}
+
+		for i, file := range b.Chapters {
+			chapter, err := copyChapter(zw, file, info)
+			if err != nil {
+				return fmt.Errorf("%s: %w", filepath.Base(file), err)
+			}
+			bookmarks[chapter.first] = chapterBookmark(b.records[i], chapter.info)
+			if i == 0 {
+				info.Summary = chapter.info.Summary
+				info.Web = chapter.info.Web
+				info.Genre = chapter.info.Genre
+				info.Tags = chapter.info.Tags
+				info.RightToLeft = chapter.info.RightToLeft
+				info.LongStrip = chapter.info.LongStrip
+			}
+			info.Author = cmp.Or(info.Author, chapter.info.Author)
+			if chapter.info.DateReleased.After(info.DateReleased) {
+				info.DateReleased = chapter.info.DateReleased
+			}
+		}
+		for i, p := range info.Pages {
+			if i > 0 && p.Type == PageTypeFrontCover {
+				p.Type = PageTypeStory
+			}
+		}
+		if len(info.Pages) > 0 {
+			info.Pages[0].Type = PageTypeFrontCover
+		}
+
+		data, err := json.MarshalIndent(info, "", "    ")
+		if err != nil {
+			return err
+		}
+		err = cbz.WriteEntry(zw, "book.json", data)
+		if err != nil {
+			return err
+		}
+		data, err = xml.MarshalIndent(volumeComicInfo(info, bookmarks), "", "  ")
+		if err != nil {
+			return err
+		}
+		return cbz.WriteEntry(zw, "ComicInfo.xml", append([]byte(xml.Header), data...))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+type copiedChapter struct {
+	info *BookInfo
+	// first is the index of the first page of the chapter in the volume
+	first int
+}
+
+// copyChapter copies the pages of a chapter cbz into the volume and appends
+// them to the volume pages.
+func copyChapter(zw *zip.Writer, file string, volume *BookInfo) (*copiedChapter, error) {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	c := &copiedChapter{info: &BookInfo{}, first: len(volume.Pages)}
+	pages := []*zip.File{}
+	for _, f := range zr.File {
+		name := strings.TrimPrefix(f.Name, "/")
+		if name == "book.json" {
+			err = readJSON(f, c.info)
+			if err != nil {
+				return nil, fmt.Errorf("invalid book.json: %w", err)
+			}
+		} else if !f.FileInfo().IsDir() && cbz.IsImage(name) {
+			pages = append(pages, f)
+		}
+	}
+	slices.SortFunc(pages, func(a, b *zip.File) int {
+		return strings.Compare(strings.TrimPrefix(a.Name, "/"), strings.TrimPrefix(b.Name, "/"))
+	})
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("no pages")
+	}
+
+	for i, f := range pages {
+		page := &InfoPage{Type: PageTypeStory}
+		if len(c.info.Pages) == len(pages) {
+			p := *c.info.Pages[i]
+			page = &p
+		}
+		if page.Width == 0 || page.Height == 0 {
+			cfg, err := decodeEntryConfig(f)
+			if err != nil {
+				return nil, err
+			}
+			page.Width, page.Height = cfg.Width, cfg.Height
+		}
+
+		fh := f.FileHeader
+		fh.Name = pageName(len(volume.Pages), f.Name)
+		w, err := zw.CreateRaw(&fh)
+		if err != nil {
+			return nil, err
+		}
+		r, err := f.OpenRaw()
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(w, r)
+		if err != nil {
+			return nil, err
+		}
+		volume.Pages = append(volume.Pages, page)
+	}
+	return c, nil
+}
+
+func pageName(i int, file string) string {
+	return fmt.Sprintf("%04d%s", i, strings.ToLower(filepath.Ext(file)))
+}
+
+func readJSON(f *zip.File, v any) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return json.NewDecoder(r).Decode(v)
+}
+
+func decodeEntryConfig(f *zip.File) (image.Config, error) {
+	r, err := f.Open()
+	if err != nil {
+		return image.Config{}, err
+	}
+	defer r.Close()
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return image.Config{}, fmt.Errorf("could not decode %s: %w", f.Name, err)
+	}
+	return cfg, nil
+}
+
+// chapterBookmark is the bookmark on the first page of a chapter, like
+// "Chapter 12: Title".
+func chapterBookmark(r *BookRecord, info *BookInfo) string {
+	bookmark := fmt.Sprintf("Chapter %g", r.Chapter)
+	if title := cmp.Or(info.Title, r.Title); title != "" {
+		bookmark += ": " + title
+	}
+	return bookmark
+}
+
+func volumeComicInfo(info *BookInfo, bookmarks map[int]string) *comicInfo {
+	ci := &comicInfo{
+		Series:    info.Series,
+		Volume:    info.Volume,
+		Summary:   info.Summary,
+		Writer:    info.Author,
+		Genre:     info.Genre,
+		Tags:      info.Tags,
+		Web:       info.Web,
+		PageCount: len(info.Pages),
+		Pages:     make([]*comicPage, len(info.Pages)),
+	}
+	if !info.DateReleased.IsZero() {
+		ci.Year, ci.Month, ci.Day = info.DateReleased.Year(), int(info.DateReleased.Month()), info.DateReleased.Day()
+	}
+	if info.RightToLeft {
+		ci.Manga = "YesAndRightToLeft"
+	}
+	for i, p := range info.Pages {
+		page := &comicPage{
+			Image:       i,
+			Type:        string(p.Type),
+			ImageWidth:  p.Width,
+			ImageHeight: p.Height,
+			Bookmark:    bookmarks[i],
+		}
+		// ComicInfo has no spread page types
+		switch p.Type {
+		case PageTypeSpread:
+			page.Type, page.DoublePage = string(PageTypeStory), true
+		case PageTypeSpreadSplit:
+			page.Type = string(PageTypeStory)
+		}
+		ci.Pages[i] = page
+	}
+	return ci
+}
@@ -0,0 +1,145 @@
+package site
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pngServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = png.Encode(w, image.NewGray(image.Rect(0, 0, 20, 30)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownload_bundle(t *testing.T) {
+	srv := pngServer(t)
+	page := testPage(srv.URL + "/0.png")
+	testConnector.books = []Book{
+		&testBook{id: "1", volume: 1, chapter: 1, pages: []Page{page}},
+		&testBook{id: "2", volume: 1, chapter: 2, pages: []Page{page, page}},
+		&testBook{id: "3", volume: 2, chapter: 3, pages: []Page{page}},
+	}
+	dir := t.TempDir()
+	db := openTestDB(t)
+	folder := filepath.Join(dir, "Test Series")
+	cover := filepath.Join(folder, ".covers", "volume-01.png")
+	require.NoError(t, os.MkdirAll(filepath.Dir(cover), 0755))
+	f, err := os.Create(cover)
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, image.NewGray(image.Rect(0, 0, 40, 60))))
+	require.NoError(t, f.Close())
+
+	// chapter 3 isn't downloaded so volume 2 isn't complete
+	src := &Source{URL: "https://test.example/series", Chapters: "1-2", Bundle: true, BundleDelete: true}
+	bundled := []*Event{}
+	AddListener(func(e *Event) {
+		if e.Source == src && e.Type == EventVolumeBundled {
+			bundled = append(bundled, e)
+		}
+	})
+	require.NoError(t, Download(db, dir, src))
+
+	file := filepath.Join(folder, "Test Series Vol. 01.cbz")
+	require.FileExists(t, file)
+	require.Len(t, bundled, 1)
+	assert.Equal(t, file, bundled[0].File)
+	assert.Equal(t, 1, bundled[0].Info.Volume)
+	assert.Equal(t, []string{
+		filepath.Join(folder, "Test Series V1 #1.cbz"),
+		filepath.Join(folder, "Test Series V1 #2.cbz"),
+	}, bundled[0].Removed)
+	assert.NoFileExists(t, filepath.Join(folder, "Test Series V1 #1.cbz"))
+	assert.NoFileExists(t, filepath.Join(folder, "Test Series V1 #2.cbz"))
+	assert.NoFileExists(t, filepath.Join(folder, "Test Series Vol. 02.cbz"))
+
+	zr, err := zip.OpenReader(file)
+	require.NoError(t, err)
+	defer zr.Close()
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"0000.png", "0001.png", "0002.png", "0003.png", "book.json", "ComicInfo.xml"}, names)
+
+	r, err := zr.Open("book.json")
+	require.NoError(t, err)
+	info := &BookInfo{}
+	require.NoError(t, json.NewDecoder(r).Decode(info))
+	assert.Equal(t, "Test Series", info.Series)
+	assert.Equal(t, 1, info.Volume)
+	assert.Equal(t, []*InfoPage{
+		{Type: PageTypeFrontCover, Width: 40, Height: 60},
+		{Type: PageTypeStory, Width: 20, Height: 30},
+		{Type: PageTypeStory, Width: 20, Height: 30},
+		{Type: PageTypeStory, Width: 20, Height: 30},
+	}, info.Pages)
+
+	r, err = zr.Open("ComicInfo.xml")
+	require.NoError(t, err)
+	ci := &comicInfo{}
+	require.NoError(t, xml.NewDecoder(r).Decode(ci))
+	require.Len(t, ci.Pages, 4)
+	assert.Equal(t, 4, ci.PageCount)
+	assert.Equal(t, "FrontCover", ci.Pages[0].Type)
+	assert.Equal(t, "Chapter 1", ci.Pages[1].Bookmark)
+	assert.Equal(t, "Chapter 2", ci.Pages[2].Bookmark)
+	assert.Equal(t, "", ci.Pages[3].Bookmark)
+
+	record, err := db.Book("Test Series/Test Series Vol. 01.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, 1, record.Volume)
+	chapter, err := db.Book("Test Series/Test Series V1 #1.cbz")
+	require.NoError(t, err)
+	assert.Equal(t, "Test Series/Test Series Vol. 01.cbz", chapter.Bundle)
+
+	// the deleted chapters aren't downloaded again
+	books, err := Plan(db, dir, src, nil)
+	require.NoError(t, err)
+	assert.Empty(t, books)
+}
+
+func TestBundle_volumeMap(t *testing.T) {
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1},
+		&testBook{id: "2", chapter: 2},
+		&testBook{id: "4", chapter: 4},
+	}
+	dir := t.TempDir()
+	db := openTestDB(t)
+	for _, ch := range []float64{1, 2, 4} {
+		file := fmt.Sprintf("Test Series/Test Series #%g.cbz", ch)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "Test Series"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, filepath.FromSlash(file)), []byte{}, 0644))
+		require.NoError(t, db.AddBook(&BookRecord{File: file, SeriesID: "test:series", Series: "Test Series", BookID: fmt.Sprint(ch), Chapter: ch}))
+	}
+
+	src := &Source{URL: "https://test.example/series", VolumeMap: map[int]Ranges{1: "1-2", 2: "3-4"}}
+	bundles, err := Bundle(db, dir, src, true)
+	require.NoError(t, err)
+
+	require.Len(t, bundles, 2)
+	assert.Equal(t, BundleWouldBundle, bundles[0].Status)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "Test Series", "Test Series #1.cbz"),
+		filepath.Join(dir, "Test Series", "Test Series #2.cbz"),
+	}, bundles[0].Chapters)
+	assert.Equal(t, BundleIncomplete, bundles[1].Status)
+	assert.Equal(t, []float64{3}, bundles[1].Missing)
+	assert.NoFileExists(t, filepath.Join(dir, "Test Series", "Test Series Vol. 01.cbz"))
+}
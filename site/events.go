@@ -0,0 +1,62 @@
+package site
+
+import "sync"
+
+type EventType string
+
+const (
+	EventBookStarted    = EventType("book_started")
+	EventPageDownloaded = EventType("page_downloaded")
+	EventBookDownloaded = EventType("book_downloaded")
+	EventBookFailed     = EventType("book_failed")
+	EventVolumeBundled  = EventType("volume_bundled")
+)
+
+// Event describes the progress of a download.
+type Event struct {
+	Type   EventType
+	Source *Source
+	// Connector is the name of the site the book is downloaded from, for a
+	// series it is the site of the source rather than the series url
+	Connector string
+	// Book is nil for EventVolumeBundled
+	Book Book
+	// Name is the display name of the book
+	Name string
+	// File is the path of the downloaded cbz, it is set for
+	// EventBookDownloaded and EventVolumeBundled
+	File string
+	// Info is set for EventBookDownloaded and EventVolumeBundled
+	Info *BookInfo
+	// Removed are the chapter files bundle_delete removed after they were
+	// bundled into the volume, it is set for EventVolumeBundled
+	Removed []string
+	// Page is the index of the page that was downloaded, Pages is the number
+	// of pages in the book
+	Page  int
+	Pages int
+	// Bytes is the size of the response body of the page
+	Bytes int
+	Err   error
+}
+
+var (
+	listenersMtx sync.RWMutex
+	listeners    []func(*Event)
+)
+
+// AddListener registers a function that is called for every download event.
+// Listeners are called synchronously and should return quickly.
+func AddListener(l func(*Event)) {
+	listenersMtx.Lock()
+	defer listenersMtx.Unlock()
+	listeners = append(listeners, l)
+}
+
+func emit(e *Event) {
+	listenersMtx.RLock()
+	defer listenersMtx.RUnlock()
+	for _, l := range listeners {
+		l(e)
+	}
+}
@@ -0,0 +1,73 @@
+package site
+
+import (
+	"bytes"
+	"encoding/binary"
+	"slices"
+
+	"go.etcd.io/bbolt"
+)
+
+// AddFailure counts a failed run for a book, an empty bookID counts a failure
+// of the whole source. It returns the number of runs in a row that have
+// failed.
+func (db *DB) AddFailure(sourceURL, bookID string) (int, error) {
+	count := 0
+	err := db.db.Update(func(tx *bbolt.Tx) error {
+		b, err := bucket(tx, "failures")
+		if err != nil {
+			return err
+		}
+		key := failureKey(sourceURL, bookID)
+		if v := b.Get(key); len(v) == 8 {
+			count = int(binary.BigEndian.Uint64(v))
+		}
+		count++
+		return b.Put(key, binary.BigEndian.AppendUint64(nil, uint64(count)))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ClearFailure resets the failure count after a successful run.
+func (db *DB) ClearFailure(sourceURL, bookID string) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("failures"))
+		if b == nil {
+			return nil
+		}
+		return b.Delete(failureKey(sourceURL, bookID))
+	})
+}
+
+// ClearFailures resets the failure counts of a source and all of its books
+// except the ones in keep.
+func (db *DB) ClearFailures(sourceURL string, keep []string) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("failures"))
+		if b == nil {
+			return nil
+		}
+		prefix := failureKey(sourceURL, "")
+		keys := [][]byte{}
+		c := b.Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			if !slices.Contains(keep, string(k[len(prefix):])) {
+				keys = append(keys, bytes.Clone(k))
+			}
+		}
+		for _, k := range keys {
+			err := b.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func failureKey(sourceURL, bookID string) []byte {
+	return []byte(sourceURL + "\x00" + bookID)
+}
@@ -0,0 +1,141 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LocalBook is a book found in the library that wasn't downloaded by manga,
+// or was downloaded before the database tracked books.
+type LocalBook struct {
+	// File is the path relative to the library dir
+	File string
+	// Folder is the series folder the book is in
+	Folder  string
+	Series  string
+	Volume  int
+	Chapter float64
+	Title   string
+	// BookID and Source are set when the book records what it was
+	// downloaded from
+	BookID  string
+	Source  string
+	ModTime time.Time
+}
+
+// ImportMatch is the book from a source that a LocalBook matched.
+type ImportMatch struct {
+	Local *LocalBook
+	// Record is nil if no book matched
+	Record *BookRecord
+	// Pinned is true if the source sets the series name, otherwise the
+	// folder should be saved as the series name so new chapters are
+	// downloaded next to the imported ones
+	Pinned bool
+	Err    error
+}
+
+// MatchBooks matches books in the library to books in the sources so they
+// can be recorded in the database. A book matches a source book with the same
+// id if it knows it, otherwise one in the same series with the same chapter
+// and volume. The series is matched by the source name, or by the name of the
+// series from the connector or database, against the series folder or the
+// series the book says it is in.
+func MatchBooks(db *DB, sources []*Source, books []*LocalBook) []*ImportMatch {
+	m := &matcher{db: db, books: newBookCache()}
+
+	matches := make([]*ImportMatch, len(books))
+	for i, local := range books {
+		candidates := sources
+		if local.Source != "" {
+			candidates = []*Source{findSourceURL(sources, local.Source)}
+		}
+		matches[i] = m.match(candidates, local)
+	}
+	return matches
+}
+
+func findSourceURL(sources []*Source, url string) *Source {
+	for _, s := range sources {
+		if s.URL == url {
+			return s
+		}
+	}
+	return &Source{URL: url}
+}
+
+type matcher struct {
+	db    *DB
+	books *bookCache
+}
+
+func (m *matcher) match(sources []*Source, local *LocalBook) *ImportMatch {
+	match := &ImportMatch{Local: local}
+	errs := []string{}
+	for _, s := range sources {
+		// a known book id is enough, the folder may have been renamed
+		if local.BookID == "" && s.Name != "" && !sameSeries(local, s.Name) {
+			continue
+		}
+		books, err := m.books.get(s.URL)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		for _, b := range books {
+			if !matchesBook(local, b) {
+				continue
+			}
+			if local.BookID == "" && s.Name == "" && !m.sameSeries(local, b) {
+				continue
+			}
+			match.Pinned = s.Name != ""
+			match.Record = &BookRecord{
+				File:         local.File,
+				SeriesID:     b.SeriesID(),
+				Series:       local.Folder,
+				BookID:       b.ID(),
+				Chapter:      b.Chapter(),
+				Volume:       b.Volume(),
+				Title:        local.Title,
+				Source:       s.URL,
+				DownloadedAt: local.ModTime,
+			}
+			return match
+		}
+	}
+	if len(errs) > 0 {
+		match.Err = fmt.Errorf("no matching book, some sources failed: %s", strings.Join(errs, ", "))
+	} else {
+		match.Err = fmt.Errorf("no matching book in the sources")
+	}
+	return match
+}
+
+func (m *matcher) sameSeries(local *LocalBook, b Book) bool {
+	if sameSeries(local, b.Series()) {
+		return true
+	}
+	name, err := m.db.PeekSeriesName(b)
+	return err == nil && sameSeries(local, name)
+}
+
+func sameSeries(local *LocalBook, name string) bool {
+	return strings.EqualFold(local.Folder, name) || strings.EqualFold(local.Series, name)
+}
+
+func matchesBook(local *LocalBook, b Book) bool {
+	if local.BookID != "" {
+		return b.ID() == local.BookID
+	}
+	if b.Chapter() != local.Chapter {
+		return false
+	}
+	volume := bookVolume(b)
+	if local.Chapter == 0 {
+		// books without a chapter number are only matched by volume
+		return local.Volume != 0 && volume == local.Volume
+	}
+	return local.Volume == 0 || volume == 0 || volume == local.Volume
+}
@@ -0,0 +1,70 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchBooks(t *testing.T) {
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1},
+		&testBook{id: "2", volume: 1, chapter: 2},
+		&testBook{id: "3", chapter: 3},
+	}
+	db := openTestDB(t)
+	src := &Source{URL: "https://test.example/series"}
+
+	matches := MatchBooks(db, []*Source{src}, []*LocalBook{
+		{File: "Test Series/c001.cbz", Folder: "Test Series", Chapter: 1},
+		{File: "Test Series/v1 c002.cbz", Folder: "Test Series", Volume: 1, Chapter: 2},
+		{File: "Other/c003.cbz", Folder: "Other", Series: "test series", Chapter: 3},
+		{File: "Test Series/c004.cbz", Folder: "Test Series", Chapter: 4},
+		{File: "Other/c001.cbz", Folder: "Other", Chapter: 1},
+		{File: "Renamed/x.cbz", Folder: "Renamed", BookID: "3", Source: src.URL},
+	})
+
+	ids := []string{}
+	for _, m := range matches {
+		if m.Err != nil {
+			ids = append(ids, "")
+			continue
+		}
+		ids = append(ids, m.Record.BookID)
+		assert.Equal(t, m.Local.File, m.Record.File)
+		assert.Equal(t, m.Local.Folder, m.Record.Series)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "", "", "3"}, ids)
+
+	pinned := MatchBooks(db, []*Source{{Name: "Pinned", URL: src.URL}}, []*LocalBook{
+		{File: "Pinned/c001.cbz", Folder: "Pinned", Chapter: 1},
+		{File: "Test Series/c001.cbz", Folder: "Test Series", Chapter: 1},
+	})
+	require.NoError(t, pinned[0].Err)
+	assert.True(t, pinned[0].Pinned)
+	assert.Error(t, pinned[1].Err)
+}
+
+func TestPlan_imported(t *testing.T) {
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1},
+		&testBook{id: "2", chapter: 2},
+	}
+	dir := t.TempDir()
+	db := openTestDB(t)
+	src := &Source{URL: "https://test.example/series"}
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Test Series"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Test Series", "ch 1.cbz"), []byte("cbz"), 0644))
+	require.NoError(t, db.AddBook(&BookRecord{File: "Test Series/ch 1.cbz", BookID: "1", Source: src.URL}))
+	// the file of this record is gone so it is downloaded again
+	require.NoError(t, db.AddBook(&BookRecord{File: "Test Series/ch 2.cbz", BookID: "2", Source: src.URL}))
+
+	books, err := Plan(db, dir, src, nil)
+	require.NoError(t, err)
+	require.Len(t, books, 1)
+	assert.Equal(t, "2", books[0].ID)
+}
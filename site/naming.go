@@ -0,0 +1,179 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// DefaultNamingTemplate names books like "One Piece V3 #21", books without a
+// volume or chapter use their id.
+const DefaultNamingTemplate = `{{.Series}}{{with .Volume}} V{{.}}{{end}}{{with .Chapter}} #{{.}}{{end}}{{if not (or .Volume .Chapter)}} {{.ID}}{{end}}`
+
+// NameData is passed to the naming template. It only has what is known
+// without loading the pages of the book since names are needed before
+// downloading.
+type NameData struct {
+	Series  string
+	Volume  int
+	Chapter float64
+	ID      string
+}
+
+// Naming builds the file names of books from a text/template
+type Naming struct {
+	text string
+	tmpl *template.Template
+}
+
+var namingFuncs = template.FuncMap{
+	// pad zero pads the whole part of a number, pad 3 10.5 is 010.5
+	"pad": func(width int, n any) (string, error) {
+		var f float64
+		switch n := n.(type) {
+		case int:
+			f = float64(n)
+		case float64:
+			f = n
+		default:
+			return "", fmt.Errorf("pad expects a number, got %T", n)
+		}
+		whole, frac := math.Modf(f)
+		s := fmt.Sprintf("%0*d", width, int(whole))
+		if frac != 0 {
+			s += strings.TrimPrefix(strconv.FormatFloat(math.Abs(frac), 'f', -1, 64), "0")
+		}
+		return s, nil
+	},
+}
+
+// ParseNaming parses a naming template, an empty string uses
+// DefaultNamingTemplate.
+func ParseNaming(text string) (*Naming, error) {
+	if text == "" {
+		text = DefaultNamingTemplate
+	}
+	tmpl, err := template.New("naming").Funcs(namingFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid naming template: %w", err)
+	}
+	n := &Naming{text: text, tmpl: tmpl}
+
+	// catch templates that parse but can't be used, like calling pad on the
+	// series
+	_, err = n.execute(&NameData{Series: "Series", Volume: 1, Chapter: 1.5, ID: "id"})
+	if err != nil {
+		return nil, fmt.Errorf("invalid naming template: %w", err)
+	}
+	return n, nil
+}
+
+// String returns the template text
+func (n *Naming) String() string {
+	return n.text
+}
+
+// Name returns the file name of the book without an extension
+func (n *Naming) Name(data *NameData) (string, error) {
+	name, err := n.execute(data)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("naming template produced an empty name for %s", data.ID)
+	}
+	return name, nil
+}
+
+// markers stand in for the values of a name when it is turned into a pattern
+const (
+	seriesMarker  = "\uE000"
+	idMarker      = "\uE001"
+	volumeMarker  = 104729
+	chapterMarker = 104723
+)
+
+// Matches returns true if name could have been made by the template for a
+// book in series. The volume, chapter and id can be anything.
+func (n *Naming) Matches(series, name string) bool {
+	for _, volume := range []int{0, volumeMarker} {
+		for _, chapter := range []float64{0, chapterMarker} {
+			s, err := n.execute(&NameData{Series: seriesMarker, Volume: volume, Chapter: chapter, ID: idMarker})
+			if err != nil {
+				continue
+			}
+			pattern := regexp.QuoteMeta(s)
+			pattern = strings.ReplaceAll(pattern, seriesMarker, regexp.QuoteMeta(series))
+			pattern = strings.ReplaceAll(pattern, idMarker, `.+`)
+			pattern = strings.ReplaceAll(pattern, strconv.Itoa(volumeMarker), `\d+`)
+			pattern = strings.ReplaceAll(pattern, strconv.Itoa(chapterMarker), `\d+(?:\.\d+)?`)
+			if ok, _ := regexp.MatchString("^"+pattern+"$", name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (n *Naming) execute(data *NameData) (string, error) {
+	b := &bytes.Buffer{}
+	err := n.tmpl.Execute(b, data)
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+var (
+	namingMtx     sync.RWMutex
+	defaultNaming = mustParseNaming(DefaultNamingTemplate)
+	naming        = defaultNaming
+)
+
+func mustParseNaming(text string) *Naming {
+	n, err := ParseNaming(text)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// SetNaming sets the naming template used by Download, nil resets it to the
+// default.
+func SetNaming(n *Naming) {
+	if n == nil {
+		n = defaultNaming
+	}
+	namingMtx.Lock()
+	defer namingMtx.Unlock()
+	naming = n
+}
+
+// CurrentNaming returns the naming template set with SetNaming.
+func CurrentNaming() *Naming {
+	return currentNaming()
+}
+
+func currentNaming() *Naming {
+	namingMtx.RLock()
+	defer namingMtx.RUnlock()
+	return naming
+}
+
+// bookName falls back to the default template so a book always gets a name,
+// ParseNaming makes failures here unlikely.
+func bookName(n *Naming, data *NameData) string {
+	name, err := n.Name(data)
+	if err == nil {
+		return name
+	}
+	slog.Warn("Naming template failed, using the default", "id", data.ID, "err", err)
+	name, _ = defaultNaming.Name(data)
+	return name
+}
@@ -0,0 +1,98 @@
+package site
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNaming_default(t *testing.T) {
+	n, err := ParseNaming("")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		data *NameData
+		want string
+	}{
+		{&NameData{Series: "One Piece", Chapter: 1}, "One Piece #1"},
+		{&NameData{Series: "One Piece", Volume: 3, Chapter: 21}, "One Piece V3 #21"},
+		{&NameData{Series: "One Piece", Chapter: 1000.5}, "One Piece #1000.5"},
+		{&NameData{Series: "One Piece", Volume: 2}, "One Piece V2"},
+		{&NameData{Series: "One Piece", ID: "abc"}, "One Piece abc"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.want, func(t *testing.T) {
+			name, err := n.Name(tc.data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, name)
+			assert.Equal(t, legacyName(tc.data), name)
+		})
+	}
+}
+
+func TestNaming_pad(t *testing.T) {
+	n, err := ParseNaming(`{{.Series}} - c{{pad 3 .Chapter}}{{with .Volume}} (v{{pad 2 .}}){{end}}`)
+	require.NoError(t, err)
+
+	name, err := n.Name(&NameData{Series: "One Piece", Volume: 3, Chapter: 21.5})
+	require.NoError(t, err)
+	assert.Equal(t, "One Piece - c021.5 (v03)", name)
+}
+
+func TestNaming_Matches(t *testing.T) {
+	def, err := ParseNaming("")
+	require.NoError(t, err)
+	pad, err := ParseNaming(`{{.Series}} - c{{pad 3 .Chapter}}{{with .Volume}} (v{{pad 2 .}}){{end}}`)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		naming *Naming
+		name   string
+		want   bool
+	}{
+		{def, "One Piece #1", true},
+		{def, "One Piece V3 #21", true},
+		{def, "One Piece #1000.5", true},
+		{def, "One Piece V2", true},
+		{def, "One Piece abc-123", true},
+		{def, "One Piece", false},
+		{def, "Chapter 2", false},
+		{def, "Naruto #1", false},
+		{pad, "One Piece - c021.5 (v03)", true},
+		{pad, "One Piece - c021", true},
+		{pad, "One Piece #21", false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.naming.Matches("One Piece", tc.name))
+		})
+	}
+}
+
+func TestParseNaming_invalid(t *testing.T) {
+	_, err := ParseNaming(`{{.Series`)
+	assert.Error(t, err)
+
+	_, err = ParseNaming(`{{.Missing}}`)
+	assert.Error(t, err)
+
+	_, err = ParseNaming(`{{pad 3 .Series}}`)
+	assert.Error(t, err)
+}
+
+// legacyName is how books were named before naming templates
+func legacyName(d *NameData) string {
+	name := d.Series
+	if d.Volume != 0 {
+		name += fmt.Sprintf(" V%d", d.Volume)
+	}
+	if d.Chapter != 0 {
+		name += fmt.Sprintf(" #%.6g", d.Chapter)
+	}
+	if d.Volume == 0 && d.Chapter == 0 {
+		name += " " + d.ID
+	}
+	return name
+}
@@ -0,0 +1,47 @@
+package site
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/abibby/manga/imaging"
+)
+
+// ProfileNone is the profile a source can use to keep its pages untouched
+// when a default profile is set.
+const ProfileNone = "none"
+
+var (
+	profilesMtx    sync.RWMutex
+	profiles       map[string]*imaging.Profile
+	defaultProfile string
+)
+
+// SetProfiles sets the image profiles sources can use with profile, def is
+// used by sources that don't pick one. Pages aren't changed when def is empty.
+func SetProfiles(p map[string]*imaging.Profile, def string) {
+	profilesMtx.Lock()
+	defer profilesMtx.Unlock()
+	profiles = p
+	defaultProfile = def
+}
+
+// sourceProfile returns the image profile a source's pages are processed
+// with, nil if they are saved as they are.
+func sourceProfile(s *Source) (*imaging.Profile, error) {
+	profilesMtx.RLock()
+	defer profilesMtx.RUnlock()
+	name := s.Profile
+	if name == "" {
+		name = defaultProfile
+	}
+	if name == "" || strings.EqualFold(name, ProfileNone) {
+		return nil, nil
+	}
+	p, ok := profiles[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %s", name)
+	}
+	return p, nil
+}
@@ -0,0 +1,78 @@
+package site
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"path/filepath"
+	"testing"
+
+	"github.com/abibby/manga/imaging"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sizedBook gives the page sizes like viz does
+type sizedBook struct {
+	*testBook
+}
+
+func (b *sizedBook) Info() *BookInfo {
+	info := b.testBook.Info()
+	info.Pages = []*InfoPage{{Type: PageTypeFrontCover, Width: 20, Height: 30}}
+	return info
+}
+
+func TestDownload_profile(t *testing.T) {
+	srv := pngServer(t)
+	SetProfiles(map[string]*imaging.Profile{
+		"small": {Height: 15, Convert: "jpeg"},
+	}, "small")
+	t.Cleanup(func() { SetProfiles(nil, "") })
+
+	testConnector.books = []Book{
+		&sizedBook{&testBook{id: "1", chapter: 1, pages: []Page{testPage(srv.URL + "/0.png")}}},
+		&testBook{id: "2", chapter: 2, pages: []Page{testPage(srv.URL + "/0.png")}},
+	}
+	dir := t.TempDir()
+	db := openTestDB(t)
+	require.NoError(t, Download(db, dir, &Source{URL: "https://test.example/series"}))
+
+	for _, name := range []string{"Test Series #1.cbz", "Test Series #2.cbz"} {
+		zr, err := zip.OpenReader(filepath.Join(dir, "Test Series", name))
+		require.NoError(t, err)
+		defer zr.Close()
+
+		r, err := zr.Open("000.jpeg")
+		require.NoError(t, err)
+		cfg, _, err := image.DecodeConfig(r)
+		require.NoError(t, err)
+		assert.Equal(t, 10, cfg.Width)
+		assert.Equal(t, 15, cfg.Height)
+
+		r, err = zr.Open("book.json")
+		require.NoError(t, err)
+		info := &BookInfo{}
+		require.NoError(t, json.NewDecoder(r).Decode(info))
+		assert.Equal(t, []*InfoPage{{Type: PageTypeFrontCover, Width: 10, Height: 15}}, info.Pages)
+	}
+
+	_, err := newSourceDownload(db, dir, &Source{URL: "https://test.example/series", Profile: "big"}, currentNaming())
+	assert.Error(t, err)
+	d, err := newSourceDownload(db, dir, &Source{URL: "https://test.example/series", Profile: "None"}, currentNaming())
+	require.NoError(t, err)
+	assert.Nil(t, d.profile)
+}
+
+func TestSaveImage_bytes(t *testing.T) {
+	srv := pngServer(t)
+	body := &bytes.Buffer{}
+	require.NoError(t, png.Encode(body, image.NewGray(image.Rect(0, 0, 20, 30))))
+
+	// the size is what was downloaded, not the processed page
+	_, size, err := saveImage(testPage(srv.URL+"/0.png"), filepath.Join(t.TempDir(), "000"), &imaging.Profile{Height: 15, Convert: "jpeg"})
+	require.NoError(t, err)
+	assert.Equal(t, body.Len(), size)
+}
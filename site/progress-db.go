@@ -0,0 +1,97 @@
+package site
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// ReadProgress is how far a user has read in a book.
+type ReadProgress struct {
+	// Page is the index of the last page read
+	Page      int       `json:"page"`
+	Pages     int       `json:"pages"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Finished returns true if the last page has been read.
+func (p *ReadProgress) Finished() bool {
+	return p.Pages > 0 && p.Page >= p.Pages-1
+}
+
+// ReadProgress returns the users progress in a book, file is the path of the
+// book relative to the library dir. It returns nil if the user hasn't opened
+// the book.
+func (db *DB) ReadProgress(user, file string) (*ReadProgress, error) {
+	var progress *ReadProgress
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := progressBucket(tx, user)
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(file))
+		if v == nil {
+			return nil
+		}
+		progress = &ReadProgress{}
+		return json.Unmarshal(v, progress)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// UserProgress returns the users progress in every book they have opened
+// keyed by file.
+func (db *DB) UserProgress(user string) (map[string]*ReadProgress, error) {
+	progress := map[string]*ReadProgress{}
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := progressBucket(tx, user)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			p := &ReadProgress{}
+			err := json.Unmarshal(v, p)
+			if err != nil {
+				return err
+			}
+			progress[string(k)] = p
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+func (db *DB) SetReadProgress(user, file string, progress *ReadProgress) error {
+	v, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		users, err := bucket(tx, "progress")
+		if err != nil {
+			return err
+		}
+		b, err := users.CreateBucketIfNotExists([]byte(user))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(file), v)
+	})
+}
+
+// progressBucket returns the bucket holding a users progress, each user gets
+// a nested bucket in "progress".
+func progressBucket(tx *bbolt.Tx, user string) *bbolt.Bucket {
+	users := tx.Bucket([]byte("progress"))
+	if users == nil {
+		return nil
+	}
+	return users.Bucket([]byte(user))
+}
@@ -0,0 +1,101 @@
+package site
+
+import (
+	"encoding/xml"
+	"fmt"
+	"slices"
+)
+
+// bookCache loads the books of each source once.
+type bookCache struct {
+	books map[string][]Book
+	errs  map[string]error
+}
+
+func newBookCache() *bookCache {
+	return &bookCache{books: map[string][]Book{}, errs: map[string]error{}}
+}
+
+func (c *bookCache) get(url string) ([]Book, error) {
+	if books, ok := c.books[url]; ok {
+		return books, c.errs[url]
+	}
+	site, ok := FindSite(url)
+	if !ok {
+		c.books[url], c.errs[url] = nil, fmt.Errorf("no site that matches %s", url)
+		return nil, c.errs[url]
+	}
+	books, err := site.Books(url)
+	c.books[url], c.errs[url] = books, err
+	return books, err
+}
+
+// Retagger fetches the current metadata of downloaded books.
+type Retagger struct {
+	db     *DB
+	path   string
+	naming *Naming
+	books  *bookCache
+}
+
+func NewRetagger(db *DB, path string, naming *Naming) *Retagger {
+	if naming == nil {
+		naming = currentNaming()
+	}
+	return &Retagger{db: db, path: path, naming: naming, books: newBookCache()}
+}
+
+// Retag fetches the metadata of a downloaded book from its connector. series
+// is the folder the book is in and old is the book.json in the archive, the
+// page sizes from it are kept since the images don't change. It returns the
+// new book.json and the path the book would be downloaded to with the
+// naming template.
+func (r *Retagger) Retag(sourceURL, bookID, series string, old *BookInfo) (*BookInfo, string, error) {
+	books, err := r.books.get(sourceURL)
+	if err != nil {
+		return nil, "", err
+	}
+	i := slices.IndexFunc(books, func(b Book) bool { return b.ID() == bookID })
+	if i == -1 {
+		return nil, "", fmt.Errorf("book %s not found in %s", bookID, sourceURL)
+	}
+	book := books[i]
+
+	info := book.Info()
+	if info == nil {
+		return nil, "", fmt.Errorf("connector returned no book info")
+	}
+	if old != nil && len(old.Pages) > 0 {
+		info.Pages = old.Pages
+	}
+	info.ID = book.ID()
+	info.Source = sourceURL
+	info.Series = series
+
+	d, err := newSourceDownload(r.db, r.path, &Source{URL: sourceURL, Name: series}, r.naming)
+	if err != nil {
+		return nil, "", err
+	}
+	return info, d.folder(book) + ".cbz", nil
+}
+
+// RetagComicInfo builds the ComicInfo.xml of a book from its new book.json,
+// old is the ComicInfo.xml in the archive and its bookmarks are kept.
+func RetagComicInfo(old []byte, info *BookInfo) ([]byte, error) {
+	ci := &comicInfo{}
+	err := xml.Unmarshal(old, ci)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ComicInfo.xml: %w", err)
+	}
+	bookmarks := map[int]string{}
+	for _, p := range ci.Pages {
+		if p.Bookmark != "" {
+			bookmarks[p.Image] = p.Bookmark
+		}
+	}
+	data, err := xml.MarshalIndent(volumeComicInfo(info, bookmarks), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
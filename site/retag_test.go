@@ -0,0 +1,57 @@
+package site
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetag(t *testing.T) {
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1, author: "Oda"},
+	}
+	dir := t.TempDir()
+	naming, err := ParseNaming(`{{.Series}} c{{pad 3 .Chapter}}`)
+	require.NoError(t, err)
+	r := NewRetagger(openTestDB(t), dir, naming)
+
+	old := &BookInfo{Series: "Old", Chapter: 1, Pages: []*InfoPage{{Type: PageTypeFrontCover, Width: 10, Height: 20}}}
+	info, file, err := r.Retag("https://test.example/series", "1", "My Series", old)
+	require.NoError(t, err)
+
+	assert.Equal(t, &BookInfo{
+		ID:      "1",
+		Source:  "https://test.example/series",
+		Series:  "My Series",
+		Chapter: 1,
+		Author:  "Oda",
+		Pages:   old.Pages,
+	}, info)
+	assert.Equal(t, filepath.Join(dir, "My Series", "My Series c001.cbz"), file)
+
+	_, _, err = r.Retag("https://test.example/series", "2", "My Series", old)
+	assert.ErrorContains(t, err, "book 2 not found")
+}
+
+func TestRetagComicInfo(t *testing.T) {
+	info := &BookInfo{Series: "Old", Volume: 1, Pages: []*InfoPage{{Type: PageTypeFrontCover}, {Type: PageTypeStory}}}
+	old, err := xml.Marshal(volumeComicInfo(info, map[int]string{0: "Chapter 1", 1: "Chapter 2"}))
+	require.NoError(t, err)
+
+	info.Series, info.Author = "New", "Oda"
+	data, err := RetagComicInfo(old, info)
+	require.NoError(t, err)
+
+	ci := &comicInfo{}
+	require.NoError(t, xml.Unmarshal(data, ci))
+	assert.Equal(t, "New", ci.Series)
+	assert.Equal(t, "Oda", ci.Writer)
+	assert.Equal(t, "Chapter 1", ci.Pages[0].Bookmark)
+	assert.Equal(t, "Chapter 2", ci.Pages[1].Bookmark)
+
+	_, err = RetagComicInfo([]byte("<ComicInfo>"), info)
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SearchResult is a series found by a Searcher
+type SearchResult struct {
+	Connector string `json:"connector"`
+	Title     string `json:"title"`
+	Author    string `json:"author,omitempty"`
+	Status    string `json:"status,omitempty"`
+	// URL can be used as the url of a source
+	URL string `json:"url"`
+}
+
+// Searcher can be implemented by a MangaSite that can look up series by title
+type Searcher interface {
+	Search(query string) ([]*SearchResult, error)
+}
+
+// Search queries every connector that implements Searcher, or only the named
+// connectors if any are passed. Results from the connectors that succeeded are
+// returned along with the errors of the ones that failed, the results are nil
+// if every connector failed. Naming a connector that doesn't exist or can't
+// search is an error.
+func Search(query string, connectors ...string) ([]*SearchResult, error) {
+	type response struct {
+		searched bool
+		results  []*SearchResult
+		err      error
+	}
+
+	for _, name := range connectors {
+		i := slices.IndexFunc(magnaSites, func(connector MangaSite) bool {
+			return strings.EqualFold(name, connector.SiteName())
+		})
+		if i == -1 {
+			return nil, fmt.Errorf("unknown connector %q, expected one of %s", name, strings.Join(ConnectorNames(), ", "))
+		}
+		if _, ok := magnaSites[i].(Searcher); !ok {
+			return nil, fmt.Errorf("%s doesn't support searching", magnaSites[i].SiteName())
+		}
+	}
+
+	responses := make([]response, len(magnaSites))
+	wg := sync.WaitGroup{}
+	for i, connector := range magnaSites {
+		searcher, ok := connector.(Searcher)
+		if !ok {
+			continue
+		}
+		if len(connectors) > 0 && !slices.ContainsFunc(connectors, func(name string) bool {
+			return strings.EqualFold(name, connector.SiteName())
+		}) {
+			continue
+		}
+		wg.Go(func() {
+			results, err := searcher.Search(query)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", connector.SiteName(), err)
+			}
+			for _, r := range results {
+				r.Connector = connector.SiteName()
+			}
+			responses[i] = response{searched: true, results: results, err: err}
+		})
+	}
+	wg.Wait()
+
+	results := []*SearchResult{}
+	errs := []error{}
+	searched := 0
+	for _, r := range responses {
+		if !r.searched {
+			continue
+		}
+		searched++
+		results = append(results, r.results...)
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	if searched > 0 && len(errs) == searched {
+		return nil, errors.Join(errs...)
+	}
+	return results, errors.Join(errs...)
+}
+
+// SearchMatch returns true if every word of the query is in the title. It is
+// used by connectors that can only list all of their series.
+func SearchMatch(title, query string) bool {
+	words := searchWords(title)
+	for _, q := range searchWords(query) {
+		found := false
+		for _, w := range words {
+			if strings.HasPrefix(w, q) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,90 @@
+package site
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type searchSite struct {
+	name    string
+	results []*SearchResult
+	err     error
+}
+
+func (s *searchSite) SiteName() string                 { return s.name }
+func (s *searchSite) Test(url string) bool             { return false }
+func (s *searchSite) Books(url string) ([]Book, error) { return nil, nil }
+func (s *searchSite) Search(query string) ([]*SearchResult, error) {
+	results := []*SearchResult{}
+	for _, r := range s.results {
+		if SearchMatch(r.Title, query) {
+			c := *r
+			results = append(results, &c)
+		}
+	}
+	return results, s.err
+}
+
+func init() {
+	RegisterMangaSite(&searchSite{name: "Searchable", results: []*SearchResult{
+		{Title: "One Piece", URL: "https://searchable.example/one-piece"},
+		{Title: "One-Punch Man", URL: "https://searchable.example/one-punch-man"},
+		{Title: "Sakamoto Days", URL: "https://searchable.example/sakamoto-days"},
+	}})
+	RegisterMangaSite(&searchSite{name: "Broken", err: errors.New("503 service unavailable")})
+}
+
+func TestSearchMatch(t *testing.T) {
+	testCases := []struct {
+		title string
+		query string
+		want  bool
+	}{
+		{"One Piece", "one piece", true},
+		{"One Piece", "piece", true},
+		{"One Piece", "pie", true},
+		{"One Piece", "iece", false},
+		{"One-Punch Man", "one punch", true},
+		{"One-Punch Man", "punch-man", true},
+		{"One Piece", "one punch", false},
+		{"Kaiju No. 8", "kaiju 8", true},
+		{"Spy×Family", "spy family", true},
+		{"One Piece", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.title+"/"+tc.query, func(t *testing.T) {
+			assert.Equal(t, tc.want, SearchMatch(tc.title, tc.query))
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	results, err := Search("one", "searchable")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "One Piece", results[0].Title)
+	assert.Equal(t, "Searchable", results[0].Connector)
+	assert.Equal(t, "One-Punch Man", results[1].Title)
+
+	// the results of the connectors that worked are kept
+	results, err = Search("sakamoto")
+	assert.ErrorContains(t, err, "Broken: 503 service unavailable")
+	require.Len(t, results, 1)
+	assert.Equal(t, "Sakamoto Days", results[0].Title)
+
+	// with no working connector there are no results to show
+	results, err = Search("sakamoto", "broken")
+	assert.ErrorContains(t, err, "Broken: 503 service unavailable")
+	assert.Nil(t, results)
+}
+
+func TestSearch_connectors(t *testing.T) {
+	_, err := Search("one", "nope")
+	assert.ErrorContains(t, err, `unknown connector "nope"`)
+
+	_, err = Search("one", "test")
+	assert.ErrorContains(t, err, "Test doesn't support searching")
+}
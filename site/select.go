@@ -0,0 +1,226 @@
+package site
+
+import (
+	"cmp"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Releaser can be implemented by a Book that knows when it was released
+// without loading its pages. Books that don't implement it use Info.
+type Releaser interface {
+	Released() time.Time
+}
+
+// VolumeHinter can be implemented by a Book that knows its volume but doesn't
+// return it from Volume so it stays out of the file name.
+type VolumeHinter interface {
+	VolumeHint() int
+}
+
+// Ranges is a comma separated list of numbers and inclusive ranges like
+// 1-10,15,20.5,30-. A range without an end has no upper limit.
+type Ranges string
+
+type numberRange struct {
+	from, to float64
+}
+
+func (r Ranges) parse() ([]numberRange, error) {
+	ranges := []numberRange{}
+	for part := range strings.SplitSeq(string(r), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseFloat(strings.TrimSpace(from), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid range %q", part)
+		}
+		end := start
+		if isRange {
+			end = math.Inf(1)
+			if to = strings.TrimSpace(to); to != "" {
+				end, err = strconv.ParseFloat(to, 64)
+				if err != nil || end < start {
+					return nil, fmt.Errorf("invalid range %q", part)
+				}
+			}
+		}
+		ranges = append(ranges, numberRange{from: start, to: end})
+	}
+	return ranges, nil
+}
+
+func inRanges(ranges []numberRange, n float64) bool {
+	for _, r := range ranges {
+		if n >= r.from && n <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+const dateLayout = "2006-01-02"
+
+func parseDate(field, date string) (time.Time, error) {
+	if date == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, date, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, must be a date like %s", field, date, dateLayout)
+	}
+	return t, nil
+}
+
+// selection is the parsed selection settings of a source
+type selection struct {
+	chapters []numberRange
+	volumes  []numberRange
+	after    time.Time
+	before   time.Time
+}
+
+func (s *Source) selection() (*selection, error) {
+	if s.To != 0 && s.To < s.From {
+		return nil, fmt.Errorf("to %g is before from %g", s.To, s.From)
+	}
+	if s.Latest < 0 {
+		return nil, fmt.Errorf("latest must be positive")
+	}
+	var err error
+	sel := &selection{}
+	sel.chapters, err = s.Chapters.parse()
+	if err != nil {
+		return nil, fmt.Errorf("chapters: %w", err)
+	}
+	sel.volumes, err = s.Volumes.parse()
+	if err != nil {
+		return nil, fmt.Errorf("volumes: %w", err)
+	}
+	sel.after, err = parseDate("released_after", s.ReleasedAfter)
+	if err != nil {
+		return nil, err
+	}
+	sel.before, err = parseDate("released_before", s.ReleasedBefore)
+	if err != nil {
+		return nil, err
+	}
+	return sel, nil
+}
+
+// ValidateSelection checks the settings that pick which books of the source
+// are downloaded and the volume map.
+func (s *Source) ValidateSelection() error {
+	_, err := s.selection()
+	if err != nil {
+		return err
+	}
+	_, err = s.volumeMap()
+	return err
+}
+
+// volumeMap parses the chapter ranges of each volume in VolumeMap.
+func (s *Source) volumeMap() (map[int][]numberRange, error) {
+	volumes := map[int][]numberRange{}
+	for volume, chapters := range s.VolumeMap {
+		if volume <= 0 {
+			return nil, fmt.Errorf("volume_map: invalid volume %d", volume)
+		}
+		ranges, err := chapters.parse()
+		if err != nil {
+			return nil, fmt.Errorf("volume_map %d: %w", volume, err)
+		}
+		volumes[volume] = ranges
+	}
+	return volumes, nil
+}
+
+// selectBooks returns the books that match the selection settings of the
+// source, in the same order.
+func (s *Source) selectBooks(books []Book) ([]Book, error) {
+	sel, err := s.selection()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := []Book{}
+	for _, book := range books {
+		reason := s.skipReason(book, sel)
+		if reason != "" {
+			slog.Debug("chapter not selected", "id", book.ID(), "chapter", book.Chapter(), "reason", reason)
+			continue
+		}
+		selected = append(selected, book)
+	}
+
+	if s.Latest > 0 && len(selected) > s.Latest {
+		newest := slices.Clone(selected)
+		slices.SortStableFunc(newest, func(a, b Book) int {
+			return cmp.Compare(b.Chapter(), a.Chapter())
+		})
+		keep := map[Book]bool{}
+		for _, book := range newest[:s.Latest] {
+			keep[book] = true
+		}
+		selected = slices.DeleteFunc(selected, func(b Book) bool {
+			return !keep[b]
+		})
+	}
+	return selected, nil
+}
+
+func (s *Source) skipReason(book Book, sel *selection) string {
+	chapter := book.Chapter()
+	if chapter < s.From {
+		return "before from"
+	}
+	if s.To != 0 && chapter > s.To {
+		return "after to"
+	}
+	if s.SkipExtras && (chapter == 0 || chapter != math.Trunc(chapter)) {
+		return "extra chapter"
+	}
+	if len(sel.chapters) > 0 && !inRanges(sel.chapters, chapter) {
+		return "not in chapters"
+	}
+	if len(sel.volumes) > 0 && !inRanges(sel.volumes, float64(bookVolume(book))) {
+		return "not in volumes"
+	}
+	if !sel.after.IsZero() || !sel.before.IsZero() {
+		// books without a release date are kept since they can't be
+		// compared
+		released := bookReleased(book)
+		if !released.IsZero() && !sel.after.IsZero() && released.Before(sel.after) {
+			return "released before released_after"
+		}
+		if !released.IsZero() && !sel.before.IsZero() && !released.Before(sel.before) {
+			return "released after released_before"
+		}
+	}
+	return ""
+}
+
+func bookVolume(book Book) int {
+	if book.Volume() != 0 {
+		return book.Volume()
+	}
+	if hinter, ok := book.(VolumeHinter); ok {
+		return hinter.VolumeHint()
+	}
+	return 0
+}
+
+func bookReleased(book Book) time.Time {
+	if releaser, ok := book.(Releaser); ok {
+		return releaser.Released()
+	}
+	return book.Info().DateReleased
+}
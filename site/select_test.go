@@ -0,0 +1,66 @@
+package site
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func chapters(books []Book) []float64 {
+	result := make([]float64, len(books))
+	for i, b := range books {
+		result[i] = b.Chapter()
+	}
+	return result
+}
+
+func TestSelectBooks(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, time.January, d, 12, 0, 0, 0, time.Local)
+	}
+	books := []Book{
+		&testBook{id: "1", volume: 1, chapter: 1, released: day(1)},
+		&testBook{id: "2", volume: 1, chapter: 2, released: day(8)},
+		&testBook{id: "2.5", volume: 1, chapter: 2.5, released: day(10)},
+		&testBook{id: "3", volume: 2, chapter: 3, released: day(15)},
+		&testBook{id: "4", volume: 2, chapter: 4, released: day(22)},
+		&testBook{id: "extra"},
+		&testBook{id: "5", chapter: 5},
+	}
+
+	testCases := []struct {
+		name   string
+		source *Source
+		want   []float64
+	}{
+		{"all", &Source{}, []float64{1, 2, 2.5, 3, 4, 0, 5}},
+		{"from", &Source{From: 3}, []float64{3, 4, 5}},
+		{"from to", &Source{From: 2, To: 3}, []float64{2, 2.5, 3}},
+		{"chapters", &Source{Chapters: "1, 3-4"}, []float64{1, 3, 4}},
+		{"open range", &Source{Chapters: "4-"}, []float64{4, 5}},
+		{"volumes", &Source{Volumes: "2"}, []float64{3, 4}},
+		{"skip extras", &Source{SkipExtras: true}, []float64{1, 2, 3, 4, 5}},
+		{"latest", &Source{Latest: 2}, []float64{4, 5}},
+		{"latest after filters", &Source{Latest: 2, To: 3}, []float64{2.5, 3}},
+		{"released after", &Source{ReleasedAfter: "2024-01-10"}, []float64{2.5, 3, 4, 0, 5}},
+		{"released before", &Source{ReleasedBefore: "2024-01-10"}, []float64{1, 2, 0, 5}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := tc.source.selectBooks(books)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, chapters(selected))
+		})
+	}
+}
+
+func TestSource_ValidateSelection(t *testing.T) {
+	assert.NoError(t, (&Source{Chapters: "1-10,15,20.5,30-", Volumes: "1"}).ValidateSelection())
+	assert.Error(t, (&Source{Chapters: "a-b"}).ValidateSelection())
+	assert.Error(t, (&Source{Volumes: "3-1"}).ValidateSelection())
+	assert.Error(t, (&Source{From: 10, To: 5}).ValidateSelection())
+	assert.Error(t, (&Source{Latest: -1}).ValidateSelection())
+	assert.Error(t, (&Source{ReleasedAfter: "01/02/2024"}).ValidateSelection())
+}
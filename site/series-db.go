@@ -1,15 +1,21 @@
 package site
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
 	"go.etcd.io/bbolt"
+	bberrors "go.etcd.io/bbolt/errors"
 )
 
 type DB struct {
 	db *bbolt.DB
+	// tmp is the empty database file opened in place of a missing one, it
+	// is removed on close
+	tmp string
 }
 
 func OpenDB(path string) (*DB, error) {
@@ -26,12 +32,72 @@ func OpenDB(path string) (*DB, error) {
 		FreelistType: bbolt.FreelistArrayType,
 	})
 	if err != nil {
+		return nil, lockedError(path, err)
+	}
+
+	return &DB{db: db}, nil
+}
+
+// OpenDBReadOnly opens the database for commands that only read from it.
+// Readers can share the database but have to wait for a writer like watch to
+// close it. A missing database isn't created, it is read as an empty one.
+func OpenDBReadOnly(path string) (*DB, error) {
+	_, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return openEmptyDB()
+	} else if err != nil {
 		return nil, err
 	}
 
+	db, err := bbolt.Open(path, 0666, &bbolt.Options{
+		Timeout:  time.Second * 2,
+		ReadOnly: true,
+	})
+	if err != nil {
+		return nil, lockedError(path, err)
+	}
+
 	return &DB{db: db}, nil
 }
 
+// openEmptyDB opens a new read only database in the temp dir.
+func openEmptyDB() (*DB, error) {
+	f, err := os.CreateTemp("", "manga-*.db")
+	if err != nil {
+		return nil, err
+	}
+	tmp := f.Name()
+	f.Close()
+
+	db, err := bbolt.Open(tmp, 0600, nil)
+	if err == nil {
+		err = db.Close()
+	}
+	if err == nil {
+		db, err = bbolt.Open(tmp, 0600, &bbolt.Options{ReadOnly: true})
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	return &DB{db: db, tmp: tmp}, nil
+}
+
+// lockedError explains the timeout bbolt returns when another process has the
+// database open for writing.
+func lockedError(path string, err error) error {
+	if IsLocked(err) {
+		return fmt.Errorf("%s is locked by another manga process, stop manga watch or use its --listen api: %w", path, err)
+	}
+	return err
+}
+
+// IsLocked returns true if the database couldn't be opened because another
+// process like watch has it open for writing.
+func IsLocked(err error) bool {
+	return errors.Is(err, bberrors.ErrTimeout)
+}
+
 func (db *DB) SeriesName(book Book) (string, error) {
 	series := ""
 	err := db.db.Update(func(tx *bbolt.Tx) error {
@@ -55,8 +121,70 @@ func (db *DB) SeriesName(book Book) (string, error) {
 	return series, nil
 }
 
+// PeekSeriesName returns the saved name of the series like SeriesName without
+// saving the name of new series.
+func (db *DB) PeekSeriesName(book Book) (string, error) {
+	series := book.Series()
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("series"))
+		if b == nil {
+			return nil
+		}
+		if s := b.Get([]byte(book.SeriesID())); s != nil {
+			series = string(s)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return series, nil
+}
+
+// InitSeriesName saves the name of a series unless it already has one.
+func (db *DB) InitSeriesName(seriesID, name string) error {
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b, err := bucket(tx, "series")
+		if err != nil {
+			return err
+		}
+		if b.Get([]byte(seriesID)) != nil {
+			return nil
+		}
+		return b.Put([]byte(seriesID), []byte(name))
+	})
+}
+
+// AllSeries returns the name of every series keyed by series id.
+func (db *DB) AllSeries() (map[string]string, error) {
+	series := map[string]string{}
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("series"))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			series[string(k)] = string(v)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return series, nil
+}
+
+// Check returns an error if the database can't be read.
+func (db *DB) Check() error {
+	return db.db.View(func(tx *bbolt.Tx) error { return nil })
+}
+
 func (db *DB) Close() error {
-	return db.db.Close()
+	err := db.db.Close()
+	if db.tmp != "" {
+		return errors.Join(err, os.Remove(db.tmp))
+	}
+	return err
 }
 
 func bucket(tx *bbolt.Tx, name string) (*bbolt.Bucket, error) {
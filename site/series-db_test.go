@@ -0,0 +1,40 @@
+package site
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bberrors "go.etcd.io/bbolt/errors"
+)
+
+func TestOpenDBReadOnly_missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "manga.db")
+
+	db, err := OpenDBReadOnly(path)
+	require.NoError(t, err)
+	books, err := db.Books()
+	require.NoError(t, err)
+	assert.Empty(t, books)
+	tmp := db.tmp
+	require.NoError(t, db.Close())
+
+	assert.NoFileExists(t, path)
+	assert.NoDirExists(t, filepath.Dir(path))
+	_, err = os.Stat(tmp)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpenDBReadOnly_locked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manga.db")
+	w, err := OpenDB(path)
+	require.NoError(t, err)
+	defer w.Close()
+
+	_, err = OpenDBReadOnly(path)
+	assert.ErrorIs(t, err, bberrors.ErrTimeout)
+	assert.True(t, IsLocked(err))
+	assert.ErrorContains(t, err, "stop manga watch")
+}
@@ -0,0 +1,166 @@
+package site
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abibby/manga/imaging"
+)
+
+const (
+	SeriesStatusOngoing   = "ongoing"
+	SeriesStatusCompleted = "completed"
+	SeriesStatusHiatus    = "hiatus"
+	SeriesStatusCancelled = "cancelled"
+)
+
+// SeriesInfo is the data that will be put into the series.json file in the
+// series folder
+type SeriesInfo struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// Status is one of the SeriesStatus constants or empty if the site
+	// doesn't say
+	Status string `json:"status,omitempty"`
+	Author string `json:"author,omitempty"`
+	Web    string `json:"web,omitempty"`
+
+	// Cover and Poster are image urls saved as cover.jpg and poster.jpg
+	Cover  string `json:"-"`
+	Poster string `json:"-"`
+	// VolumeCovers are the image urls of the covers of each volume
+	VolumeCovers map[int]string `json:"-"`
+}
+
+// SeriesInformer can be implemented by a Book that can describe the series it
+// belongs to.
+type SeriesInformer interface {
+	SeriesInfo() (*SeriesInfo, error)
+}
+
+// volumeCoverDir is the folder in a series folder volume covers are saved
+// in, it is hidden so library managers don't show them as books
+const volumeCoverDir = ".covers"
+
+// seriesArt tracks the series folders of a run, it is shared by the sites of
+// a series so only the highest priority site writes the art.
+type seriesArt struct {
+	// updated are the series folders books were downloaded into
+	updated map[string]bool
+	// saved are the series folders series.json has been written to
+	saved map[string]bool
+}
+
+func newSeriesArt() *seriesArt {
+	return &seriesArt{
+		updated: map[string]bool{},
+		saved:   map[string]bool{},
+	}
+}
+
+// saveSeriesInfo writes series.json and the series art into the folder of
+// each series the source has books in. It is only done when books were
+// downloaded into the folder or it has no series.json yet. Errors are logged,
+// missing art doesn't fail the download.
+func (d *sourceDownload) saveSeriesInfo(books []Book) {
+	for _, book := range books {
+		informer, ok := book.(SeriesInformer)
+		if !ok {
+			continue
+		}
+		folder := d.seriesFolder(book)
+		if d.art.saved[folder] || !fileExists(folder) {
+			continue
+		}
+		d.art.saved[folder] = true
+		if fileExists(filepath.Join(folder, "series.json")) && !d.art.updated[folder] {
+			continue
+		}
+
+		err := writeSeriesInfo(folder, d.bookSeries(book), informer, d.profile)
+		if err != nil {
+			slog.Warn("Could not save series info", "series", d.bookSeries(book), "err", err)
+		}
+	}
+}
+
+// writeSeriesInfo saves series.json and the art, volume covers are bundled
+// with the pages so they go through the profile.
+func writeSeriesInfo(folder, name string, informer SeriesInformer, profile *imaging.Profile) error {
+	info, err := informer.SeriesInfo()
+	if err != nil {
+		return err
+	}
+	// same as book.json, the name is the folder the series is saved in
+	info.Name = name
+	b, err := json.MarshalIndent(info, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(folder, "series.json"), b, 0644)
+	if err != nil {
+		return err
+	}
+
+	type art struct {
+		url     string
+		profile *imaging.Profile
+	}
+	errs := []error{}
+	images := map[string]art{
+		filepath.Join(folder, "cover"):  {url: info.Cover},
+		filepath.Join(folder, "poster"): {url: info.Poster},
+	}
+	for volume, url := range info.VolumeCovers {
+		images[filepath.Join(folder, volumeCoverDir, volumeCoverName(volume))] = art{url: url, profile: profile}
+	}
+	for base, img := range images {
+		if img.url == "" {
+			continue
+		}
+		if _, ok := findImage(base); ok {
+			continue
+		}
+		err = os.MkdirAll(filepath.Dir(base), 0775)
+		if err != nil {
+			return err
+		}
+		_, _, err = saveImage(DefaultPage(img.url), base, img.profile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(base), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func volumeCoverName(volume int) string {
+	return fmt.Sprintf("volume-%02d", volume)
+}
+
+// VolumeCover returns the path of the cover of a volume saved in a series
+// folder.
+func VolumeCover(seriesFolder string, volume int) (string, bool) {
+	return findImage(filepath.Join(seriesFolder, volumeCoverDir, volumeCoverName(volume)))
+}
+
+// findImage returns the image saved at base with any extension.
+func findImage(base string) (string, bool) {
+	files, err := os.ReadDir(filepath.Dir(base))
+	if err != nil {
+		return "", false
+	}
+	name := filepath.Base(base)
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if !f.IsDir() && ext != "" && strings.TrimSuffix(f.Name(), ext) == name {
+			return filepath.Join(filepath.Dir(base), f.Name()), true
+		}
+	}
+	return "", false
+}
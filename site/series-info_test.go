@@ -0,0 +1,77 @@
+package site
+
+import (
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type informerBook struct {
+	*testBook
+	info  *SeriesInfo
+	calls *int
+}
+
+func (b *informerBook) SeriesInfo() (*SeriesInfo, error) {
+	*b.calls++
+	return b.info, nil
+}
+
+func TestDownload_seriesInfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = png.Encode(w, image.NewGray(image.Rect(0, 0, 20, 30)))
+	}))
+	defer srv.Close()
+
+	calls := 0
+	info := &SeriesInfo{
+		ID:           "test:series",
+		Name:         "Test Series",
+		Description:  "A test",
+		Status:       SeriesStatusOngoing,
+		Cover:        srv.URL + "/cover.png",
+		VolumeCovers: map[int]string{1: srv.URL + "/v1.png"},
+	}
+	testConnector.books = []Book{
+		&informerBook{
+			testBook: &testBook{id: "1", volume: 1, chapter: 1, pages: []Page{testPage(srv.URL + "/0.png")}},
+			info:     info,
+			calls:    &calls,
+		},
+	}
+	dir := t.TempDir()
+	db := openTestDB(t)
+	src := &Source{Name: "Pinned", URL: "https://test.example/series"}
+
+	require.NoError(t, Download(db, dir, src))
+	assert.Equal(t, 1, calls)
+
+	folder := filepath.Join(dir, "Pinned")
+	b, err := os.ReadFile(filepath.Join(folder, "series.json"))
+	require.NoError(t, err)
+	saved := &SeriesInfo{}
+	require.NoError(t, json.Unmarshal(b, saved))
+	assert.Equal(t, "Pinned", saved.Name)
+	assert.Equal(t, "A test", saved.Description)
+	assert.Equal(t, SeriesStatusOngoing, saved.Status)
+	assert.FileExists(t, filepath.Join(folder, "cover.png"))
+	assert.NoFileExists(t, filepath.Join(folder, "poster.png"))
+
+	cover, ok := VolumeCover(folder, 1)
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(folder, ".covers", "volume-01.png"), cover)
+	_, ok = VolumeCover(folder, 2)
+	assert.False(t, ok)
+
+	// nothing new was downloaded and series.json exists
+	require.NoError(t, Download(db, dir, src))
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,219 @@
+package site
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// seriesBook is a book of a series and the site it is downloaded from
+type seriesBook struct {
+	d    *sourceDownload
+	book Book
+	// replaces is the book from a lower priority site the download replaces
+	replaces *BookRecord
+	// fallbacks are the same chapter from lower priority sites, tried in
+	// order when the download fails
+	fallbacks []*seriesBook
+}
+
+// newSeriesDownloads returns a download for each site of a series in
+// priority order. They all save into the folder of the series.
+func newSeriesDownloads(db *DB, path string, s *Source, naming *Naming) ([]*sourceDownload, error) {
+	if s.Name == "" {
+		return nil, fmt.Errorf("a series with several sources must have a name")
+	}
+	downloads := make([]*sourceDownload, len(s.Sources))
+	art := newSeriesArt()
+	for i, url := range s.Sources {
+		src := *s
+		src.URL = url
+		src.Sources = nil
+		d, err := newSourceDownload(db, path, &src, naming)
+		if err != nil {
+			return nil, err
+		}
+		d.series = s
+		d.art = art
+		downloads[i] = d
+	}
+	return downloads, nil
+}
+
+// seriesPending picks the site each chapter of a series is downloaded from,
+// the first site that has a chapter wins and the others are its fallbacks.
+// Chapters that have been downloaded
+// from any site are skipped unless upgrade is set and a higher priority site
+// has them. Sites that can't be loaded are skipped and their errors returned.
+func seriesPending(downloads []*sourceDownload, upgrade bool) ([]*seriesBook, []error) {
+	type have struct {
+		priority int
+		record   *BookRecord
+	}
+	// the chapters with a file on disk and the best site they came from
+	downloaded := map[float64]have{}
+	records := make([]map[string]*BookRecord, len(downloads))
+	for i, d := range downloads {
+		recs, err := d.db.SourceBooks(d.source.URL)
+		if err != nil {
+			return nil, []error{err}
+		}
+		records[i] = recs
+		for _, r := range recs {
+			if r.Chapter == 0 || !d.recordExists(r) {
+				continue
+			}
+			if h, ok := downloaded[r.Chapter]; !ok || i < h.priority {
+				downloaded[r.Chapter] = have{priority: i, record: r}
+			}
+		}
+	}
+
+	errs := []error{}
+	// the book each chapter is downloaded from, nil if it isn't downloaded
+	// or can't fall back to another site
+	chosen := map[float64]*seriesBook{}
+	pending := []*seriesBook{}
+	for i, d := range downloads {
+		books, err := d.available()
+		if err != nil {
+			slog.Warn("Could not load series source", "series", d.source.Name, "url", d.source.URL, "err", err)
+			errs = append(errs, fmt.Errorf("%s: %w", d.source.URL, err))
+			continue
+		}
+		for _, book := range books {
+			chapter := book.Chapter()
+			if chapter == 0 && i > 0 {
+				// books without a chapter number can't be matched
+				// across sites
+				continue
+			}
+			if chapter != 0 {
+				if b, ok := chosen[chapter]; ok {
+					if b != nil && !fileExists(d.folder(book)+".cbz") {
+						b.fallbacks = append(b.fallbacks, &seriesBook{d: d, book: book})
+					}
+					continue
+				}
+				chosen[chapter] = nil
+			}
+			if _, ok := d.recorded(records[i], book); ok {
+				continue
+			}
+			if h, ok := downloaded[chapter]; ok && chapter != 0 {
+				if upgrade && i < h.priority {
+					pending = append(pending, &seriesBook{d: d, book: book, replaces: h.record})
+				}
+				continue
+			}
+			if fileExists(d.folder(book) + ".cbz") {
+				continue
+			}
+			b := &seriesBook{d: d, book: book}
+			pending = append(pending, b)
+			if chapter != 0 {
+				chosen[chapter] = b
+			}
+		}
+	}
+	slices.SortStableFunc(pending, func(a, b *seriesBook) int {
+		return cmp.Compare(a.book.Chapter(), b.book.Chapter())
+	})
+	return pending, errs
+}
+
+func downloadSeries(db *DB, path string, s *Source, naming *Naming) error {
+	downloads, err := newSeriesDownloads(db, path, s, naming)
+	if err != nil {
+		return err
+	}
+	pending, errs := seriesPending(downloads, s.Upgrade)
+	if len(errs) > 0 && len(errs) >= len(downloads) {
+		return errors.Join(errs...)
+	}
+
+	for _, b := range pending {
+		if b.replaces == nil {
+			b.download()
+			continue
+		}
+		slog.Info("Upgrading book", "name", b.d.name(b.book), "from", b.replaces.Source, "to", b.d.source.URL)
+		err := b.d.downloadLogged(b.book, true)
+		if err != nil {
+			continue
+		}
+		err = b.d.removeReplaced(b.book, b.replaces)
+		if err != nil {
+			slog.Warn("Could not remove the replaced book", "file", b.replaces.File, "err", err)
+		}
+	}
+	for _, d := range downloads {
+		d.saveSeriesInfo(d.books)
+	}
+	if s.Bundle && len(errs) == 0 {
+		bundleLogged(downloads)
+	}
+	// the run still fails when a site is broken so it is retried and
+	// alerted on
+	return errors.Join(errs...)
+}
+
+// download downloads a new book, falling back to the next site that has the
+// chapter when it fails.
+func (b *seriesBook) download() {
+	err := b.d.downloadLogged(b.book, false)
+	from := b
+	for _, f := range b.fallbacks {
+		if err == nil {
+			return
+		}
+		slog.Info("Falling back to the next source", "name", f.d.name(f.book), "from", from.d.source.URL, "to", f.d.source.URL)
+		err = f.d.downloadLogged(f.book, false)
+		from = f
+	}
+}
+
+// removeReplaced deletes a book that was replaced by one from a higher
+// priority site, unless the new book was saved over it.
+func (d *sourceDownload) removeReplaced(book Book, old *BookRecord) error {
+	rel, err := filepath.Rel(d.path, d.folder(book)+".cbz")
+	if err != nil {
+		return err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == old.File {
+		return nil
+	}
+	err = os.Remove(filepath.Join(d.path, filepath.FromSlash(old.File)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return d.db.ReplaceBook(old.File, rel)
+}
+
+func planSeries(db *DB, path string, s *Source, naming *Naming) ([]*PlannedBook, error) {
+	downloads, err := newSeriesDownloads(db, path, s, naming)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range downloads {
+		d.dryRun = true
+	}
+	pending, errs := seriesPending(downloads, s.Upgrade)
+	if len(errs) > 0 && len(errs) >= len(downloads) {
+		return nil, errors.Join(errs...)
+	}
+
+	planned := make([]*PlannedBook, len(pending))
+	for i, b := range pending {
+		planned[i] = b.d.planned(b.book)
+		if b.replaces != nil {
+			planned[i].Replaces = filepath.Join(path, filepath.FromSlash(b.replaces.File))
+		}
+	}
+	return planned, nil
+}
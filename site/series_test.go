@@ -0,0 +1,164 @@
+package site
+
+import (
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSites serves a different list of books for each url under
+// https://sites.example/
+type testSites struct {
+	books map[string][]Book
+	errs  map[string]error
+}
+
+func (s *testSites) SiteName() string     { return "Test Sites" }
+func (s *testSites) Test(url string) bool { return strings.HasPrefix(url, "https://sites.example/") }
+func (s *testSites) Books(url string) ([]Book, error) {
+	return s.books[url], s.errs[url]
+}
+
+var testSeriesConnector = &testSites{}
+
+func init() {
+	RegisterMangaSite(testSeriesConnector)
+}
+
+const (
+	siteA = "https://sites.example/a"
+	siteB = "https://sites.example/b"
+)
+
+func plannedChapters(books []*PlannedBook) map[float64]string {
+	chapters := map[float64]string{}
+	for _, b := range books {
+		chapters[b.Chapter] = b.Source
+	}
+	return chapters
+}
+
+func TestPlan_series(t *testing.T) {
+	testSeriesConnector.books = map[string][]Book{
+		siteA: {&testBook{id: "a2", chapter: 2}, &testBook{id: "a3", chapter: 3}},
+		siteB: {&testBook{id: "b1", chapter: 1}, &testBook{id: "b2", chapter: 2}, &testBook{id: "b3", chapter: 3}, &testBook{id: "b4", chapter: 4}},
+	}
+	testSeriesConnector.errs = nil
+	dir := t.TempDir()
+	db := openTestDB(t)
+	src := &Source{Name: "Series", URL: siteA, Sources: []string{siteA, siteB}}
+
+	books, err := Plan(db, dir, src, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[float64]string{1: siteB, 2: siteA, 3: siteA, 4: siteB}, plannedChapters(books))
+	assert.Equal(t, filepath.Join(dir, "Series", "Series #1.cbz"), books[0].File)
+
+	// chapter 3 was downloaded from the lower priority site before
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Series"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Series", "b3.cbz"), []byte{}, 0644))
+	require.NoError(t, db.AddBook(&BookRecord{File: "Series/b3.cbz", BookID: "b3", Chapter: 3, Source: siteB}))
+
+	books, err = Plan(db, dir, src, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[float64]string{1: siteB, 2: siteA, 4: siteB}, plannedChapters(books))
+
+	src.Upgrade = true
+	books, err = Plan(db, dir, src, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[float64]string{1: siteB, 2: siteA, 3: siteA, 4: siteB}, plannedChapters(books))
+	assert.Equal(t, filepath.Join(dir, "Series", "b3.cbz"), books[2].Replaces)
+}
+
+func TestPlan_seriesBrokenSite(t *testing.T) {
+	testSeriesConnector.books = map[string][]Book{
+		siteB: {&testBook{id: "b1", chapter: 1}},
+	}
+	testSeriesConnector.errs = map[string]error{siteA: errors.New("expired")}
+	db := openTestDB(t)
+	src := &Source{Name: "Series", URL: siteA, Sources: []string{siteA, siteB}}
+
+	books, err := Plan(db, t.TempDir(), src, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[float64]string{1: siteB}, plannedChapters(books))
+
+	testSeriesConnector.errs[siteB] = errors.New("down")
+	_, err = Plan(db, t.TempDir(), src, nil)
+	assert.ErrorContains(t, err, "expired")
+	assert.ErrorContains(t, err, "down")
+}
+
+func TestDownload_seriesUpgrade(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = png.Encode(w, image.NewGray(image.Rect(0, 0, 20, 30)))
+	}))
+	defer srv.Close()
+
+	testSeriesConnector.books = map[string][]Book{
+		siteA: {&testBook{id: "a1", chapter: 1, pages: []Page{testPage(srv.URL + "/0.png")}}},
+		siteB: {&testBook{id: "b1", chapter: 1}},
+	}
+	testSeriesConnector.errs = nil
+	dir := t.TempDir()
+	db := openTestDB(t)
+
+	old := filepath.Join(dir, "Series", "Series - b1.cbz")
+	require.NoError(t, os.MkdirAll(filepath.Dir(old), 0755))
+	require.NoError(t, os.WriteFile(old, []byte{}, 0644))
+	require.NoError(t, db.AddBook(&BookRecord{File: "Series/Series - b1.cbz", BookID: "b1", Chapter: 1, Source: siteB}))
+	require.NoError(t, db.SetReadProgress("adam", "Series/Series - b1.cbz", &ReadProgress{Page: 1, Pages: 2}))
+
+	src := &Source{Name: "Series", URL: siteA, Sources: []string{siteA, siteB}, Upgrade: true}
+	require.NoError(t, Download(db, dir, src))
+
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, filepath.Join(dir, "Series", "Series #1.cbz"))
+
+	books, err := db.Books()
+	require.NoError(t, err)
+	require.Len(t, books, 1)
+	assert.Equal(t, "a1", books[0].BookID)
+	assert.Equal(t, siteA, books[0].Source)
+
+	p, err := db.ReadProgress("adam", "Series/Series #1.cbz")
+	require.NoError(t, err)
+	assert.NotNil(t, p)
+}
+
+func TestDownload_seriesFallback(t *testing.T) {
+	srv := pngServer(t)
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+	testSeriesConnector.books = map[string][]Book{
+		siteA: {
+			&testBook{id: "a1", chapter: 1, pages: []Page{testPage(missing.URL + "/0.png")}},
+			&testBook{id: "a2", chapter: 2, pages: []Page{testPage(srv.URL + "/0.png")}},
+		},
+		siteB: {
+			&testBook{id: "b1", chapter: 1, pages: []Page{testPage(srv.URL + "/0.png")}},
+			&testBook{id: "b2", chapter: 2},
+		},
+	}
+	testSeriesConnector.errs = nil
+	dir := t.TempDir()
+	db := openTestDB(t)
+
+	src := &Source{Name: "Series", URL: siteA, Sources: []string{siteA, siteB}}
+	require.NoError(t, Download(db, dir, src))
+
+	books, err := db.Books()
+	require.NoError(t, err)
+	sources := map[float64]string{}
+	for _, b := range books {
+		sources[b.Chapter] = b.Source
+	}
+	assert.Equal(t, map[float64]string{1: siteB, 2: siteA}, sources)
+}
@@ -1,6 +1,8 @@
 package site
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"image"
@@ -14,12 +16,77 @@ import (
 	"slices"
 	"strings"
 	"time"
+
+	"github.com/abibby/manga/imaging"
 )
 
 type Source struct {
 	Name string
 	URL  string
 	From float64
+	// To is the last chapter to download, inclusive
+	To float64
+	// Chapters and Volumes limit the source to lists of ranges like 1-10,15
+	Chapters Ranges
+	Volumes  Ranges
+	// Latest only downloads the newest n chapters
+	Latest int
+	// SkipExtras skips fractional chapters like 10.5 and books without a
+	// chapter number
+	SkipExtras bool `mapstructure:"skip_extras"`
+	// ReleasedAfter and ReleasedBefore are dates like 2024-01-31, books
+	// released before ReleasedAfter or on or after ReleasedBefore are skipped
+	ReleasedAfter  string `mapstructure:"released_after"`
+	ReleasedBefore string `mapstructure:"released_before"`
+
+	// Frequency overrides watch.frequency for this source
+	Frequency time.Duration
+	// Schedule is a cron expression, when set it is used instead of Frequency
+	Schedule string
+	// QuietHours is a time range like 23:00-07:00 the source won't run in
+	QuietHours string `mapstructure:"quiet_hours"`
+
+	// Notify can be set to false to stop new chapter notifications for
+	// this source
+	Notify *bool
+
+	// Sources are the urls of a series followed on several sites in
+	// priority order, URL is the first one. Each chapter is downloaded from
+	// the first source that has it.
+	Sources []string
+	// Upgrade replaces chapters downloaded from a lower priority source when
+	// a higher priority source has them
+	Upgrade bool
+
+	// Bundle assembles the chapters of a volume into one cbz once every
+	// chapter of the volume has been downloaded
+	Bundle bool
+	// BundleDelete removes the chapter files once they have been bundled
+	BundleDelete bool `mapstructure:"bundle_delete"`
+	// VolumeMap assigns chapters to volumes for sites that don't say which
+	// volume a chapter is in, e.g. 1: 1-8
+	VolumeMap map[int]Ranges `mapstructure:"volume_map"`
+
+	// Profile is the name of the image profile pages are processed with, it
+	// overrides the default profile. none saves pages as they are.
+	Profile string
+}
+
+// IsSeries returns true if the source follows a series on several sites.
+func (s *Source) IsSeries() bool {
+	return len(s.Sources) > 1
+}
+
+// ID is a short stable identifier for the source derived from its url
+func (s *Source) ID() string {
+	sum := sha1.Sum([]byte(s.URL))
+	return hex.EncodeToString(sum[:4])
+}
+
+// NotifyEnabled returns true if new chapters of the source should be
+// notified.
+func (s *Source) NotifyEnabled() bool {
+	return s.Notify == nil || *s.Notify
 }
 
 // MangaSite is an interface that represents a location to download manga
@@ -53,6 +120,10 @@ func (er *ErrorReader) Read([]byte) (int, error) {
 
 // BookInfo is the data that will be put into the book.json file in the cbz
 type BookInfo struct {
+	// ID and Source are the book id and source url the book was downloaded
+	// with, used to download it again
+	ID              string      `json:"id,omitempty"`
+	Source          string      `json:"source,omitempty"`
 	Series          string      `json:"series,omitempty"`
 	Title           string      `json:"title,omitempty"`
 	Volume          int         `json:"volume,omitempty"`
@@ -141,60 +212,298 @@ type sourceDownload struct {
 	path   string
 	site   MangaSite
 	source *Source
+	naming *Naming
+	// dryRun stops series names from being saved to the database
+	dryRun bool
+	// series is the source the download is part of when source is one of
+	// the sites of a series, events are sent for the series
+	series *Source
+	art    *seriesArt
+	// profile processes pages before they are archived, nil keeps them as
+	// they are
+	profile *imaging.Profile
+	// all caches the books of the site and books the ones returned by
+	// available
+	all   []Book
+	books []Book
 }
 
 // Download downloads all books from a given URL with chapter >= fromChapter
 func Download(db *DB, path string, s *Source) error {
+	if s.IsSeries() {
+		return downloadSeries(db, path, s, currentNaming())
+	}
+	d, err := newSourceDownload(db, path, s, currentNaming())
+	if err != nil {
+		return err
+	}
+	return d.download()
+}
+
+// PlannedBook is a book Plan found that would be downloaded
+type PlannedBook struct {
+	Source  string  `json:"source"`
+	ID      string  `json:"id"`
+	Series  string  `json:"series"`
+	Name    string  `json:"name"`
+	Volume  int     `json:"volume,omitempty"`
+	Chapter float64 `json:"chapter,omitempty"`
+	// File is the path the cbz would be saved to
+	File string `json:"file"`
+	// Replaces is the file of a book from a lower priority source of a
+	// series that will be replaced
+	Replaces string `json:"replaces,omitempty"`
+}
+
+// Plan returns the books Download would download without downloading any
+// pages or writing anything. naming can be used to preview a different naming
+// template, nil uses the current one.
+func Plan(db *DB, path string, s *Source, naming *Naming) ([]*PlannedBook, error) {
+	if naming == nil {
+		naming = currentNaming()
+	}
+	if s.IsSeries() {
+		return planSeries(db, path, s, naming)
+	}
+	d, err := newSourceDownload(db, path, s, naming)
+	if err != nil {
+		return nil, err
+	}
+	d.dryRun = true
+
+	books, err := d.pending()
+	if err != nil {
+		return nil, err
+	}
+	planned := make([]*PlannedBook, len(books))
+	for i, book := range books {
+		planned[i] = d.planned(book)
+	}
+	return planned, nil
+}
+
+func (d *sourceDownload) planned(book Book) *PlannedBook {
+	return &PlannedBook{
+		Source:  d.source.URL,
+		ID:      book.ID(),
+		Series:  d.bookSeries(book),
+		Name:    d.name(book),
+		Volume:  book.Volume(),
+		Chapter: book.Chapter(),
+		File:    d.folder(book) + ".cbz",
+	}
+}
+
+// Redownload downloads the book with the id from the source again even if it
+// has already been downloaded. The old cbz is kept until the new one has been
+// written. It returns the path of the new cbz.
+func Redownload(db *DB, path string, s *Source, bookID string) (string, error) {
+	d, err := newSourceDownload(db, path, s, currentNaming())
+	if err != nil {
+		return "", err
+	}
+	books, err := d.site.Books(s.URL)
+	if err != nil {
+		return "", err
+	}
+	i := slices.IndexFunc(books, func(b Book) bool { return b.ID() == bookID })
+	if i == -1 {
+		return "", fmt.Errorf("book %s not found in %s", bookID, s.URL)
+	}
+	return d.replaceBook(books[i])
+}
+
+// replaceBook downloads a book over the file it would be saved to, the old
+// file is put back if the download fails. It returns the path of the cbz.
+func (d *sourceDownload) replaceBook(book Book) (string, error) {
+	file := d.folder(book) + ".cbz"
+	backup := file + ".bak"
+	if fileExists(file) {
+		err := os.Rename(file, backup)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err := d.downloadBook(book)
+	if err != nil {
+		if fileExists(backup) {
+			_ = os.Rename(backup, file)
+		}
+		return "", err
+	}
+	_ = os.Remove(backup)
+	return file, nil
+}
+
+func newSourceDownload(db *DB, path string, s *Source, naming *Naming) (*sourceDownload, error) {
+	site, ok := FindSite(s.URL)
+	if !ok {
+		return nil, fmt.Errorf("no site that matches %s", s.URL)
+	}
+	profile, err := sourceProfile(s)
+	if err != nil {
+		return nil, err
+	}
+	return &sourceDownload{
+		db:      db,
+		path:    path,
+		site:    site,
+		source:  s,
+		naming:  naming,
+		art:     newSeriesArt(),
+		profile: profile,
+	}, nil
+}
+
+// FindSite returns the connector that can download the url
+func FindSite(url string) (MangaSite, bool) {
 	for _, site := range magnaSites {
-		if site.Test(s.URL) {
-			d := &sourceDownload{
-				db:     db,
-				path:   path,
-				site:   site,
-				source: s,
-			}
-			return d.download()
+		if site.Test(url) {
+			return site, true
 		}
 	}
-	return fmt.Errorf("no site that matches %s", s.URL)
+	return nil, false
 }
 
-func (d *sourceDownload) download() error {
-	books, err := d.site.Books(d.source.URL)
+// available returns the books of the source that match its selection in the
+// order they are downloaded
+func (d *sourceDownload) available() ([]Book, error) {
+	if d.books != nil {
+		return d.books, nil
+	}
+	books, err := d.siteBooks()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	books = slices.Clone(books)
 	slices.SortFunc(books, func(a, b Book) int {
 		return strings.Compare(
 			d.sortStr(a),
 			d.sortStr(b),
 		)
 	})
+	d.books, err = d.source.selectBooks(books)
+	return d.books, err
+}
+
+// siteBooks returns every book of the source before the selection is applied
+func (d *sourceDownload) siteBooks() ([]Book, error) {
+	if d.all != nil {
+		return d.all, nil
+	}
+	books, err := d.site.Books(d.source.URL)
+	if err != nil {
+		return nil, err
+	}
+	d.all = books
+	return books, nil
+}
 
+// pending returns the books of the source that are after From and haven't
+// been downloaded
+func (d *sourceDownload) pending() ([]Book, error) {
+	books, err := d.available()
+	if err != nil {
+		return nil, err
+	}
+
+	// imported books and books downloaded with a different naming template
+	// are found by id
+	downloaded, err := d.db.SourceBooks(d.source.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := []Book{}
 	for _, book := range books {
-		if book.Chapter() < d.source.From {
-			slog.Debug("chapter too early", "book", d.name(book), "from", d.source.From)
-			continue
-		}
 		bookFile := d.folder(book) + ".cbz"
 		if fileExists(bookFile) {
 			slog.Debug("chapter already downloaded", "book", d.name(book), "file", bookFile)
 			continue
 		}
-		slog.Info("Downloading book", "name", d.name(book))
-		err := d.downloadBook(book)
-		if err != nil {
-			slog.Error("Failed to download book", "name", d.name(book), "err", err)
+		if r, ok := d.recorded(downloaded, book); ok {
+			slog.Debug("chapter already downloaded", "book", d.name(book), "file", r.File)
+			continue
 		}
+		pending = append(pending, book)
+	}
+	return pending, nil
+}
+
+// recorded returns the record of a book in downloaded if its file still
+// exists
+func (d *sourceDownload) recorded(downloaded map[string]*BookRecord, book Book) (*BookRecord, bool) {
+	r, ok := downloaded[book.ID()]
+	if !ok || !d.recordExists(r) {
+		return nil, false
+	}
+	return r, true
+}
+
+// recordExists returns true if the file of a record, or the volume it was
+// bundled into, is on disk
+func (d *sourceDownload) recordExists(r *BookRecord) bool {
+	if isFile(filepath.Join(d.path, filepath.FromSlash(r.File))) {
+		return true
+	}
+	return r.Bundle != "" && isFile(filepath.Join(d.path, filepath.FromSlash(r.Bundle)))
+}
+
+// eventSource is the source events are sent for
+func (d *sourceDownload) eventSource() *Source {
+	if d.series != nil {
+		return d.series
+	}
+	return d.source
+}
+
+func (d *sourceDownload) download() error {
+	books, err := d.pending()
+	if err != nil {
+		return err
+	}
+
+	for _, book := range books {
+		_ = d.downloadLogged(book, false)
+	}
+	d.saveSeriesInfo(d.books)
+	if d.source.Bundle {
+		bundleLogged([]*sourceDownload{d})
 	}
 	return nil
 }
 
+// downloadLogged downloads a book and sends the started and failed events.
+// Callers carry on after an error so one broken book doesn't stop the rest.
+// replace downloads over the file the book would be saved to.
+func (d *sourceDownload) downloadLogged(book Book, replace bool) error {
+	slog.Info("Downloading book", "name", d.name(book))
+	emit(&Event{Type: EventBookStarted, Source: d.eventSource(), Connector: d.site.SiteName(), Book: book, Name: d.name(book)})
+	var err error
+	if replace {
+		_, err = d.replaceBook(book)
+	} else {
+		err = d.downloadBook(book)
+	}
+	if err != nil {
+		slog.Error("Failed to download book", "name", d.name(book), "err", err)
+		emit(&Event{Type: EventBookFailed, Source: d.eventSource(), Connector: d.site.SiteName(), Book: book, Name: d.name(book), Err: err})
+	} else {
+		d.art.updated[d.seriesFolder(book)] = true
+	}
+	return err
+}
+
 func (d *sourceDownload) bookSeries(book Book) string {
 	if d.source.Name != "" {
 		return d.source.Name
 	}
-	name, err := d.db.SeriesName(book)
+	seriesName := d.db.SeriesName
+	if d.dryRun {
+		seriesName = d.db.PeekSeriesName
+	}
+	name, err := seriesName(book)
 	if err != nil {
 		slog.Warn("Could not find series name in database", "err", err)
 		return book.Series()
@@ -208,17 +517,12 @@ func (d *sourceDownload) seriesFolder(book Book) string {
 }
 
 func (d *sourceDownload) name(book Book) string {
-	name := d.bookSeries(book)
-	if book.Volume() != 0 {
-		name += fmt.Sprintf(" V%d", book.Volume())
-	}
-	if book.Chapter() != 0 {
-		name += fmt.Sprintf(" #%.6g", book.Chapter())
-	}
-	if book.Volume() == 0 && book.Chapter() == 0 {
-		name += " " + book.ID()
-	}
-	return name
+	return bookName(d.naming, &NameData{
+		Series:  d.bookSeries(book),
+		Volume:  book.Volume(),
+		Chapter: book.Chapter(),
+		ID:      book.ID(),
+	})
 }
 func (d *sourceDownload) folder(book Book) string {
 	folder := fp.Join(d.seriesFolder(book), d.name(book))
@@ -248,6 +552,8 @@ func (d *sourceDownload) downloadBook(book Book) error {
 	if updatePages {
 		info.Pages = make([]*InfoPage, len(pages))
 	}
+	// the sizes the site gives are wrong once a profile has resized pages
+	updateSizes := updatePages || d.profile != nil
 	existingPages := map[string]string{}
 	pageFiles, err := os.ReadDir(folder)
 	if err != nil {
@@ -265,7 +571,7 @@ func (d *sourceDownload) downloadBook(book Book) error {
 		imageBasePath := fp.Join(folder, fmt.Sprintf("%03d", i))
 		var cfg image.Config
 		if file, ok := existingPages[imageBasePath]; ok {
-			if updatePages {
+			if updateSizes {
 				f, err := os.Open(file)
 				if err != nil {
 					return err
@@ -277,10 +583,21 @@ func (d *sourceDownload) downloadBook(book Book) error {
 				}
 			}
 		} else {
-			cfg, err = saveImage(page, imageBasePath)
+			var size int
+			cfg, size, err = saveImage(page, imageBasePath, d.profile)
 			if err != nil {
 				return err
 			}
+			emit(&Event{
+				Type:      EventPageDownloaded,
+				Source:    d.eventSource(),
+				Connector: d.site.SiteName(),
+				Book:      book,
+				Name:      d.name(book),
+				Page:      i,
+				Pages:     len(pages),
+				Bytes:     size,
+			})
 		}
 
 		if updatePages {
@@ -301,6 +618,9 @@ func (d *sourceDownload) downloadBook(book Book) error {
 				Height: h,
 				Type:   typ,
 			}
+		} else if updateSizes && i < len(info.Pages) && info.Pages[i] != nil {
+			info.Pages[i].Width = cfg.Width
+			info.Pages[i].Height = cfg.Height
 		}
 	}
 
@@ -322,6 +642,8 @@ func (d *sourceDownload) downloadBook(book Book) error {
 			info.Pages = append(info.Pages, page)
 		}
 	}
+	info.ID = book.ID()
+	info.Source = d.source.URL
 	info.Series = d.bookSeries(book)
 	b, err := json.MarshalIndent(info, "", "    ")
 	if err != nil {
@@ -342,10 +664,51 @@ func (d *sourceDownload) downloadBook(book Book) error {
 		return err
 	}
 
+	err = d.recordBook(book, info, file)
+	if err != nil {
+		slog.Warn("Failed to record book in database", "name", d.name(book), "err", err)
+	}
+
+	emit(&Event{
+		Type:      EventBookDownloaded,
+		Source:    d.eventSource(),
+		Connector: d.site.SiteName(),
+		Book:      book,
+		Name:      d.name(book),
+		File:      file,
+		Info:      info,
+		Pages:     len(pages),
+	})
+
 	return nil
 }
 
+func (d *sourceDownload) recordBook(book Book, info *BookInfo, file string) error {
+	rel, err := filepath.Rel(d.path, file)
+	if err != nil {
+		return err
+	}
+	return d.db.AddBook(&BookRecord{
+		File:         filepath.ToSlash(rel),
+		SeriesID:     book.SeriesID(),
+		Series:       info.Series,
+		BookID:       book.ID(),
+		Chapter:      book.Chapter(),
+		Volume:       book.Volume(),
+		Title:        info.Title,
+		Source:       d.source.URL,
+		DownloadedAt: time.Now(),
+	})
+}
+
 func fileExists(f string) bool {
 	_, err := os.Stat(f)
 	return err == nil
 }
+
+// isFile is fileExists for anything but a directory, the page folder of an
+// unfinished download shouldn't count as the book.
+func isFile(f string) bool {
+	stat, err := os.Stat(f)
+	return err == nil && !stat.IsDir()
+}
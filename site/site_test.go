@@ -0,0 +1,171 @@
+package site
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSite struct {
+	books []Book
+}
+
+func (s *testSite) SiteName() string                 { return "Test" }
+func (s *testSite) Test(url string) bool             { return url == "https://test.example/series" }
+func (s *testSite) Books(url string) ([]Book, error) { return s.books, nil }
+
+type testBook struct {
+	id       string
+	volume   int
+	chapter  float64
+	released time.Time
+	author   string
+	pages    []Page
+}
+
+type testPage string
+
+func (p testPage) URL() (string, error) { return string(p), nil }
+
+func (b *testBook) Pages() ([]Page, error) {
+	if b.pages == nil {
+		panic("pages should not be loaded")
+	}
+	return b.pages, nil
+}
+
+func (b *testBook) ID() string       { return b.id }
+func (b *testBook) Series() string   { return "Test Series" }
+func (b *testBook) SeriesID() string { return "test:series" }
+func (b *testBook) Chapter() float64 { return b.chapter }
+func (b *testBook) Volume() int      { return b.volume }
+func (b *testBook) Info() *BookInfo {
+	return &BookInfo{Chapter: b.chapter, Author: b.author, DateReleased: b.released}
+}
+
+var testConnector = &testSite{}
+
+func init() {
+	RegisterMangaSite(testConnector)
+}
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := OpenDB(filepath.Join(t.TempDir(), "manga.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPlan(t *testing.T) {
+	testConnector.books = []Book{
+		&testBook{id: "3", chapter: 3},
+		&testBook{id: "1", chapter: 1},
+		&testBook{id: "2", chapter: 2},
+		&testBook{id: "4", volume: 1, chapter: 4},
+	}
+	dir := t.TempDir()
+	db := openTestDB(t)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "Test Series"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Test Series", "Test Series #3.cbz"), []byte{}, 0644))
+
+	src := &Source{URL: "https://test.example/series", From: 2}
+	books, err := Plan(db, dir, src, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []*PlannedBook{
+		{Source: src.URL, ID: "4", Series: "Test Series", Name: "Test Series V1 #4", Volume: 1, Chapter: 4, File: filepath.Join(dir, "Test Series", "Test Series V1 #4.cbz")},
+		{Source: src.URL, ID: "2", Series: "Test Series", Name: "Test Series #2", Chapter: 2, File: filepath.Join(dir, "Test Series", "Test Series #2.cbz")},
+	}, books)
+
+	series, err := db.AllSeries()
+	require.NoError(t, err)
+	assert.Empty(t, series, "dry runs should not save series names")
+}
+
+func TestPlan_naming(t *testing.T) {
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1},
+	}
+	dir := t.TempDir()
+	naming, err := ParseNaming(`{{.Series}} c{{pad 3 .Chapter}}`)
+	require.NoError(t, err)
+
+	books, err := Plan(openTestDB(t), dir, &Source{Name: "Pinned", URL: "https://test.example/series"}, naming)
+	require.NoError(t, err)
+
+	require.Len(t, books, 1)
+	assert.Equal(t, filepath.Join(dir, "Pinned", "Pinned c001.cbz"), books[0].File)
+}
+
+func TestDownload_unfinishedFolder(t *testing.T) {
+	srv := pngServer(t)
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1, pages: []Page{testPage(srv.URL + "/0.png")}},
+	}
+	dir := t.TempDir()
+	// the pages of an interrupted download that were recorded by an import
+	folder := filepath.Join(dir, "Test Series", "Test Series #1")
+	require.NoError(t, os.MkdirAll(folder, 0755))
+	db := openTestDB(t)
+	require.NoError(t, db.AddBook(&BookRecord{
+		File:   "Test Series/Test Series #1",
+		BookID: "1",
+		Source: "https://test.example/series",
+	}))
+
+	require.NoError(t, Download(db, dir, &Source{URL: "https://test.example/series"}))
+	assert.FileExists(t, folder+".cbz")
+	assert.NoDirExists(t, folder)
+}
+
+func TestRedownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = png.Encode(w, image.NewGray(image.Rect(0, 0, 20, 30)))
+	}))
+	defer srv.Close()
+
+	testConnector.books = []Book{
+		&testBook{id: "1", chapter: 1, pages: []Page{testPage(srv.URL + "/0.png"), testPage(srv.URL + "/1.png")}},
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "Test Series", "Test Series #1.cbz")
+	require.NoError(t, os.MkdirAll(filepath.Dir(file), 0755))
+	require.NoError(t, os.WriteFile(file, []byte("broken"), 0644))
+
+	db := openTestDB(t)
+	got, err := Redownload(db, dir, &Source{URL: "https://test.example/series"}, "1")
+	require.NoError(t, err)
+	assert.Equal(t, file, got)
+	assert.NoFileExists(t, file+".bak")
+
+	zr, err := zip.OpenReader(file)
+	require.NoError(t, err)
+	defer zr.Close()
+	f, err := zr.Open("book.json")
+	require.NoError(t, err)
+	info := &BookInfo{}
+	require.NoError(t, json.NewDecoder(f).Decode(info))
+	assert.Equal(t, "1", info.ID)
+	assert.Equal(t, "https://test.example/series", info.Source)
+	assert.Len(t, info.Pages, 2)
+
+	record, err := db.Book("Test Series/Test Series #1.cbz")
+	require.NoError(t, err)
+	require.NotNil(t, record)
+	assert.Equal(t, "1", record.BookID)
+
+	_, err = Redownload(db, dir, &Source{URL: "https://test.example/series"}, "2")
+	assert.ErrorContains(t, err, "book 2 not found")
+}
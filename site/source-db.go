@@ -0,0 +1,51 @@
+package site
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// SourceState is what the watcher remembers about a source between restarts.
+type SourceState struct {
+	LastRun     time.Time `json:"last_run"`
+	LastSuccess time.Time `json:"last_success,omitzero"`
+	NextRun     time.Time `json:"next_run"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// SourceState returns the saved state of the source with the given url, a
+// source that has never run returns an empty state.
+func (db *DB) SourceState(url string) (*SourceState, error) {
+	state := &SourceState{}
+	err := db.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("sources"))
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(url))
+		if v == nil {
+			return nil
+		}
+		return json.Unmarshal(v, state)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (db *DB) SetSourceState(url string, state *SourceState) error {
+	v, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return db.db.Update(func(tx *bbolt.Tx) error {
+		b, err := bucket(tx, "sources")
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(url), v)
+	})
+}
@@ -3,6 +3,7 @@ package site
 import (
 	"archive/zip"
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"io"
@@ -14,28 +15,36 @@ import (
 	_ "image/jpeg"
 	_ "image/png"
 
+	"github.com/abibby/manga/imaging"
 	_ "golang.org/x/image/bmp"
 	_ "golang.org/x/image/webp"
 )
 
-func saveImage(page Page, path string) (image.Config, error) {
+// ErrHTTPStatus is returned when a page request doesn't return a 2xx status.
+var ErrHTTPStatus = errors.New("unexpected http status")
+
+// saveImage downloads the page to path, adding the extension for the image
+// type. The page is run through the profile when it isn't nil. It returns the
+// image config and the size of the response body.
+func saveImage(page Page, path string, profile *imaging.Profile) (image.Config, int, error) {
 	uri, err := page.URL()
 	if err != nil {
-		return image.Config{}, err
+		return image.Config{}, 0, err
 	}
 
 	response, err := client.Get(uri)
 	if err != nil {
-		return image.Config{}, fmt.Errorf("failed to fetch image: %v", err)
+		return image.Config{}, 0, fmt.Errorf("failed to fetch image: %w", err)
 	}
 
 	defer response.Body.Close()
 
 	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		return image.Config{}, fmt.Errorf("image fetch failed with %s", response.Status)
+		return image.Config{}, 0, fmt.Errorf("image fetch failed with %s: %w", response.Status, ErrHTTPStatus)
 	}
 
-	var body io.Reader = response.Body
+	counter := &countingReader{r: response.Body}
+	var body io.Reader = counter
 
 	if decoder, ok := page.(ImageDecrypter); ok {
 		body = decoder.ImageDecrypt(body)
@@ -43,12 +52,19 @@ func saveImage(page Page, path string) (image.Config, error) {
 
 	b, err := io.ReadAll(body)
 	if err != nil {
-		return image.Config{}, err
+		return image.Config{}, 0, err
+	}
+
+	if profile != nil {
+		b, _, err = profile.Process(b)
+		if err != nil {
+			return image.Config{}, 0, fmt.Errorf("could not process image '%s': %w", uri, err)
+		}
 	}
 
 	cfg, imgTyp, err := image.DecodeConfig(bytes.NewBuffer(b))
 	if err != nil {
-		return image.Config{}, fmt.Errorf("could not decode image '%s': %v", uri, err)
+		return image.Config{}, 0, fmt.Errorf("could not decode image '%s': %w", uri, err)
 	}
 
 	ext := "." + imgTyp
@@ -57,7 +73,19 @@ func saveImage(page Page, path string) (image.Config, error) {
 		path += ext
 	}
 
-	return cfg, os.WriteFile(path, b, 0644)
+	return cfg, counter.n, os.WriteFile(path, b, 0644)
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
 }
 
 // from http://blog.ralch.com/tutorial/golang-working-with-zip/